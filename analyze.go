@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// noiseFloorPercentile is the percentile of each stretch of the power series
+// taken as the noise floor there. Bursts are short compared to the stretch,
+// so they don't raise it much.
+const noiseFloorPercentile = 20
+
+// powerSeries is a recording's power over time, in dB relative to full
+// scale, one value every Resolution from Start. Floor is the noise floor at
+// each point.
+type powerSeries struct {
+	Start      time.Time
+	Resolution time.Duration
+	Power      []float64
+	Floor      []float64
+}
+
+// detection is a stretch of the recording where the power was over the noise
+// floor by at least the threshold. PeakDB is how far over the floor it got.
+// The state is worked out for the middle of the detection, and Window is the
+// forecast window it falls in, if any.
+type detection struct {
+	Start  time.Time
+	End    time.Time
+	PeakDB float64
+	State  *jovianState
+	Window *forecastWindow
+}
+
+type jsonAnalysis struct {
+	File         string           `json:"file"`
+	Format       string           `json:"format"`
+	SampleRateHz int              `json:"sample_rate_hz"`
+	Channels     int              `json:"channels"`
+	Start        time.Time        `json:"start"`
+	End          time.Time        `json:"end"`
+	Resolution   string           `json:"resolution"`
+	ThresholdDB  float64          `json:"threshold_db"`
+	Windows      []*jsonWindow    `json:"windows"`
+	Detections   []*jsonDetection `json:"detections"`
+}
+
+type jsonDetection struct {
+	Start       time.Time   `json:"start"`
+	End         time.Time   `json:"end"`
+	Duration    string      `json:"duration"`
+	PeakDB      float64     `json:"peak_db"`
+	CMLDeg      float64     `json:"cml_deg"`
+	IoPhaseDeg  float64     `json:"io_phase_deg"`
+	DistanceAU  float64     `json:"distance_au"`
+	RadioSource string      `json:"radio_source"`
+	AltitudeDeg *float64    `json:"altitude_deg,omitempty"`
+	AzimuthDeg  *float64    `json:"azimuth_deg,omitempty"`
+	Window      *jsonWindow `json:"window,omitempty"`
+}
+
+func analyzeCommand(args []string) error {
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	sampleRate := flags.Int("sample-rate", 0, "Sample rate in Hz of raw I/Q files without a sidecar.")
+	resolution := flags.Duration("resolution", 100*time.Millisecond, "How long to average the power over for each point of the power series.")
+	floorWindow := flags.Duration("floor-window", 5*time.Minute, "How long a stretch of the recording to find each part of the noise floor from.")
+	threshold := flags.Float64("threshold", 6, "How many dB over the noise floor counts as a burst.")
+	minDuration := flags.Duration("min-duration", 0, "Ignore detections shorter than this.")
+	gap := flags.Duration("gap", time.Second, "Join up detections no more than this far apart. With 0, only detections right next to each other are joined.")
+	powerCSV := flags.String("power-csv", "", "Optional path to write the power series and noise floor to, as CSV.")
+	output := flags.String("output", "text", "How to format the analysis. Currently acceptable options are: text (default), json.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise analyze [options] <recording>\n\nFinds bursts of noise in a WAV or raw I/Q recording, and compares them with the forecast. -start-time is when the recording started; recordings from the record command have it in their sidecars.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("analyze needs a recording to analyze.")
+	}
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("Output format '%s' is not a valid selection.", *output)
+	}
+	if *resolution < time.Millisecond {
+		return fmt.Errorf("-resolution must be at least a millisecond.")
+	}
+	if *floorWindow < *resolution {
+		return fmt.Errorf("-floor-window must be longer than -resolution.")
+	}
+	if *threshold <= 0 {
+		return fmt.Errorf("-threshold must be more than 0.")
+	}
+
+	sf, err := openSampleFile(flags.Arg(0), *sampleRate)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+	if fp.StartTime != "" {
+		if sf.Start, err = time.Parse(time.RFC3339, fp.StartTime); err != nil {
+			return fmt.Errorf("Error parsing -start-time: %s", err)
+		}
+	}
+	if sf.Start.IsZero() {
+		return fmt.Errorf("Give the time %s started recording with -start-time.", sf.Path)
+	}
+
+	ps, err := readPowerSeries(sf, *resolution)
+	if err != nil {
+		return err
+	}
+	if len(ps.Power) == 0 {
+		return fmt.Errorf("%s is too short to analyze.", sf.Path)
+	}
+	ps.findFloor(int(*floorWindow / *resolution))
+	end := ps.Start.Add(time.Duration(len(ps.Power)) * ps.Resolution)
+
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+	jData, windows, err := recordingForecast(p, fp, ps.Start, end)
+	if err != nil {
+		return err
+	}
+
+	var observer = &jData.Coords
+	if !jData.LocalForecast {
+		observer = nil
+	}
+	dets := ps.detect(*threshold, *minDuration, *gap)
+	for _, d := range dets {
+		d.State = p.stateAt(d.Start.Add(d.End.Sub(d.Start)/2), observer)
+		for _, fw := range windows {
+			if (timeSpan{d.Start, d.End}).overlaps(timeSpan{fw.Start, fw.End}) {
+				d.Window = fw
+				break
+			}
+		}
+	}
+
+	if *powerCSV != "" {
+		if err = ps.writeCSV(*powerCSV); err != nil {
+			return err
+		}
+	}
+
+	if *output == "json" {
+		return outputAnalysisJSON(sf, ps, *threshold, windows, dets)
+	}
+	outputAnalysisText(jData, sf, ps, *floorWindow, *threshold, windows, dets)
+	return nil
+}
+
+// recordingForecast calculates the forecast for a recording, and returns it
+// with the windows that overlap the recording. The forecast starts far
+// enough back to see whole windows that were already open when the
+// recording started.
+func recordingForecast(p *planets, fp *forecastParams, start time.Time, end time.Time) (*jupiterData, []*forecastWindow, error) {
+	step := time.Duration(fp.Interval) * time.Minute
+	from := start.Truncate(step).Add(-windowOverlap)
+	fp.StartTime = from.UTC().Format(time.RFC3339)
+	fp.Duration = end.Sub(from) + step
+	jData, err := p.forecast(fp)
+	if err != nil {
+		return nil, nil, err
+	}
+	span := timeSpan{start, end}
+	windows := make([]*forecastWindow, 0)
+	for _, fw := range jData.Windows() {
+		if span.overlaps(timeSpan{fw.Start, fw.End}) {
+			windows = append(windows, fw)
+		}
+	}
+	return jData, windows, nil
+}
+
+// readPowerSeries reads the whole recording, averaging its power over each
+// stretch of the given resolution. For I/Q recordings the power is I² + Q²,
+// and for other recordings it's averaged over the channels. A short stretch
+// at the end is kept if it's at least half the resolution.
+func readPowerSeries(sf *sampleFile, resolution time.Duration) (*powerSeries, error) {
+	bin := int(math.Round(resolution.Seconds() * float64(sf.SampleRate)))
+	if bin < 1 {
+		return nil, fmt.Errorf("-resolution is shorter than one sample at %d Hz.", sf.SampleRate)
+	}
+	ps := &powerSeries{Start: sf.Start, Resolution: resolution, Power: make([]float64, 0)}
+	buf := make([]float64, bin*sf.Channels)
+	for {
+		n, err := sf.readFrames(buf)
+		if n > 0 && n*2 >= bin {
+			var sum float64
+			for _, v := range buf[:n*sf.Channels] {
+				sum += v * v
+			}
+			pow := sum / float64(n)
+			if !sf.Complex {
+				pow /= float64(sf.Channels)
+			}
+			ps.Power = append(ps.Power, 10*math.Log10(pow+1e-20))
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %s", sf.Path, err)
+		}
+	}
+	return ps, nil
+}
+
+// findFloor works out the noise floor, as a percentile of each block of the
+// power series. A short block at the end is joined onto the one before.
+func (ps *powerSeries) findFloor(block int) {
+	ps.Floor = make([]float64, len(ps.Power))
+	for i := 0; i < len(ps.Power); i += block {
+		end := i + block
+		if len(ps.Power)-end < block/2 {
+			end = len(ps.Power)
+		}
+		vals := append([]float64(nil), ps.Power[i:end]...)
+		sort.Float64s(vals)
+		floor := vals[(len(vals)-1)*noiseFloorPercentile/100]
+		for j := i; j < end; j++ {
+			ps.Floor[j] = floor
+		}
+		if end == len(ps.Power) {
+			break
+		}
+	}
+}
+
+// detect finds the stretches where the power is at least threshold dB over
+// the noise floor, joining up ones no more than gap apart and dropping ones
+// shorter than minDuration.
+func (ps *powerSeries) detect(threshold float64, minDuration time.Duration, gap time.Duration) []*detection {
+	at := func(i int) time.Time {
+		return ps.Start.Add(time.Duration(i) * ps.Resolution)
+	}
+	dets := make([]*detection, 0)
+	var cur *detection
+	for i, pow := range ps.Power {
+		over := pow - ps.Floor[i]
+		if over < threshold {
+			continue
+		}
+		if cur != nil && at(i).Sub(cur.End) <= gap {
+			cur.End = at(i + 1)
+			cur.PeakDB = math.Max(cur.PeakDB, over)
+			continue
+		}
+		cur = &detection{Start: at(i), End: at(i + 1), PeakDB: over}
+		dets = append(dets, cur)
+	}
+
+	kept := dets[:0]
+	for _, d := range dets {
+		if d.End.Sub(d.Start) >= minDuration {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+func (ps *powerSeries) writeCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "time,power_db,floor_db\n")
+	for i, pow := range ps.Power {
+		t := ps.Start.Add(time.Duration(i) * ps.Resolution).UTC()
+		fmt.Fprintf(w, "%s,%s,%s\n", t.Format(time.RFC3339Nano), strconv.FormatFloat(pow, 'f', 2, 64), strconv.FormatFloat(ps.Floor[i], 'f', 2, 64))
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sourceName is the name of the radio source the state is in the region
+// of, or "none".
+func (st *jovianState) sourceName() string {
+	if st.RadioSource == NoEvent {
+		return "none"
+	}
+	return st.RadioSource.String()
+}
+
+func outputAnalysisText(jData *jupiterData, sf *sampleFile, ps *powerSeries, floorWindow time.Duration, threshold float64, windows []*forecastWindow, dets []*detection) {
+	loc := jData.displayLocation()
+	end := ps.Start.Add(time.Duration(len(ps.Power)) * ps.Resolution)
+	zone, _ := end.In(loc).Zone()
+	channels := fmt.Sprintf("%d channels", sf.Channels)
+	switch {
+	case sf.Complex:
+		channels = "I/Q"
+	case sf.Channels == 1:
+		channels = "mono"
+	}
+	fmt.Printf("Analysis of %s (%s, %d Hz, %s): %s until %s %s (%s).\n", sf.Path, sf.Format, sf.SampleRate, channels, ps.Start.In(loc).Format("2006-01-02 15:04:05"), end.In(loc).Format("2006-01-02 15:04:05"), zone, end.Sub(ps.Start).Round(time.Second))
+	fmt.Printf("Power is averaged over %s. The noise floor is the %dth percentile of each %s, and bursts are at least %.1f dB over it.\n\n", ps.Resolution, noiseFloorPercentile, floorWindow, threshold)
+
+	hits := make(map[*forecastWindow]bool)
+	inWindows := 0
+	for _, d := range dets {
+		if d.Window != nil {
+			hits[d.Window] = true
+			inWindows++
+		}
+	}
+
+	if len(windows) == 0 {
+		fmt.Printf("No forecast windows during the recording.\n\n")
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 1, 8, 1, ' ', 0)
+		fmt.Fprintf(w, "Window\tStart\tEnd\tRec\tBursts\t\n")
+		fmt.Fprintf(w, "------\t-----\t---\t---\t------\t\n")
+		for _, fw := range windows {
+			rec, hit := "N", "N"
+			if fw.Recommended() {
+				rec = "Y"
+			}
+			if hits[fw] {
+				hit = "Y"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", fw.RadioSource, fw.Start.In(loc).Format("Jan 02 15:04"), fw.End.In(loc).Format("Jan 02 15:04"), rec, hit)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	if len(dets) == 0 {
+		fmt.Printf("No bursts found.\n")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 8, 1, ' ', 0)
+	if jData.LocalForecast {
+		fmt.Fprintf(w, "Start\tEnd\tLength\tPeak dB\tPhase°\tCML\tDist.\tSrc\tAlt.\tAz.\tWindow\t\n")
+		fmt.Fprintf(w, "-----\t---\t------\t-------\t------\t---\t-----\t---\t----\t---\t------\t\n")
+	} else {
+		fmt.Fprintf(w, "Start\tEnd\tLength\tPeak dB\tPhase°\tCML\tDist.\tSrc\tWindow\t\n")
+		fmt.Fprintf(w, "-----\t---\t------\t-------\t------\t---\t-----\t---\t------\t\n")
+	}
+	for _, d := range dets {
+		window := "-"
+		if d.Window != nil {
+			window = d.Window.RadioSource.String()
+		}
+		st := d.State
+		var altAz string
+		if st.AltAz != nil {
+			altAz = fmt.Sprintf("%0.1f\t%0.1f\t", st.AltAz.Altitude.Deg(), st.AltAz.Azimuth.Deg())
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%0.1f\t%0.2f\t%0.2f\t%0.2f\t%s\t%s%s\t\n", d.Start.In(loc).Format("15:04:05.0"), d.End.In(loc).Format("15:04:05.0"), d.End.Sub(d.Start), d.PeakDB, st.IoPhase.Deg(), st.Meridian.Deg(), st.Distance, st.sourceName(), altAz, window)
+	}
+	w.Flush()
+	fmt.Printf("\n%d bursts, %d during forecast windows. %d of %d forecast windows had bursts.\n", len(dets), inWindows, len(hits), len(windows))
+}
+
+func outputAnalysisJSON(sf *sampleFile, ps *powerSeries, threshold float64, windows []*forecastWindow, dets []*detection) error {
+	ja := &jsonAnalysis{
+		File:         sf.Path,
+		Format:       sf.Format,
+		SampleRateHz: sf.SampleRate,
+		Channels:     sf.Channels,
+		Start:        ps.Start.UTC(),
+		End:          ps.Start.Add(time.Duration(len(ps.Power)) * ps.Resolution).UTC(),
+		Resolution:   isoDuration(ps.Resolution),
+		ThresholdDB:  threshold,
+		Windows:      make([]*jsonWindow, 0, len(windows)),
+		Detections:   make([]*jsonDetection, 0, len(dets)),
+	}
+	for _, fw := range windows {
+		ja.Windows = append(ja.Windows, newJSONWindow(fw))
+	}
+	for _, d := range dets {
+		st := d.State
+		jd := &jsonDetection{
+			Start:       d.Start.UTC(),
+			End:         d.End.UTC(),
+			Duration:    isoDuration(d.End.Sub(d.Start)),
+			PeakDB:      d.PeakDB,
+			CMLDeg:      st.Meridian.Deg(),
+			IoPhaseDeg:  st.IoPhase.Deg(),
+			DistanceAU:  st.Distance,
+			RadioSource: st.sourceName(),
+		}
+		if st.AltAz != nil {
+			alt, az := st.AltAz.Altitude.Deg(), st.AltAz.Azimuth.Deg()
+			jd.AltitudeDeg, jd.AzimuthDeg = &alt, &az
+		}
+		if d.Window != nil {
+			jd.Window = newJSONWindow(d.Window)
+		}
+		ja.Detections = append(ja.Detections, jd)
+	}
+	j, err := json.MarshalIndent(ja, "", "\t")
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(j)
+	fmt.Println()
+	return nil
+}
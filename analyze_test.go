@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindFloor(t *testing.T) {
+	tests := []struct {
+		name  string
+		power []float64
+		block int
+		want  []float64
+	}{
+		{
+			"whole blocks",
+			[]float64{3, 0, 2, 1, 7, 4, 6, 5},
+			4,
+			[]float64{0, 0, 0, 0, 4, 4, 4, 4},
+		},
+		{
+			"short last block joined on",
+			[]float64{5, 1, 3, 2, 10, 9, 8, 7, 6},
+			4,
+			[]float64{1, 1, 1, 1, 6, 6, 6, 6, 6},
+		},
+		{
+			"last block half the size kept",
+			[]float64{0, 1, 2, 3, 4, 5, 6, 7, 9, 8},
+			4,
+			[]float64{0, 0, 0, 0, 4, 4, 4, 4, 8, 8},
+		},
+		{
+			"block longer than the series",
+			[]float64{-40, -50, -45},
+			10,
+			[]float64{-50, -50, -50},
+		},
+		{
+			// the 20th percentile of 11 values is the third lowest,
+			// however high the bursts go
+			"percentile",
+			[]float64{10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+			11,
+			[]float64{2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2},
+		},
+		{
+			"empty",
+			[]float64{},
+			4,
+			[]float64{},
+		},
+	}
+	for _, tt := range tests {
+		ps := &powerSeries{Power: tt.power}
+		ps.findFloor(tt.block)
+		if fmt.Sprint(ps.Floor) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: floor is %v, want %v", tt.name, ps.Floor, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	start := time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)
+	// want is each detection's start and end, in seconds from the start,
+	// and peak
+	tests := []struct {
+		name        string
+		power       []float64
+		floor       []float64
+		minDuration time.Duration
+		gap         time.Duration
+		want        [][3]float64
+	}{
+		{"nothing over the threshold", []float64{0, 5.9, 3}, nil, 0, 0, nil},
+		{"exactly the threshold", []float64{0, 6, 0}, nil, 0, 0, [][3]float64{{1, 2, 6}}},
+		{"adjacent bins with no gap", []float64{0, 7, 9, 8, 0}, nil, 0, 0, [][3]float64{{1, 4, 9}}},
+		{"separate bursts with no gap", []float64{7, 0, 8}, nil, 0, 0, [][3]float64{{0, 1, 7}, {2, 3, 8}}},
+		{"joined across the gap", []float64{7, 0, 0, 8}, nil, 0, 2 * time.Second, [][3]float64{{0, 4, 8}}},
+		{"further apart than the gap", []float64{7, 0, 0, 8}, nil, 0, time.Second, [][3]float64{{0, 1, 7}, {3, 4, 8}}},
+		{"short burst dropped", []float64{7, 0, 0, 8, 8, 0}, nil, 2 * time.Second, 0, [][3]float64{{3, 5, 8}}},
+		{"over the floor, not zero", []float64{15, 16, -30}, []float64{10, 10, -40}, 0, 0, [][3]float64{{1, 3, 10}}},
+		{"burst at the end", []float64{0, 0, 12}, nil, 0, 0, [][3]float64{{2, 3, 12}}},
+	}
+	for _, tt := range tests {
+		ps := &powerSeries{Start: start, Resolution: time.Second, Power: tt.power, Floor: tt.floor}
+		if ps.Floor == nil {
+			ps.Floor = make([]float64, len(ps.Power))
+		}
+		got := ps.detect(6, tt.minDuration, tt.gap)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d detections, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, d := range got {
+			w := tt.want[i]
+			wantStart, wantEnd := start.Add(time.Duration(w[0])*time.Second), start.Add(time.Duration(w[1])*time.Second)
+			if !d.Start.Equal(wantStart) || !d.End.Equal(wantEnd) || d.PeakDB != w[2] {
+				t.Errorf("%s: detection %d is %s to %s peaking at %g dB, want %s to %s at %g dB", tt.name, i, d.Start, d.End, d.PeakDB, wantStart, wantEnd, w[2])
+			}
+		}
+	}
+}
+
+func TestReadPowerSeries(t *testing.T) {
+	start := time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)
+	silence := -200.0
+	tests := []struct {
+		name       string
+		file       string
+		data       []byte
+		sidecar    string
+		resolution time.Duration
+		want       []float64
+	}{
+		{
+			// a tone at half scale, silence, then a half length bin at
+			// full scale that's kept, at 100 samples a bin
+			"mono",
+			"mono.wav",
+			wavBytes(1000, 1, append(append(repeat16(200, 16384, -16384), repeat16(100, 0)...), repeat16(50, -32768)...)),
+			"",
+			100 * time.Millisecond,
+			[]float64{20 * math.Log10(0.5), 20 * math.Log10(0.5), silence, 0},
+		},
+		{
+			"short last bin dropped",
+			"short.wav",
+			wavBytes(1000, 1, repeat16(149, 16384)),
+			"",
+			100 * time.Millisecond,
+			[]float64{20 * math.Log10(0.5)},
+		},
+		{
+			// only the left channel has anything, so the power is
+			// halved
+			"stereo",
+			"stereo.wav",
+			wavBytes(1000, 2, repeat16(200, 16384, 0)),
+			"",
+			100 * time.Millisecond,
+			[]float64{10 * math.Log10(0.125)},
+		},
+		{
+			// I² + Q² isn't averaged over the two
+			"I/Q",
+			"iq.cu8",
+			bytes.Repeat([]byte{255, 0}, 500),
+			`{"start": "2024-01-01T05:00:00Z", "sample_rate_hz": 1000}`,
+			250 * time.Millisecond,
+			[]float64{10 * math.Log10(2), 10 * math.Log10(2)},
+		},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		path := filepath.Join(dir, tt.file)
+		if err := os.WriteFile(path, tt.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if tt.sidecar != "" {
+			if err := os.WriteFile(strings.TrimSuffix(path, filepath.Ext(path))+".json", []byte(tt.sidecar), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		sf, err := openSampleFile(path, 0)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		ps, err := readPowerSeries(sf, tt.resolution)
+		sf.Close()
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if ps.Resolution != tt.resolution {
+			t.Errorf("%s: resolution is %s", tt.name, ps.Resolution)
+		}
+		if tt.sidecar != "" && !ps.Start.Equal(start) {
+			t.Errorf("%s: starts at %s, want the sidecar's %s", tt.name, ps.Start, start)
+		}
+		if len(ps.Power) != len(tt.want) {
+			t.Errorf("%s: power is %v, want %v", tt.name, ps.Power, tt.want)
+			continue
+		}
+		for i := range ps.Power {
+			if math.Abs(ps.Power[i]-tt.want[i]) > 1e-6 {
+				t.Errorf("%s: power is %v, want %v", tt.name, ps.Power, tt.want)
+				break
+			}
+		}
+	}
+
+	sf, err := openSampleFile(writeTestFile(t, "fast.wav", wavBytes(1000, 1, repeat16(10, 0))), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close()
+	if _, err := readPowerSeries(sf, 100*time.Microsecond); err == nil {
+		t.Error("a resolution shorter than a sample was allowed")
+	}
+}
+
+// repeat16 is n 16 bit samples, cycling through vals.
+func repeat16(n int, vals ...int16) []int16 {
+	s := make([]int16, n)
+	for i := range s {
+		s[i] = vals[i%len(vals)]
+	}
+	return s
+}
+
+// wavBytes is a 16 bit PCM WAV file of the samples.
+func wavBytes(sampleRate int, channels int, samples []int16) []byte {
+	var b bytes.Buffer
+	writeWAVHeader(&b, sampleRate, channels, 16, int64(len(samples)/channels))
+	b.Write(le16(samples...))
+	return b.Bytes()
+}
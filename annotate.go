@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/soniakeys/meeus/v3/globe"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// annotateTimeColumns are the column names (or JSON fields) tried for the
+// timestamps, in order, if one isn't given with -column.
+var annotateTimeColumns = []string{"time", "timestamp", "datetime", "date", "start", "instant", "utc"}
+
+// annotateTimeFormats are the layouts timestamps can be in, besides Unix
+// seconds. The ones without a zone are in the display time zone.
+var annotateTimeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// annotator works out the CML, Io phase, distance, and source for each
+// timestamp, and Jupiter's altitude and azimuth if there's an observer.
+// StateAt is the planets' stateAt, so it's worked out the same way the
+// forecast does.
+type annotator struct {
+	StateAt  func(t time.Time, observer *globe.Coord) *jovianState
+	Observer *globe.Coord
+	Location *time.Location
+	Column   string
+}
+
+func annotateCommand(args []string) error {
+	flags := flag.NewFlagSet("annotate", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	format := flags.String("format", "auto", "Format of the input: csv, jsonl (JSON lines), lines (a timestamp on each line), or auto to work it out from the file's extension or its first line.")
+	column := flags.String("column", "", "CSV column or JSON field with the timestamps. Defaults to the first of time, timestamp, datetime, date, start, instant, or utc there is, or for CSV, the first column.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise annotate [options] [file]\n\nReads timestamps from a CSV, JSON lines, or plain text file, or stdin if there's no file or it's '-', and writes them back out with the CML, Io phase, Earth-Jupiter distance, and likely radio source at each, and Jupiter's altitude and azimuth with -lat and -lon. Timestamps can be in RFC 3339 format, or Unix seconds; ones without a time zone are in the -timezone, -offset-hours, or -local time zone, or UTC.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return fmt.Errorf("annotate takes at most one file to annotate.")
+	}
+	switch *format {
+	case "auto", "csv", "jsonl", "lines":
+	default:
+		return fmt.Errorf("Input format '%s' is not a valid selection.", *format)
+	}
+	jData, err := fp.jupiterData()
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if flags.NArg() == 1 && flags.Arg(0) != "-" {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+		if *format == "auto" {
+			switch strings.ToLower(filepath.Ext(f.Name())) {
+			case ".csv":
+				*format = "csv"
+			case ".jsonl", ".ndjson", ".json":
+				*format = "jsonl"
+			}
+		}
+	}
+	br := bufio.NewReader(in)
+	if *format == "auto" {
+		*format = sniffAnnotateFormat(br)
+	}
+
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+	a := &annotator{StateAt: p.stateAt, Location: jData.displayLocation(), Column: *column}
+	if jData.LocalForecast {
+		a.Observer = &jData.Coords
+	}
+
+	switch *format {
+	case "csv":
+		return a.annotateCSV(br, os.Stdout)
+	case "jsonl":
+		return a.annotateJSON(br, os.Stdout)
+	}
+	return a.annotateLines(br, os.Stdout)
+}
+
+// sniffAnnotateFormat guesses the input's format from its first line: JSON
+// lines start with '{', CSV has commas, and anything else is taken to be a
+// timestamp on each line.
+func sniffAnnotateFormat(br *bufio.Reader) string {
+	head, _ := br.Peek(4096)
+	head = bytes.TrimLeft(head, " \t\r\n")
+	if len(head) > 0 && head[0] == '{' {
+		return "jsonl"
+	}
+	if i := bytes.IndexByte(head, '\n'); i >= 0 {
+		head = head[:i]
+	}
+	if bytes.IndexByte(head, ',') >= 0 {
+		return "csv"
+	}
+	return "lines"
+}
+
+// columns returns the names of the columns added to each timestamp.
+func (a *annotator) columns() []string {
+	cols := []string{"cml_deg", "io_phase_deg", "distance_au", "radio_source"}
+	if a.Observer != nil {
+		cols = append(cols, "altitude_deg", "azimuth_deg")
+	}
+	return cols
+}
+
+// values returns the state's values for the columns, with numbers to prec
+// decimal places, or as many as they need if prec is -1.
+func (a *annotator) values(st *jovianState, prec int) []string {
+	num := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', prec, 64)
+	}
+	vals := []string{num(st.Meridian.Deg()), num(st.IoPhase.Deg()), num(st.Distance), st.sourceName()}
+	if st.AltAz != nil {
+		vals = append(vals, num(st.AltAz.Altitude.Deg()), num(st.AltAz.Azimuth.Deg()))
+	}
+	return vals
+}
+
+func (a *annotator) state(s string) (*jovianState, error) {
+	t, err := parseAnnotateTime(s, a.Location)
+	if err != nil {
+		return nil, err
+	}
+	return a.StateAt(t, a.Observer), nil
+}
+
+// annotateCSV copies the CSV through, with the columns added to the header
+// and each row.
+func (a *annotator) annotateCSV(r io.Reader, w io.Writer) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return fmt.Errorf("The CSV is empty.")
+	} else if err != nil {
+		return fmt.Errorf("Error reading the CSV header: %s", err)
+	}
+	col := -1
+	names := make(map[string]int)
+	for i, h := range header {
+		names[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if a.Column != "" {
+		i, ok := names[strings.ToLower(a.Column)]
+		if !ok {
+			return fmt.Errorf("The CSV has no '%s' column.", a.Column)
+		}
+		col = i
+	} else {
+		col = 0
+		for _, c := range annotateTimeColumns {
+			if i, ok := names[c]; ok {
+				col = i
+				break
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err = cw.Write(append(header, a.columns()...)); err != nil {
+		return err
+	}
+	for row := 2; ; row++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if col >= len(rec) {
+			return fmt.Errorf("Row %d has no timestamp.", row)
+		}
+		st, err := a.state(rec[col])
+		if err != nil {
+			return fmt.Errorf("Row %d: %s", row, err)
+		}
+		if err = cw.Write(append(rec, a.values(st, -1)...)); err != nil {
+			return err
+		}
+		cw.Flush()
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// annotateJSON copies the JSON lines through, adding the columns as fields
+// at the end of each object. The fields already there are left as they
+// were, except ones named like the columns, from annotating the file
+// before, which are replaced. Blank lines are copied, as annotateLines
+// does.
+func (a *annotator) annotateJSON(r io.Reader, w io.Writer) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	bw := bufio.NewWriter(w)
+	cols := a.columns()
+	for n := 1; s.Scan(); n++ {
+		line := bytes.TrimSpace(s.Bytes())
+		if len(line) == 0 {
+			if err := bw.WriteByte('\n'); err != nil {
+				return err
+			}
+			continue
+		}
+		fields := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(line, &fields); err != nil {
+			return fmt.Errorf("Line %d isn't a JSON object: %s", n, err)
+		}
+		for _, c := range cols {
+			if _, ok := fields[c]; ok {
+				line = dropJSONFields(line, cols)
+				break
+			}
+		}
+		raw, err := a.jsonTime(fields)
+		if err != nil {
+			return fmt.Errorf("Line %d: %s", n, err)
+		}
+		st, err := a.state(raw)
+		if err != nil {
+			return fmt.Errorf("Line %d: %s", n, err)
+		}
+
+		// the object's closing brace is replaced with the new fields
+		out := bytes.TrimRight(bytes.TrimSuffix(line, []byte("}")), " \t")
+		out = append([]byte(nil), out...)
+		for i, v := range a.values(st, -1) {
+			if len(bytes.TrimSpace(out)) > 1 || i > 0 {
+				out = append(out, ',')
+			}
+			val := v
+			if cols[i] == "radio_source" {
+				val = strconv.Quote(v)
+			}
+			out = append(out, fmt.Sprintf("%q:%s", cols[i], val)...)
+		}
+		out = append(out, "}\n"...)
+		if _, err = bw.Write(out); err != nil {
+			return err
+		}
+		if err = bw.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return s.Err()
+}
+
+// dropJSONFields returns the JSON object with the named fields taken out,
+// and the others left as they were, in the same order.
+func dropJSONFields(obj []byte, names []string) []byte {
+	drop := make(map[string]bool, len(names))
+	for _, n := range names {
+		drop[n] = true
+	}
+	dec := json.NewDecoder(bytes.NewReader(obj))
+	if _, err := dec.Token(); err != nil {
+		return obj
+	}
+	out := []byte{'{'}
+	for dec.More() {
+		start := dec.InputOffset()
+		key, err := dec.Token()
+		if err != nil {
+			return obj
+		}
+		var v json.RawMessage
+		if err = dec.Decode(&v); err != nil {
+			return obj
+		}
+		if k, _ := key.(string); drop[k] {
+			continue
+		}
+		// each field after the first starts with the comma before it
+		field := bytes.TrimLeft(obj[start:dec.InputOffset()], " \t,")
+		if len(out) > 1 {
+			out = append(out, ',')
+		}
+		out = append(out, field...)
+	}
+	return append(out, '}')
+}
+
+// jsonTime finds the object's timestamp, which can be a string or a
+// number of Unix seconds.
+func (a *annotator) jsonTime(fields map[string]json.RawMessage) (string, error) {
+	names := annotateTimeColumns
+	if a.Column != "" {
+		names = []string{a.Column}
+	}
+	for _, name := range names {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s, nil
+		}
+		var f json.Number
+		if err := json.Unmarshal(raw, &f); err == nil {
+			return f.String(), nil
+		}
+		return "", fmt.Errorf("The '%s' field isn't a timestamp.", name)
+	}
+	if a.Column != "" {
+		return "", fmt.Errorf("There's no '%s' field.", a.Column)
+	}
+	return "", fmt.Errorf("There's no timestamp field; give its name with -column.")
+}
+
+// annotateLines reads a timestamp from each line, and writes it with the
+// columns after it, separated by tabs. Blank lines and lines starting with
+// '#' are copied as they are.
+func (a *annotator) annotateLines(r io.Reader, w io.Writer) error {
+	s := bufio.NewScanner(r)
+	bw := bufio.NewWriter(w)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			fmt.Fprintln(bw, line)
+			continue
+		}
+		st, err := a.state(line)
+		if err != nil {
+			return fmt.Errorf("Line %d: %s", n, err)
+		}
+		fmt.Fprintf(bw, "%s\t%s\n", line, strings.Join(a.values(st, 2), "\t"))
+		if err = bw.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return s.Err()
+}
+
+// parseAnnotateTime parses a timestamp in one of annotateTimeFormats, or
+// Unix seconds.
+func parseAnnotateTime(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if secs, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(secs, 0) && !math.IsNaN(secs) {
+		whole, frac := math.Modf(secs)
+		return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+	}
+	for _, layout := range annotateTimeFormats {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Can't read '%s' as a time. Times should be in RFC 3339 format, like 2024-01-01T05:00:00Z, or Unix seconds.", s)
+}
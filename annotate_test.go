@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/soniakeys/meeus/v3/globe"
+	"github.com/soniakeys/unit"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAnnotator is an annotator whose CML is 180° plus the timestamp's
+// minute, with Io-B on the hour and nothing otherwise.
+func testAnnotator(observer *globe.Coord, column string) *annotator {
+	stateAt := func(t time.Time, obs *globe.Coord) *jovianState {
+		st := &jovianState{
+			Time:        t,
+			Meridian:    unit.AngleFromDeg(180 + float64(t.Minute())),
+			IoPhase:     unit.AngleFromDeg(90.5),
+			Distance:    4.25,
+			RadioSource: NoEvent,
+		}
+		if t.Minute() == 0 {
+			st.RadioSource = IoB
+		}
+		if obs != nil {
+			st.AltAz = &hzCoords{Altitude: unit.AngleFromDeg(35.5), Azimuth: unit.AngleFromDeg(180)}
+		}
+		return st
+	}
+	return &annotator{StateAt: stateAt, Observer: observer, Location: time.UTC, Column: column}
+}
+
+func TestParseAnnotateTime(t *testing.T) {
+	est := time.FixedZone("EST", -5*3600)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2024-01-01T05:00:00Z", time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)},
+		{"2024-01-01T05:00:00.25-03:00", time.Date(2024, 1, 1, 8, 0, 0, 250000000, time.UTC)},
+		{"2024-01-01 05:00:00+01:00", time.Date(2024, 1, 1, 4, 0, 0, 0, time.UTC)},
+		// times without a zone are in the location given
+		{"2024-01-01T05:00:00", time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{"2024-01-01 05:00:00", time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)},
+		{"2024-01-01T05:30", time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)},
+		{" 2024-01-01 05:30 ", time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)},
+		{"1704085200", time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)},
+		{"1704085200.5", time.Date(2024, 1, 1, 5, 0, 0, 500000000, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseAnnotateTime(tt.in, est)
+		if err != nil {
+			t.Errorf("%q: %s", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("%q read as %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "yesterday", "2024-13-01T05:00:00Z", "NaN", "Inf"} {
+		if got, err := parseAnnotateTime(bad, est); err == nil {
+			t.Errorf("%q read as %s", bad, got)
+		}
+	}
+}
+
+func TestSniffAnnotateFormat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"time": "2024-01-01T05:00:00Z"}`, "jsonl"},
+		{"\n  \n\t{\"time\": 1704085200}\n", "jsonl"},
+		{"time,freq\n2024-01-01T05:00:00Z,20.1\n", "csv"},
+		// commas after the first line don't make it CSV
+		{"2024-01-01T05:00:00Z\n2024-01-01T06:00:00Z, again\n", "lines"},
+		{"1704085200\n", "lines"},
+		{"", "lines"},
+	}
+	for _, tt := range tests {
+		if got := sniffAnnotateFormat(bufio.NewReader(strings.NewReader(tt.in))); got != tt.want {
+			t.Errorf("%q sniffed as %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAnnotateCSV(t *testing.T) {
+	observer := &globe.Coord{}
+	tests := []struct {
+		name     string
+		observer *globe.Coord
+		column   string
+		in       string
+		want     string
+	}{
+		{
+			"time column found",
+			nil,
+			"",
+			"freq,Time\n20.1,2024-01-01T05:00:00Z\n\"19,9\",2024-01-01T05:30:00Z\n",
+			"freq,Time,cml_deg,io_phase_deg,distance_au,radio_source\n20.1,2024-01-01T05:00:00Z,180,90.5,4.25,Io-B\n\"19,9\",2024-01-01T05:30:00Z,210,90.5,4.25,none\n",
+		},
+		{
+			"first column",
+			observer,
+			"",
+			"when,freq\n1704085200,20.1\n",
+			"when,freq,cml_deg,io_phase_deg,distance_au,radio_source,altitude_deg,azimuth_deg\n1704085200,20.1,180,90.5,4.25,Io-B,35.5,180\n",
+		},
+		{
+			"column given",
+			nil,
+			"Seen",
+			"time,seen\nnot a time,2024-01-01T05:15:00Z\n",
+			"time,seen,cml_deg,io_phase_deg,distance_au,radio_source\nnot a time,2024-01-01T05:15:00Z,195,90.5,4.25,none\n",
+		},
+		{"header only", nil, "", "time,freq\n", "time,freq,cml_deg,io_phase_deg,distance_au,radio_source\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := testAnnotator(tt.observer, tt.column).annotateCSV(strings.NewReader(tt.in), &out); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, out.String(), tt.want)
+		}
+	}
+
+	errs := []struct {
+		name   string
+		column string
+		in     string
+	}{
+		{"empty", "", ""},
+		{"no such column", "when", "time,freq\n2024-01-01T05:00:00Z,20.1\n"},
+		{"bad time", "", "time\nyesterday\n"},
+		{"short row", "seen", "freq,seen\n20.1\n"},
+	}
+	for _, tt := range errs {
+		var out bytes.Buffer
+		if err := testAnnotator(nil, tt.column).annotateCSV(strings.NewReader(tt.in), &out); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestAnnotateJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		column string
+		in     string
+		want   string
+	}{
+		{
+			"string and number times",
+			"",
+			"{\"time\": \"2024-01-01T05:00:00Z\", \"freq\": 20.1}\n{\"utc\":1704087000}\n",
+			"{\"time\": \"2024-01-01T05:00:00Z\", \"freq\": 20.1,\"cml_deg\":180,\"io_phase_deg\":90.5,\"distance_au\":4.25,\"radio_source\":\"Io-B\"}\n{\"utc\":1704087000,\"cml_deg\":210,\"io_phase_deg\":90.5,\"distance_au\":4.25,\"radio_source\":\"none\"}\n",
+		},
+		{
+			"blank lines kept",
+			"",
+			"\n{\"time\":\"2024-01-01T05:00:00Z\"}\n  \n",
+			"\n{\"time\":\"2024-01-01T05:00:00Z\",\"cml_deg\":180,\"io_phase_deg\":90.5,\"distance_au\":4.25,\"radio_source\":\"Io-B\"}\n\n",
+		},
+		{
+			"column given",
+			"seen",
+			"{\"time\":\"not a time\",\"seen\":\"2024-01-01T05:15:00Z\",\"note\":{\"a\":[1,2]}}\n",
+			"{\"time\":\"not a time\",\"seen\":\"2024-01-01T05:15:00Z\",\"note\":{\"a\":[1,2]},\"cml_deg\":195,\"io_phase_deg\":90.5,\"distance_au\":4.25,\"radio_source\":\"none\"}\n",
+		},
+		{
+			// the old values are replaced, and the other fields kept in
+			// order
+			"annotated before",
+			"",
+			"{\"cml_deg\":1, \"time\": \"2024-01-01T05:00:00Z\",\"radio_source\":\"Io-A\", \"freq\": 20.1,\"distance_au\":5}\n",
+			"{\"time\": \"2024-01-01T05:00:00Z\",\"freq\": 20.1,\"cml_deg\":180,\"io_phase_deg\":90.5,\"distance_au\":4.25,\"radio_source\":\"Io-B\"}\n",
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := testAnnotator(nil, tt.column).annotateJSON(strings.NewReader(tt.in), &out); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, out.String(), tt.want)
+		}
+	}
+
+	// annotating the output again changes nothing
+	in := "{\"time\":\"2024-01-01T05:45:00Z\",\"freq\":20.1}\n\n{\"time\":1704085200}\n"
+	a := testAnnotator(&globe.Coord{}, "")
+	var once, twice bytes.Buffer
+	if err := a.annotateJSON(strings.NewReader(in), &once); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.annotateJSON(bytes.NewReader(once.Bytes()), &twice); err != nil {
+		t.Fatal(err)
+	}
+	if once.String() != twice.String() {
+		t.Errorf("annotating twice gave\n%s\nnot\n%s", twice.String(), once.String())
+	}
+
+	errs := []struct {
+		name   string
+		column string
+		in     string
+	}{
+		{"not an object", "", "[1, 2]\n"},
+		{"no time field", "", "{\"freq\": 20.1}\n"},
+		{"no such field", "seen", "{\"time\": \"2024-01-01T05:00:00Z\"}\n"},
+		{"time isn't a timestamp", "", "{\"time\": true}\n"},
+		{"bad time", "", "{\"time\": \"yesterday\"}\n"},
+	}
+	for _, tt := range errs {
+		var out bytes.Buffer
+		if err := testAnnotator(nil, tt.column).annotateJSON(strings.NewReader(tt.in), &out); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
+
+func TestAnnotateLines(t *testing.T) {
+	in := "# when\n2024-01-01T05:00:00Z\n\n  1704087000  \n"
+	want := "# when\n2024-01-01T05:00:00Z\t180.00\t90.50\t4.25\tIo-B\n\n1704087000\t210.00\t90.50\t4.25\tnone\n"
+	var out bytes.Buffer
+	if err := testAnnotator(nil, "").annotateLines(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	want = "2024-01-01T05:00:00Z\t180.00\t90.50\t4.25\tIo-B\t35.50\t180.00\n"
+	if err := testAnnotator(&globe.Coord{}, "").annotateLines(strings.NewReader("2024-01-01T05:00:00Z\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Errorf("with an observer, got\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := testAnnotator(nil, "").annotateLines(strings.NewReader("2024-01-01T05:00:00Z\nyesterday\n"), &out); err == nil {
+		t.Error("no error for a line that isn't a time")
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// command is a subcommand of jovian-noise, like 'jovian-noise diff'. It gets
+// the command line arguments after the subcommand's name.
+type command struct {
+	Run         func(args []string) error
+	Description string
+}
+
+var commands = map[string]*command{
+	"analyze":     {analyzeCommand, "Find bursts of noise in a WAV or I/Q recording, and compare them with the forecast."},
+	"annotate":    {annotateCommand, "Add the CML, Io phase, distance, and source to timestamps from CSV, JSON lines, or text."},
+	"digest":      {digestCommand, "Print or email a digest of the coming week's forecast windows."},
+	"diff":        {diffCommand, "Compare two forecasts saved with '-output json'."},
+	"grpc":        {grpcCommand, "Serve forecasts over gRPC."},
+	"log":         {logCommand, "Add, import, list, or remove observations in the observation log."},
+	"metrics":     {metricsCommand, "Serve the current state and time to the next windows as Prometheus metrics."},
+	"mqtt":        {mqttCommand, "Publish the current state and window events to an MQTT broker."},
+	"record":      {recordCommand, "Run until stopped, recording IQ samples from rtl_tcp during each window."},
+	"rig":         {rigCommand, "Run until stopped, tuning the receiver through rigctld at the start of each window."},
+	"serve":       {serveCommand, "Serve forecasts over HTTP."},
+	"spectrogram": {spectrogramCommand, "Draw a WAV or I/Q recording as a PNG waterfall, marked with the forecast windows."},
+	"synthesize":  {synthesizeCommand, "Write a WAV file of simulated Jovian emission, timed to a forecast window."},
+	"track":       {trackCommand, "Run until stopped, pointing the antenna at Jupiter through rotctld during each window."},
+	"verify":      {verifyCommand, "Score the forecast against the detections in the observation log."},
+	"watch":       {watchCommand, "Run until stopped, sending alerts before forecast windows open and close."},
+}
+
+// runCommand runs the subcommand named in the command line arguments, if
+// there is one. It returns false if the arguments don't start with a
+// subcommand, in which case the usual forecast should be run.
+func runCommand(args []string) (bool, error) {
+	if len(args) < 1 {
+		return false, nil
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return false, nil
+	}
+	return true, cmd.Run(args[1:])
+}
+
+// commandUsage describes the available subcommands, for the main usage
+// message.
+func commandUsage() string {
+	names := make([]string, 0, len(commands))
+	for k := range commands {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Commands (run 'jovian-noise <command> -h' for their options):\n")
+	for _, n := range names {
+		fmt.Fprintf(&b, "  %s\n        %s\n", n, commands[n].Description)
+	}
+	return b.String()
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+type windowChangeKind string
+
+const (
+	windowAdded   windowChangeKind = "added"
+	windowRemoved windowChangeKind = "removed"
+	windowShifted windowChangeKind = "shifted"
+)
+
+// windowChange is one difference between the windows of two forecasts. Old
+// is nil for added windows, and New is nil for removed windows.
+type windowChange struct {
+	Kind        windowChangeKind
+	RadioSource radioSource
+	Old         *forecastWindow
+	New         *forecastWindow
+}
+
+type jsonWindowChange struct {
+	Change      windowChangeKind `json:"change"`
+	RadioSource radioSource      `json:"radio_source"`
+	OldStart    *time.Time       `json:"old_start,omitempty"`
+	OldEnd      *time.Time       `json:"old_end,omitempty"`
+	NewStart    *time.Time       `json:"new_start,omitempty"`
+	NewEnd      *time.Time       `json:"new_end,omitempty"`
+	StartDelta  string           `json:"start_delta,omitempty"`
+	EndDelta    string           `json:"end_delta,omitempty"`
+}
+
+func (wc *windowChange) deltas() (time.Duration, time.Duration) {
+	if wc.Old == nil || wc.New == nil {
+		return 0, 0
+	}
+	return wc.New.Start.Sub(wc.Old.Start), wc.New.End.Sub(wc.Old.End)
+}
+
+func diffCommand(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	threshold := flags.Int("threshold", 0, "Ignore windows whose start and end have both moved by less than this many minutes.")
+	output := flags.String("output", "text", "How to format the differences. Currently acceptable options are: text (default), json.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise diff [options] <old.json> <new.json>\n\nReports forecast windows that were added, removed, or shifted between two forecasts saved with '-output json'.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("diff needs exactly two forecasts to compare.")
+	}
+	if *threshold < 0 {
+		return fmt.Errorf("-threshold can't be negative.")
+	}
+
+	oldData, err := loadForecast(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	newData, err := loadForecast(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	changes := diffWindows(oldData.Windows(), newData.Windows(), time.Duration(*threshold)*time.Minute)
+
+	switch *output {
+	case "text":
+		return outputDiffText(flags.Arg(0), oldData, flags.Arg(1), newData, changes)
+	case "json":
+		return outputDiffJSON(changes)
+	default:
+		return fmt.Errorf("Output format '%s' is not a valid selection.", *output)
+	}
+}
+
+// diffWindows matches up the windows of two forecasts. Windows match if they
+// have the same radio source and either overlap or start within a window's
+// length of each other; the closest matches are paired off first. Matched
+// windows whose start or end moved by at least the threshold are shifted,
+// and any windows left over were added or removed.
+func diffWindows(oldWindows []*forecastWindow, newWindows []*forecastWindow, threshold time.Duration) []*windowChange {
+	type candidate struct {
+		o, n  int
+		score time.Duration
+	}
+	candidates := make([]candidate, 0)
+	for i, ow := range oldWindows {
+		for j, nw := range newWindows {
+			if ow.RadioSource != nw.RadioSource {
+				continue
+			}
+			startDelta := absDuration(nw.Start.Sub(ow.Start))
+			endDelta := absDuration(nw.End.Sub(ow.End))
+			maxLen := ow.Duration()
+			if nw.Duration() > maxLen {
+				maxLen = nw.Duration()
+			}
+			if !(timeSpan{ow.Start, ow.End}).overlaps(timeSpan{nw.Start, nw.End}) && startDelta > maxLen {
+				continue
+			}
+			candidates = append(candidates, candidate{i, j, startDelta + endDelta})
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].score < candidates[b].score
+	})
+
+	oldMatched := make([]bool, len(oldWindows))
+	newMatched := make([]bool, len(newWindows))
+	changes := make([]*windowChange, 0)
+	for _, c := range candidates {
+		if oldMatched[c.o] || newMatched[c.n] {
+			continue
+		}
+		oldMatched[c.o] = true
+		newMatched[c.n] = true
+		wc := &windowChange{Kind: windowShifted, RadioSource: oldWindows[c.o].RadioSource, Old: oldWindows[c.o], New: newWindows[c.n]}
+		startDelta, endDelta := wc.deltas()
+		if absDuration(startDelta) >= threshold && startDelta != 0 || absDuration(endDelta) >= threshold && endDelta != 0 {
+			changes = append(changes, wc)
+		}
+	}
+	for i, ow := range oldWindows {
+		if !oldMatched[i] {
+			changes = append(changes, &windowChange{Kind: windowRemoved, RadioSource: ow.RadioSource, Old: ow})
+		}
+	}
+	for j, nw := range newWindows {
+		if !newMatched[j] {
+			changes = append(changes, &windowChange{Kind: windowAdded, RadioSource: nw.RadioSource, New: nw})
+		}
+	}
+
+	sort.SliceStable(changes, func(a, b int) bool {
+		return changes[a].when().Before(changes[b].when())
+	})
+	return changes
+}
+
+// when returns the time to sort a change by.
+func (wc *windowChange) when() time.Time {
+	if wc.Old != nil {
+		return wc.Old.Start
+	}
+	return wc.New.Start
+}
+
+func outputDiffText(oldPath string, oldData *jupiterData, newPath string, newData *jupiterData, changes []*windowChange) error {
+	loc := newData.displayLocation()
+	describe := func(path string, jData *jupiterData) string {
+		d := fmt.Sprintf("%s (%s until %s", path, jData.StartTime.In(loc).Format(htmlTimeFormat), jData.EndTime.In(loc).Format(htmlTimeFormat))
+		if jData.Provenance != nil {
+			d += fmt.Sprintf(", %s %s", jData.Provenance.Program, jData.Provenance.Version)
+		}
+		return d + ")"
+	}
+	fmt.Printf("--- %s\n+++ %s\n", describe(oldPath, oldData), describe(newPath, newData))
+
+	if len(changes) == 0 {
+		fmt.Printf("No windows changed.\n")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Change\tSrc\tOld Start\tOld End\tNew Start\tNew End\tStart Δ\tEnd Δ\t\n")
+	fmt.Fprintf(w, "------\t---\t---------\t-------\t---------\t-------\t-------\t-----\t\n")
+	fmtTime := func(fw *forecastWindow, start bool) string {
+		if fw == nil {
+			return "-"
+		}
+		if start {
+			return fw.Start.In(loc).Format("Jan 02 15:04")
+		}
+		return fw.End.In(loc).Format("Jan 02 15:04")
+	}
+	var counts = make(map[windowChangeKind]int)
+	for _, wc := range changes {
+		counts[wc.Kind]++
+		startDelta, endDelta := "-", "-"
+		if wc.Kind == windowShifted {
+			s, e := wc.deltas()
+			startDelta, endDelta = signedDuration(s), signedDuration(e)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", wc.Kind, wc.RadioSource, fmtTime(wc.Old, true), fmtTime(wc.Old, false), fmtTime(wc.New, true), fmtTime(wc.New, false), startDelta, endDelta)
+	}
+	w.Flush()
+	fmt.Printf("\n%d added, %d removed, %d shifted.\n", counts[windowAdded], counts[windowRemoved], counts[windowShifted])
+	return nil
+}
+
+func outputDiffJSON(changes []*windowChange) error {
+	jc := make([]*jsonWindowChange, 0, len(changes))
+	for _, wc := range changes {
+		c := &jsonWindowChange{Change: wc.Kind, RadioSource: wc.RadioSource}
+		if wc.Old != nil {
+			c.OldStart, c.OldEnd = &wc.Old.Start, &wc.Old.End
+		}
+		if wc.New != nil {
+			c.NewStart, c.NewEnd = &wc.New.Start, &wc.New.End
+		}
+		if wc.Kind == windowShifted {
+			s, e := wc.deltas()
+			c.StartDelta, c.EndDelta = isoDuration(s), isoDuration(e)
+		}
+		jc = append(jc, c)
+	}
+	j, err := json.MarshalIndent(jc, "", "\t")
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(j)
+	return nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// signedDuration formats a duration with a sign, even when it's positive.
+func signedDuration(d time.Duration) string {
+	if d > 0 {
+		return "+" + d.String()
+	}
+	return d.String()
+}
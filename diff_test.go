@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffWindowsThreshold(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := func(rs radioSource, startMin int, endMin int) *forecastWindow {
+		return &forecastWindow{
+			Start:       base.Add(time.Duration(startMin) * time.Minute),
+			End:         base.Add(time.Duration(endMin) * time.Minute),
+			RadioSource: rs,
+		}
+	}
+	old := []*forecastWindow{window(IoA, 60, 180)}
+
+	tests := []struct {
+		name      string
+		new       *forecastWindow
+		threshold time.Duration
+		want      []windowChangeKind
+	}{
+		{"unchanged", window(IoA, 60, 180), 0, nil},
+		{"any shift with no threshold", window(IoA, 90, 180), 0, []windowChangeKind{windowShifted}},
+		{"start moved less than threshold", window(IoA, 90, 180), time.Hour, nil},
+		{"start moved by exactly threshold", window(IoA, 120, 180), time.Hour, []windowChangeKind{windowShifted}},
+		{"end moved past threshold", window(IoA, 60, 270), time.Hour, []windowChangeKind{windowShifted}},
+		{"both moved less than threshold", window(IoA, 90, 210), time.Hour, nil},
+		{"earlier start past threshold", window(IoA, 0, 180), 30 * time.Minute, []windowChangeKind{windowShifted}},
+		{"different source", window(IoB, 60, 180), time.Hour, []windowChangeKind{windowRemoved, windowAdded}},
+		{"too far away to match", window(IoA, 600, 720), time.Hour, []windowChangeKind{windowRemoved, windowAdded}},
+	}
+	for _, tt := range tests {
+		changes := diffWindows(old, []*forecastWindow{tt.new}, tt.threshold)
+		if len(changes) != len(tt.want) {
+			t.Errorf("%s: got %d changes, want %d", tt.name, len(changes), len(tt.want))
+			continue
+		}
+		for i, wc := range changes {
+			if wc.Kind != tt.want[i] {
+				t.Errorf("%s: change %d is %s, want %s", tt.name, i, wc.Kind, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestDiffWindowsPairsClosest(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time {
+		return base.Add(time.Duration(m) * time.Minute)
+	}
+	oldWindows := []*forecastWindow{
+		{Start: at(0), End: at(120), RadioSource: IoB},
+	}
+	newWindows := []*forecastWindow{
+		{Start: at(60), End: at(150), RadioSource: IoB},
+		{Start: at(30), End: at(120), RadioSource: IoB},
+	}
+	changes := diffWindows(oldWindows, newWindows, 0)
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2", len(changes))
+	}
+	if changes[0].Kind != windowShifted || changes[0].New != newWindows[1] {
+		t.Errorf("the old window should have been paired with the closest new one")
+	}
+	if changes[1].Kind != windowAdded || changes[1].New != newWindows[0] {
+		t.Errorf("the other new window should have been added")
+	}
+}
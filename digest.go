@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const digestDuration = 7 * oneDay
+
+var digestTemplate = `
+Jovian decameter radio storm forecast for {{.Start.Format "Mon Jan 02"}} until {{.End.Format "Mon Jan 02 2006"}}
+{{- if .Local}}, for coordinates {{.Lat}}º, {{.Lon}}º{{end}}.
+Times are {{if .Location}}{{.Location}} ({{.Offset}}){{else}}UTC{{end}}. Recommended windows are the ones close to Jupiter's transit.
+{{range .Nights}}
+== Night of {{.Start.Format "Mon Jan 02"}} ==
+{{if .Windows -}}
+{{range .Windows}}{{.RadioSource}} {{(local .Start).Format "15:04"}} - {{(local .End).Format "15:04"}}{{if .Recommended}} (recommended){{end}}
+{{end}}
+{{.Table}}
+{{else -}}
+No windows.
+{{end -}}
+{{end}}`
+
+// digestNight is one local night of the digest.
+type digestNight struct {
+	Start   time.Time
+	Windows []*forecastWindow
+	Table   string
+}
+
+type digestOutput struct {
+	textOutput
+	Nights []*digestNight
+}
+
+func digestCommand(args []string) error {
+	flags := flag.NewFlagSet("digest", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	smtpFile := flags.String("smtp-config", "", "Path to a JSON file of SMTP settings to email the digest with. Without it, the digest is printed.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise digest [options]\n\nSummarizes the coming week's forecast windows, grouped by local night, and emails it (meant to be run weekly from cron) or prints it. -duration defaults to a week here.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("digest doesn't take any arguments.")
+	}
+	durationSet := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "duration" {
+			durationSet = true
+		}
+	})
+	if !durationSet {
+		fp.Duration = digestDuration
+	}
+
+	var sc *smtpConfig
+	if *smtpFile != "" {
+		var err error
+		if sc, err = loadSMTPConfig(*smtpFile); err != nil {
+			return err
+		}
+	}
+
+	jData, err := fp.jupiterData()
+	if err != nil {
+		return err
+	}
+	earth, jupiter, err := loadPlanets()
+	if err != nil {
+		return err
+	}
+	if err = calculateForecast(jData, earth, jupiter); err != nil {
+		return err
+	}
+
+	body, err := renderDigest(jData)
+	if err != nil {
+		return err
+	}
+	if sc == nil {
+		fmt.Print(body)
+		return nil
+	}
+
+	loc := jData.displayLocation()
+	subject := fmt.Sprintf("Jupiter radio storm forecast for the week of %s", jData.StartTime.In(loc).Format("Jan 02"))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = sc.send(ctx, subject, body); err != nil {
+		return err
+	}
+	log.Printf("Sent digest to %s.", strings.Join(sc.To, ", "))
+	return nil
+}
+
+// renderDigest formats the forecast as the weekly digest, with each local
+// night's windows and the same table of intervals as the text output.
+func renderDigest(jData *jupiterData) (string, error) {
+	loc := jData.displayLocation()
+	tmpl, err := template.New("digest").Funcs(templateFuncs(jData)).Parse(strings.TrimPrefix(digestTemplate, "\n"))
+	if err != nil {
+		return "", err
+	}
+
+	out := new(digestOutput)
+	out.Start = jData.StartTime.In(loc)
+	out.End = jData.EndTime.In(loc)
+	out.Lat, out.Lon = jData.displayCoords()
+	out.Local = jData.LocalForecast
+	out.Location, out.Offset = jData.zoneInfo()
+
+	windows := jData.Windows()
+	for _, night := range localNights(jData.StartTime, jData.EndTime, loc) {
+		dn := &digestNight{Start: night.Start}
+		intervals := make([]*forecastInterval, 0)
+		for _, fw := range windows {
+			if !fw.Start.Before(night.Start) && fw.Start.Before(night.End) {
+				dn.Windows = append(dn.Windows, fw)
+				intervals = append(intervals, fw.Intervals...)
+			}
+		}
+		if len(intervals) > 0 {
+			dn.Table = intervalTable(jData, intervals)
+		}
+		out.Nights = append(out.Nights, dn)
+	}
+
+	var b bytes.Buffer
+	if err = tmpl.Execute(&b, out); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// smtpConfig is how to send email. The password can be given directly, or
+// (better) in an environment variable named by PasswordEnv. With TLS set,
+// the connection uses TLS from the start, as on port 465; otherwise STARTTLS
+// is used if the server offers it.
+type smtpConfig struct {
+	Host        string   `json:"host"`
+	Port        int      `json:"port,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	Password    string   `json:"password,omitempty"`
+	PasswordEnv string   `json:"password_env,omitempty"`
+	TLS         bool     `json:"tls,omitempty"`
+	From        string   `json:"from"`
+	To          []string `json:"to"`
+}
+
+const defaultSMTPPort = 587
+
+// loadSMTPConfig reads the SMTP settings from a JSON file, like:
+//
+//	{"host": "smtp.example.com", "username": "jupiter", "password_env": "SMTP_PASSWORD",
+//	 "from": "jupiter@example.com", "to": ["volunteers@example.com"]}
+func loadSMTPConfig(path string) (*smtpConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc := new(smtpConfig)
+	if err = json.Unmarshal(data, sc); err != nil {
+		return nil, fmt.Errorf("Error reading SMTP settings from %s: %s", path, err)
+	}
+	if sc.Host == "" {
+		return nil, fmt.Errorf("The SMTP settings in %s need a host.", path)
+	}
+	if sc.From == "" || len(sc.To) == 0 {
+		return nil, fmt.Errorf("The SMTP settings in %s need 'from' and 'to' addresses.", path)
+	}
+	if sc.Port == 0 {
+		sc.Port = defaultSMTPPort
+	}
+	if sc.PasswordEnv != "" {
+		sc.Password = os.Getenv(sc.PasswordEnv)
+	}
+	return sc, nil
+}
+
+func (sc *smtpConfig) addr() string {
+	return net.JoinHostPort(sc.Host, strconv.Itoa(sc.Port))
+}
+
+// message puts together an email with the given subject and plain text body.
+func (sc *smtpConfig) message(subject string, body string) []byte {
+	var b bytes.Buffer
+	id := make([]byte, 12)
+	rand.Read(id)
+	headers := [][2]string{
+		{"From", sc.From},
+		{"To", strings.Join(sc.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@jovian-noise>", hex.EncodeToString(id))},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "8bit"},
+		{"X-Mailer", "jovian-noise " + version},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&b, "%s: %s\r\n", h[0], h[1])
+	}
+	b.WriteString("\r\n")
+	// SMTP wants CRLF line endings
+	body = strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+	b.WriteString(body)
+	if !strings.HasSuffix(body, "\r\n") {
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
+
+// send sends an email to everyone in the To list.
+func (sc *smtpConfig) send(ctx context.Context, subject string, body string) error {
+	msg := sc.message(subject, body)
+
+	d := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if sc.TLS {
+		conn, err = (&tls.Dialer{NetDialer: d, Config: &tls.Config{ServerName: sc.Host}}).DialContext(ctx, "tcp", sc.addr())
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", sc.addr())
+	}
+	if err != nil {
+		return fmt.Errorf("Error connecting to SMTP server %s: %s", sc.addr(), err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, sc.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if !sc.TLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(&tls.Config{ServerName: sc.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if sc.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", sc.Username, sc.Password, sc.Host)); err != nil {
+			return fmt.Errorf("Error logging in to SMTP server %s: %s", sc.addr(), err)
+		}
+	}
+	if err = c.Mail(sc.From); err != nil {
+		return err
+	}
+	for _, to := range sc.To {
+		if err = c.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP server wouldn't take recipient %s: %s", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailNotifier emails an alert before each recommended window opens, at
+// the longest lead time only, so there's one email per window however many
+// other alerts there are. Windows that aren't recommended, and windows
+// closing, are left alone.
+type emailNotifier struct {
+	config *smtpConfig
+	loc    *time.Location
+	lead   time.Duration
+}
+
+func (n *emailNotifier) Notify(ctx context.Context, ev *windowEvent) error {
+	if ev.Kind != windowOpens || ev.Lead != n.lead || !ev.Window.Recommended() {
+		return nil
+	}
+	text := alertText(ev, n.loc)
+	fw := ev.Window
+	subject := fmt.Sprintf("Jupiter %s storm window at %s", fw.RadioSource, fw.Start.In(n.loc).Format("Jan 02 15:04 MST"))
+	body := fmt.Sprintf("%s.\n\nJupiter is near its transit during this %s window, making it a good one to listen for. Try between 18 and 23 MHz.\n", text, fw.Duration())
+	if err := n.config.send(ctx, subject, body); err != nil {
+		return fmt.Errorf("Error sending alert email: %s", err)
+	}
+	return nil
+}
+
+func (n *emailNotifier) Close() error {
+	return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"github.com/soniakeys/unit"
+	"io"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPMessage is one email taken by fakeSMTP.
+type fakeSMTPMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTP is just enough of an SMTP server to take mail, without TLS or
+// authentication.
+type fakeSMTP struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []*fakeSMTPMessage
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &fakeSMTP{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fs.serve(conn)
+		}
+	}()
+	return fs
+}
+
+// config returns SMTP settings for sending to the fake server.
+func (fs *fakeSMTP) config(to ...string) *smtpConfig {
+	host, port, _ := net.SplitHostPort(fs.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return &smtpConfig{Host: host, Port: p, From: "jupiter@example.com", To: to}
+}
+
+func (fs *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) {
+		io.WriteString(conn, s+"\r\n")
+	}
+	reply("220 fake ESMTP")
+	msg := new(fakeSMTPMessage)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 fake")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg.From = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.String()
+			fs.mu.Lock()
+			fs.messages = append(fs.messages, msg)
+			fs.mu.Unlock()
+			msg = new(fakeSMTPMessage)
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (fs *fakeSMTP) sent() []*fakeSMTPMessage {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return append([]*fakeSMTPMessage(nil), fs.messages...)
+}
+
+func readTestMail(t *testing.T, m *fakeSMTPMessage) (*mail.Message, string) {
+	msg, err := mail.ReadMessage(strings.NewReader(m.Data))
+	if err != nil {
+		t.Fatalf("couldn't read the email: %s", err)
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg, strings.ReplaceAll(string(body), "\r\n", "\n")
+}
+
+func TestSendDigest(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	jData := &jupiterData{
+		StartTime:     start,
+		EndTime:       start.Add(oneDay - time.Second),
+		Duration:      oneDay,
+		Interval:      30,
+		LocalForecast: true,
+		Coords:        coordsFromDeg(40, -105),
+		Sources:       []radioSource{IoA, IoB, IoC},
+	}
+	for i, rs := range []radioSource{IoB, IoB, IoA} {
+		ha := 0.0
+		if rs == IoA {
+			ha = 5
+		}
+		jData.Intervals = append(jData.Intervals, &forecastInterval{
+			Instant:     start.Add(2*time.Hour + time.Duration(i)*30*time.Minute),
+			RadioSource: rs,
+			TransitHA:   unit.HourAngleFromHour(ha),
+			AltAz:       &hzCoords{Altitude: unit.AngleFromDeg(30), Azimuth: unit.AngleFromDeg(180)},
+		})
+	}
+
+	body, err := renderDigest(jData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newFakeSMTP(t)
+	sc := srv.config("one@example.com", "two@example.com")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = sc.send(ctx, "Jupiter radio storm forecast", body); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := srv.sent()
+	if len(sent) != 1 {
+		t.Fatalf("got %d emails, want 1", len(sent))
+	}
+	if sent[0].From != sc.From {
+		t.Errorf("sent from %q, want %q", sent[0].From, sc.From)
+	}
+	if strings.Join(sent[0].To, ",") != "one@example.com,two@example.com" {
+		t.Errorf("sent to %v, want both recipients", sent[0].To)
+	}
+	msg, got := readTestMail(t, sent[0])
+	if to := msg.Header.Get("To"); to != "one@example.com, two@example.com" {
+		t.Errorf("To header is %q", to)
+	}
+	for _, want := range []string{
+		"== Night of Sun Dec 31 ==",
+		"Io-B 02:00 - 03:00 (recommended)",
+		"Io-A 03:00 - 03:30\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("digest doesn't have %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestEmailNotifierOnlyRecommendedAtLongestLead(t *testing.T) {
+	notRecommended := testWindowEvent(windowOpens, 30*time.Minute)
+	for _, fi := range notRecommended.Window.Intervals {
+		fi.TransitHA = unit.HourAngleFromHour(5)
+	}
+	noObserver := testWindowEvent(windowOpens, 30*time.Minute)
+	for _, fi := range noObserver.Window.Intervals {
+		fi.AltAz = nil
+	}
+	tests := []struct {
+		name   string
+		ev     *windowEvent
+		mailed bool
+	}{
+		{"lead alert for a recommended window", testWindowEvent(windowOpens, 30*time.Minute), true},
+		{"shorter lead alert for a recommended window", testWindowEvent(windowOpens, 0), false},
+		{"recommended window closing", testWindowEvent(windowCloses, 30*time.Minute), false},
+		{"window far from transit", notRecommended, false},
+		{"forecast without an observer", noObserver, false},
+	}
+	for _, tt := range tests {
+		srv := newFakeSMTP(t)
+		n := &emailNotifier{config: srv.config("observer@example.com"), loc: time.UTC, lead: 30 * time.Minute}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := n.Notify(ctx, tt.ev)
+		cancel()
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		sent := srv.sent()
+		if !tt.mailed {
+			if len(sent) != 0 {
+				t.Errorf("%s: got %d emails, want none", tt.name, len(sent))
+			}
+			continue
+		}
+		if len(sent) != 1 {
+			t.Errorf("%s: got %d emails, want 1", tt.name, len(sent))
+			continue
+		}
+		if len(sent[0].To) != 1 || sent[0].To[0] != "observer@example.com" {
+			t.Errorf("%s: sent to %v", tt.name, sent[0].To)
+		}
+		msg, body := readTestMail(t, sent[0])
+		if subj := msg.Header.Get("Subject"); !strings.Contains(subj, "Io-B") {
+			t.Errorf("%s: subject %q doesn't name the radio source", tt.name, subj)
+		}
+		if !strings.Contains(body, "Io-B") {
+			t.Errorf("%s: body doesn't name the radio source:\n%s", tt.name, body)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/soniakeys/unit"
+	"time"
+)
+
+// forecastWindow is a run of consecutive forecast intervals that all share the
+// same radio source, merged into one span of time.
+type forecastWindow struct {
+	Start       time.Time
+	End         time.Time
+	RadioSource radioSource
+	Intervals   []*forecastInterval
+}
+
+// Windows merges the forecast's intervals into windows. Intervals are merged
+// when they are exactly one forecast interval apart and have the same radio
+// source. A window ends one forecast interval after its last sample.
+func (jd *jupiterData) Windows() []*forecastWindow {
+	windows := make([]*forecastWindow, 0)
+	step := time.Duration(jd.Interval) * time.Minute
+
+	var cur *forecastWindow
+	for _, fi := range jd.Intervals {
+		if cur != nil && cur.RadioSource == fi.RadioSource && fi.Instant.Equal(cur.End) {
+			cur.End = fi.Instant.Add(step)
+			cur.Intervals = append(cur.Intervals, fi)
+			continue
+		}
+		cur = &forecastWindow{Start: fi.Instant, End: fi.Instant.Add(step), RadioSource: fi.RadioSource, Intervals: []*forecastInterval{fi}}
+		windows = append(windows, cur)
+	}
+
+	return windows
+}
+
+func (fw *forecastWindow) Duration() time.Duration {
+	return fw.End.Sub(fw.Start)
+}
+
+// Recommended returns true if any of the window's intervals are recommended.
+func (fw *forecastWindow) Recommended() bool {
+	for _, fi := range fw.Intervals {
+		if fi.Recommended() {
+			return true
+		}
+	}
+	return false
+}
+
+// PeakAltitude returns Jupiter's highest altitude during the window. The
+// second return value is false if the forecast was not for a particular
+// location.
+func (fw *forecastWindow) PeakAltitude() (unit.Angle, bool) {
+	var peak unit.Angle
+	var found bool
+	for _, fi := range fw.Intervals {
+		if fi.AltAz == nil {
+			continue
+		}
+		if !found || fi.AltAz.Altitude > peak {
+			peak = fi.AltAz.Altitude
+			found = true
+		}
+	}
+	return peak, found
+}
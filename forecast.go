@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/soniakeys/meeus/v3/coord"
+	"github.com/soniakeys/meeus/v3/elliptic"
+	"github.com/soniakeys/meeus/v3/julian"
+	pp "github.com/soniakeys/meeus/v3/planetposition"
+	"github.com/soniakeys/meeus/v3/rise"
+	"github.com/soniakeys/meeus/v3/sidereal"
+	"github.com/soniakeys/unit"
+	"math"
+	"os"
+	"time"
+)
+
+// loadPlanets loads the VSOP87 data for Earth and Jupiter from the directory
+// in the VSOP87 environment variable.
+func loadPlanets() (*pp.V87Planet, *pp.V87Planet, error) {
+	earth, err := pp.LoadPlanet(pp.Earth)
+	if err != nil {
+		return nil, nil, err
+	}
+	jupiter, err := pp.LoadPlanet(pp.Jupiter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return earth, jupiter, nil
+}
+
+// calculateForecast fills in the forecast for the parameters already set in
+// jData: the start time, duration, interval, sources, and (optionally) the
+// observer's coordinates.
+func calculateForecast(jData *jupiterData, earth *pp.V87Planet, jupiter *pp.V87Planet) error {
+	t := jData.StartTime
+	endTime := t.Add(jData.Duration - time.Second)
+	jData.EndTime = endTime
+	jData.Intervals = make([]*forecastInterval, 0)
+	jData.Provenance = newProvenance()
+
+	var jupPositions map[string]*jupiterPosition
+
+	if jData.LocalForecast {
+		// Calculate Jupiter's positions ahead of time.
+		jupPositions = make(map[string]*jupiterPosition, endTime.Sub(t)/time.Hour/24/2)
+
+		tJup := t.Add(-oneDay)
+		for tJup.Before(endTime.Add(2 * oneDay)) {
+			rounded := tJup.Truncate(oneDay)
+			// subtle, but:
+			rjd := julian.TimeToJD(rounded)
+			ra, dec := elliptic.Position(jupiter, earth, rjd)
+			th0 := sidereal.Apparent0UT(rjd)
+			h0 := rise.Stdh0Stellar
+			rising, transit, set, err := rise.ApproxTimes(jData.Coords, h0, th0, ra, dec)
+			if err != nil {
+				return err
+			}
+			jp := &jupiterPosition{EntryDate: rounded, Rising: rising, Transit: transit, Set: set, RA: ra, Dec: dec}
+			jupPositions[rounded.Format(jpFormat)] = jp
+
+			tJup = tJup.Add(oneDay)
+		}
+		jData.JupiterPositions = jupPositions
+	}
+
+	for t.Before(endTime) {
+		jd := julian.TimeToJD(t)
+		var skip bool
+		var jp *jupiterPosition
+		if jData.LocalForecast {
+			// round the day off
+			var ok bool
+			rounded := t.Truncate(oneDay)
+			secs := unit.Time(t.Sub(rounded) / time.Second)
+			if jp, ok = jupPositions[rounded.Format(jpFormat)]; ok {
+				skip = jp.Skip(secs)
+			} else {
+				return fmt.Errorf("Strange, no precalculated Jupiter position for %v under key %s was found.", rounded, rounded.Format(jpFormat))
+			}
+		}
+		if !skip {
+			el, _, eDist := earth.Position2000(jd)
+			jl, _, jDist := jupiter.Position2000(jd)
+			meridian := systemIIIMeridian(jd)
+			dist := distance(el, eDist, jl, jDist)
+			ioPhase := ioPos(jd, dist)
+			rSource := source(meridian, ioPhase)
+
+			if rSource != NoEvent && jData.includesSource(rSource) {
+				fi := new(forecastInterval)
+				fi.Instant = t
+				fi.IoPhase = ioPhase
+				fi.Meridian = meridian
+				fi.Distance = dist
+				fi.RadioSource = rSource
+
+				if jData.LocalForecast {
+					cur := float64(t.Sub(jp.EntryDate) / time.Second)
+					correctTransit, err := jData.GetCorrectTransit(t)
+					if err != nil {
+						return err
+					}
+					diff := cur - float64(correctTransit)
+					fi.TransitHA = unit.HourAngleFromSec(diff)
+					az, alt := coord.EqToHz(jp.RA, jp.Dec, jData.Coords.Lat, jData.Coords.Lon, sidereal.Apparent(jd))
+					fi.AltAz = &hzCoords{Altitude: alt, Azimuth: az + math.Pi}
+				}
+				jData.Intervals = append(jData.Intervals, fi)
+			}
+		}
+		t = t.Add(time.Duration(jData.Interval) * time.Minute)
+	}
+
+	return nil
+}
+
+// loadForecast reads a forecast previously saved with '-output json'.
+func loadForecast(path string) (*jupiterData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	jData := new(jupiterData)
+	if err = json.Unmarshal(data, jData); err != nil {
+		return nil, fmt.Errorf("Error reading forecast from %s: %s", path, err)
+	}
+	return jData, nil
+}
+
+func newProvenance() *forecastProvenance {
+	return &forecastProvenance{Program: "jovian-noise", Version: version, GeneratedAt: time.Now().UTC(), Ephemeris: ephemerisSource}
+}
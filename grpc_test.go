@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"github.com/ctdk/jovian-noise/jovianpb"
+	"github.com/soniakeys/unit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeGRPCForecast stands in for calculateForecast, with an Io-B window over
+// the given span and nothing else.
+func fakeGRPCForecast(open timeSpan) func(*jupiterData) error {
+	return func(jData *jupiterData) error {
+		jData.EndTime = jData.StartTime.Add(jData.Duration - time.Second)
+		jData.Provenance = newProvenance()
+		step := time.Duration(jData.Interval) * time.Minute
+		for t := jData.StartTime; !t.After(jData.EndTime); t = t.Add(step) {
+			if !t.Before(open.Start) && t.Before(open.End) {
+				jData.Intervals = append(jData.Intervals, &forecastInterval{Instant: t, RadioSource: IoB, Meridian: unit.AngleFromDeg(200), IoPhase: unit.AngleFromDeg(90), Distance: 5})
+			}
+		}
+		return nil
+	}
+}
+
+// grpcTestClient serves gs over an in-memory connection, and returns a
+// client for it.
+func grpcTestClient(t *testing.T, gs *grpcServer) jovianpb.JovianNoiseClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	jovianpb.RegisterJovianNoiseServer(srv, gs)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	dial := func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}
+	conn, err := grpc.NewClient("passthrough:///bufconn", grpc.WithContextDialer(dial), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return jovianpb.NewJovianNoiseClient(conn)
+}
+
+func TestGRPCForecastInvalidArgument(t *testing.T) {
+	client := grpcTestClient(t, &grpcServer{forecast: fakeGRPCForecast(timeSpan{}), maxDuration: 90 * oneDay})
+	tests := []struct {
+		name    string
+		req     *jovianpb.ForecastRequest
+		message string
+	}{
+		{"latitude out of range", &jovianpb.ForecastRequest{Observer: &jovianpb.Observer{LatitudeDeg: 100, LongitudeDeg: -105}}, "lat: lat must be between -90 and 90."},
+		{"longitude out of range", &jovianpb.ForecastRequest{Observer: &jovianpb.Observer{LatitudeDeg: 40, LongitudeDeg: -200}}, "lon: lon must be between -180 and 180."},
+		{"unknown source", &jovianpb.ForecastRequest{Sources: []jovianpb.RadioSource{jovianpb.RadioSource_RADIO_SOURCE_IO_A, 9}}, "sources: 9 is not a valid radio source."},
+		{"unspecified source", &jovianpb.ForecastRequest{Sources: []jovianpb.RadioSource{jovianpb.RadioSource_RADIO_SOURCE_UNSPECIFIED}}, "sources: RADIO_SOURCE_UNSPECIFIED is not a valid radio source."},
+		{"negative interval", &jovianpb.ForecastRequest{IntervalMinutes: -5}, "interval: interval must be at least 1 minute."},
+		{"duration too long", &jovianpb.ForecastRequest{Duration: durationpb.New(91 * oneDay)}, "duration: duration can't be longer than 2160h0m0s."},
+	}
+	for _, tt := range tests {
+		_, err := client.Forecast(context.Background(), tt.req)
+		st, ok := status.FromError(err)
+		if !ok || st.Code() != codes.InvalidArgument {
+			t.Errorf("%s: got %v, want InvalidArgument", tt.name, err)
+			continue
+		}
+		if st.Message() != tt.message {
+			t.Errorf("%s: message is %q, want %q", tt.name, st.Message(), tt.message)
+		}
+	}
+}
+
+func TestGRPCForecast(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	open := timeSpan{start.Add(2 * time.Hour), start.Add(3 * time.Hour)}
+	client := grpcTestClient(t, &grpcServer{forecast: fakeGRPCForecast(open), maxDuration: 90 * oneDay})
+
+	resp, err := client.Forecast(context.Background(), &jovianpb.ForecastRequest{Start: timestamppb.New(start), Duration: durationpb.New(oneDay), IntervalMinutes: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Start.AsTime().Equal(start) || resp.Interval.AsDuration() != 15*time.Minute {
+		t.Errorf("forecast starts %s every %s", resp.Start.AsTime(), resp.Interval.AsDuration())
+	}
+	if len(resp.Intervals) != 4 {
+		t.Errorf("got %d intervals, want 4", len(resp.Intervals))
+	}
+	if len(resp.Windows) != 1 {
+		t.Fatalf("got %d windows, want 1", len(resp.Windows))
+	}
+	w := resp.Windows[0]
+	if w.RadioSource != jovianpb.RadioSource_RADIO_SOURCE_IO_B || !w.Start.AsTime().Equal(open.Start) || !w.End.AsTime().Equal(open.End) {
+		t.Errorf("window is %s from %s to %s, want Io-B from %s to %s", w.RadioSource, w.Start.AsTime(), w.End.AsTime(), open.Start, open.End)
+	}
+	if resp.Provenance.GetProgram() != "jovian-noise" {
+		t.Errorf("provenance is %v", resp.Provenance)
+	}
+}
+
+func TestGRPCWatchEvents(t *testing.T) {
+	// a window that's already open, so it's sent straight away
+	now := time.Now().UTC().Truncate(30 * time.Minute)
+	open := timeSpan{now.Add(-time.Hour), now.Add(time.Hour)}
+	client := grpcTestClient(t, &grpcServer{forecast: fakeGRPCForecast(open), maxDuration: 90 * oneDay})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := client.WatchEvents(ctx, &jovianpb.WatchEventsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != jovianpb.WindowEvent_TYPE_WINDOW_START || !ev.Time.AsTime().Equal(open.Start) {
+		t.Errorf("got a %s event at %s, want the window starting at %s", ev.Type, ev.Time.AsTime(), open.Start)
+	}
+	if w := ev.Window; w.GetRadioSource() != jovianpb.RadioSource_RADIO_SOURCE_IO_B || !w.End.AsTime().Equal(open.End) {
+		t.Errorf("event's window is %v, want Io-B until %s", w, open.End)
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("after canceling, got %v", err)
+	}
+}
+
+func TestGRPCWatchEventsInvalidArgument(t *testing.T) {
+	client := grpcTestClient(t, &grpcServer{forecast: fakeGRPCForecast(timeSpan{}), maxDuration: 90 * oneDay})
+	stream, err := client.WatchEvents(context.Background(), &jovianpb.WatchEventsRequest{Observer: &jovianpb.Observer{LatitudeDeg: -91}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.Recv()
+	if st, _ := status.FromError(err); st.Code() != codes.InvalidArgument || st.Message() != "lat: lat must be between -90 and 90." {
+		t.Errorf("got %v, want InvalidArgument for lat", err)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hamlibErrors are the messages for Hamlib's error codes, which rigctld and
+// rotctld reply with as 'RPRT -<code>'.
+var hamlibErrors = []string{
+	"",
+	"invalid parameter",
+	"invalid configuration",
+	"memory shortage",
+	"function not implemented",
+	"communication timed out",
+	"IO error",
+	"internal Hamlib error",
+	"protocol error",
+	"command rejected by the rig",
+	"string truncated",
+	"function not available",
+	"VFO not targetable",
+	"error talking on the bus",
+	"collision on the bus",
+	"NULL RIG handle or invalid pointer parameter",
+	"invalid VFO",
+	"argument out of domain of function",
+}
+
+// hamlibError is a command the daemon answered with an error code. The
+// connection is still good after one of these.
+type hamlibError struct {
+	Addr    string
+	Command string
+	Code    int
+}
+
+func (e *hamlibError) Error() string {
+	msg := fmt.Sprintf("RPRT %d", e.Code)
+	if -e.Code > 0 && -e.Code < len(hamlibErrors) {
+		msg = hamlibErrors[-e.Code]
+	}
+	return fmt.Sprintf("%s rejected '%s': %s", e.Addr, e.Command, msg)
+}
+
+// hamlibConn is a connection to one of Hamlib's network daemons, rigctld or
+// rotctld, which take one line commands and answer commands that set things
+// with 'RPRT 0' on success.
+type hamlibConn struct {
+	addr    string
+	conn    net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+func dialHamlib(ctx context.Context, addr string, timeout time.Duration) (*hamlibConn, error) {
+	d := &net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to %s: %s", addr, err)
+	}
+	return &hamlibConn{addr: addr, conn: conn, r: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+// set sends a command that sets something, logs it, and waits for the
+// reply. It returns an error if the daemon didn't answer 'RPRT 0'.
+func (hc *hamlibConn) set(cmd string) error {
+	hc.conn.SetDeadline(time.Now().Add(hc.timeout))
+	if _, err := fmt.Fprintf(hc.conn, "%s\n", cmd); err != nil {
+		return fmt.Errorf("Error sending '%s' to %s: %s", cmd, hc.addr, err)
+	}
+	reply, err := hc.r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("Error reading the reply to '%s' from %s: %s", cmd, hc.addr, err)
+	}
+	reply = strings.TrimSpace(reply)
+	log.Printf("%s <- %s: %s", hc.addr, cmd, reply)
+
+	code, ok := strings.CutPrefix(reply, "RPRT ")
+	if !ok {
+		return fmt.Errorf("Unexpected reply to '%s' from %s: %s", cmd, hc.addr, reply)
+	}
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return fmt.Errorf("Unexpected reply to '%s' from %s: %s", cmd, hc.addr, reply)
+	}
+	if n != 0 {
+		return &hamlibError{Addr: hc.addr, Command: cmd, Code: n}
+	}
+	return nil
+}
+
+func (hc *hamlibConn) Close() error {
+	return hc.conn.Close()
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeHamlib is a stand-in for rigctld or rotctld on a loopback port. It
+// answers every command with 'RPRT 0', unless there's a reply for it in
+// Replies (keyed by the command's first word, or the whole command), and
+// keeps the commands it was sent.
+type fakeHamlib struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	replies  map[string]string
+	commands []string
+}
+
+func newFakeHamlib(t *testing.T, replies map[string]string) *fakeHamlib {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh := &fakeHamlib{ln: ln, replies: replies}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fh.serve(conn)
+		}
+	}()
+	return fh
+}
+
+func (fh *fakeHamlib) addr() string {
+	return fh.ln.Addr().String()
+}
+
+func (fh *fakeHamlib) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		fh.mu.Lock()
+		fh.commands = append(fh.commands, cmd)
+		reply, ok := fh.replies[cmd]
+		if !ok {
+			reply, ok = fh.replies[strings.Fields(cmd + " ")[0]]
+		}
+		fh.mu.Unlock()
+		if !ok {
+			reply = "RPRT 0"
+		}
+		fmt.Fprintf(conn, "%s\n", reply)
+	}
+}
+
+func (fh *fakeHamlib) sent() []string {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+	return append([]string(nil), fh.commands...)
+}
+
+func TestHamlibErrorMessages(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{-1, "localhost:4532 rejected 'F 20100000': invalid parameter"},
+		{-5, "localhost:4532 rejected 'F 20100000': communication timed out"},
+		{-9, "localhost:4532 rejected 'F 20100000': command rejected by the rig"},
+		{-17, "localhost:4532 rejected 'F 20100000': argument out of domain of function"},
+		{-18, "localhost:4532 rejected 'F 20100000': RPRT -18"},
+		{-99, "localhost:4532 rejected 'F 20100000': RPRT -99"},
+		{3, "localhost:4532 rejected 'F 20100000': RPRT 3"},
+	}
+	for _, tt := range tests {
+		err := &hamlibError{Addr: "localhost:4532", Command: "F 20100000", Code: tt.code}
+		if got := err.Error(); got != tt.want {
+			t.Errorf("code %d: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestHamlibSetReplies(t *testing.T) {
+	fh := newFakeHamlib(t, map[string]string{
+		"F": "RPRT -9",
+		"M": "RPRT nope",
+		"L": "get_level: 1",
+	})
+	hc, err := dialHamlib(context.Background(), fh.addr(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hc.Close()
+
+	if err := hc.set("V VFOA"); err != nil {
+		t.Errorf("RPRT 0 should be success: %s", err)
+	}
+	err = hc.set("F 20100000")
+	var he *hamlibError
+	if !errors.As(err, &he) || he.Code != -9 || he.Command != "F 20100000" {
+		t.Errorf("RPRT -9 should be a hamlibError with code -9, got %v", err)
+	}
+	for _, cmd := range []string{"M AM 6000", "L PREAMP 10"} {
+		err = hc.set(cmd)
+		if err == nil || errors.As(err, &he) || !strings.Contains(err.Error(), "Unexpected reply") {
+			t.Errorf("%s: got %v, want an unexpected reply error", cmd, err)
+		}
+	}
+	// the connection should still be usable after all that
+	if err := hc.set("V VFOA"); err != nil {
+		t.Errorf("connection broken after errors: %s", err)
+	}
+}
+
+func TestHamlibTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		// accept, but never answer
+		conn, err := ln.Accept()
+		if err == nil {
+			time.Sleep(time.Second)
+			conn.Close()
+		}
+	}()
+	hc, err := dialHamlib(context.Background(), ln.Addr().String(), 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hc.Close()
+	if err := hc.set("F 20100000"); err == nil || !strings.Contains(err.Error(), "Error reading the reply") {
+		t.Errorf("got %v, want a timeout reading the reply", err)
+	}
+}
@@ -18,8 +18,14 @@ To run this program, you will need to obtain the VSOP87 files for planet locatio
     Usage of ./jovian-noise:
       -duration duration
             Duration (in golang ParseDuration format) from the start time to calculate the forecast (default 720h0m0s)
+      -influx-url string
+            Optional InfluxDB HTTP write endpoint to send '-output influx' points to, instead of printing them (e.g. 'http://localhost:8086/api/v2/write?org=home&bucket=jupiter'). An API token can be given in the INFLUX_TOKEN environment variable.
+      -input string
+            Optional path to a forecast saved with '-output json' to display, instead of calculating a new forecast. The forecast parameter flags are ignored, but the time zone flags can be used to change the time zone results are displayed in.
       -interval int
             Interval in minutes to calculate the forecast (default 30)
+      -json-schema
+            Print the JSON Schema for '-output json' forecasts and exit.
       -lat int
             Optional latitute. If given, will limit results to when Jupiter is above the horizon at this location. Requires -lon
       -local
@@ -31,9 +37,15 @@ To run this program, you will need to obtain the VSOP87 files for planet locatio
       -offset-hours float
             Optional offset in hours east of UTC to display results. Offsets to the west should be given with negative numbers (e.g. '-offset-hours -7'). Conflicts with -timezone and -local.
       -output string
-            How to format the forecast for output. Currently acceptable options are: text (default), json. (default "text")
+            How to format the forecast for output. Currently acceptable options are: text (default), json, html, svg, png, pdf, ics, influx. (default "text")
+      -sources string
+            Optional comma separated list of the radio sources to forecast (e.g. 'Io-A,Io-B'). Defaults to Io-A, Io-B, and Io-C. Overrides -non-io-a.
       -start-time string
             Start time (in RFC 3339 format) to calculate Jupiter radio storm forecasts (defaults to the start of the current hour)
+      -station string
+            Optional station name to tag points with in '-output influx'.
+      -template string
+            Optional path to a text/template file to format the forecast with, instead of the built-in text output. Only valid with '-output text'.
       -timezone string
             Optional timezone for displaying results. Conflicts with -offset-hours and -local.
       -version
@@ -67,15 +79,7 @@ package main
 import (
 	"flag"
 	"fmt"
-	"github.com/soniakeys/meeus/v3/coord"
-	"github.com/soniakeys/meeus/v3/elliptic"
-	"github.com/soniakeys/meeus/v3/julian"
-	pp "github.com/soniakeys/meeus/v3/planetposition"
-	"github.com/soniakeys/meeus/v3/rise"
-	"github.com/soniakeys/meeus/v3/sidereal"
-	"github.com/soniakeys/unit"
 	"log"
-	"math"
 	"os"
 	"time"
 )
@@ -85,196 +89,118 @@ const jpFormat string = "2006-01-02"
 const oneDay time.Duration = 24 * time.Hour
 
 func main() {
-	startTime := flag.String("start-time", "", "Start time (in RFC 3339 format) to calculate Jupiter radio storm forecasts (defaults to the start of the current hour)")
-	dur := flag.Duration("duration", 30*oneDay, "Duration (in golang ParseDuration format) from the start time to calculate the forecast")
-	interval := flag.Int("interval", 30, "Interval in minutes to calculate the forecast")
-	tz := flag.String("timezone", "", "Optional timezone for displaying results. Conflicts with -offset-hours and -local.")
-	offsetHours := flag.Float64("offset-hours", 0, "Optional offset in hours east of UTC to display results. Offsets to the west should be given with negative numbers (e.g. '-offset-hours -7' or '-offset-hours 9.5'). Conflicts with -timezone and -local.")
-	localTZ := flag.Bool("local", false, "Optionally use this computer's timzone to display results. Conflicts with -timezone and -offset-hours.")
-	lat := flag.Int("lat", 0, "Optional latitute. If given, will limit results to when Jupiter is above the horizon at this location. Requires -lon")
-	lon := flag.Int("lon", 0, "Optional longitude. If given, will limit results to when Jupiter is above the horizon at this location. Requires -lat")
-	ver := flag.Bool("version", false, "Print version number and exit.")
-	nonIoA := flag.Bool("non-io-a", false, "Include forecasts for the non-Io-A radio source.")
-	output := flag.String("output", "text", "How to format the forecast for output. Currently acceptable options are: text (default), json.")
-
-	jData := new(jupiterData)
-	jData.Intervals = make([]*forecastInterval, 0)
+	if ran, err := runCommand(os.Args[1:]); ran {
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
 
-	var t time.Time
+	fp := addForecastFlags(flag.CommandLine)
+	ver := flag.Bool("version", false, "Print version number and exit.")
+	output := flag.String("output", "text", "How to format the forecast for output. Currently acceptable options are: text (default), json, html, svg, png, pdf, ics, influx.")
+	tmplFile := flag.String("template", "", "Optional path to a text/template file to format the forecast with, instead of the built-in text output. Only valid with '-output text'.")
+	printSchema := flag.Bool("json-schema", false, "Print the JSON Schema for '-output json' forecasts and exit.")
+	station := flag.String("station", "", "Optional station name to tag points with in '-output influx'.")
+	influxURL := flag.String("influx-url", "", "Optional InfluxDB HTTP write endpoint to send '-output influx' points to, instead of printing them (e.g. 'http://localhost:8086/api/v2/write?org=home&bucket=jupiter'). An API token can be given in the INFLUX_TOKEN environment variable.")
+	input := flag.String("input", "", "Optional path to a forecast saved with '-output json' to display, instead of calculating a new forecast. The forecast parameter flags are ignored, but the time zone flags can be used to change the time zone results are displayed in.")
+
+	var jData *jupiterData
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintf(flag.CommandLine.Output(), "\n%s", commandUsage())
+	}
 	flag.Parse()
+	fp.setFlags(flag.CommandLine)
 
 	if *ver {
 		fmt.Printf("jovian-noise version %s\n", version)
 		os.Exit(0)
 	}
 
-	if *interval < 1 {
-		fmt.Printf("-interval must be at least 1 minute.\n")
-		os.Exit(1)
+	if *printSchema {
+		fmt.Print(jsonSchema)
+		os.Exit(0)
 	}
-	if *dur < time.Duration(*interval)*time.Minute {
-		fmt.Printf("-duration really should be longer than the interval specified.\n")
+
+	if *tmplFile != "" && *output != "text" {
+		fmt.Printf("-template can only be used with '-output text'.\n")
 		os.Exit(1)
 	}
 
-	roundOffset := int(*offsetHours)
-	// bleh
-	if *tz != "" && roundOffset != 0 || *tz != "" && *localTZ || roundOffset != 0 && *localTZ {
-		fmt.Printf("One of the -timezone, -offset-hours, and -local flags can be specified, but not more than that. None are required, however.")
+	if *influxURL != "" && *output != "influx" {
+		fmt.Printf("-influx-url can only be used with '-output influx'.\n")
 		os.Exit(1)
 	}
 
-	if *tz != "" {
-		if loc, err := time.LoadLocation(*tz); err != nil {
-			fmt.Printf("Error loading timezone %s: %w\n", *tz, err)
-		} else {
+	if *input != "" {
+		loc, err := fp.location()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		jData, err = loadForecast(*input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if loc != nil {
 			jData.Location = loc
 		}
-	} else if roundOffset != 0 {
-		m := time.Duration(*offsetHours * 60)
-		secondsEast := int((m * time.Minute).Seconds())
-		jData.Location = time.FixedZone("Manual Offset Zone", secondsEast)
-	} else if *localTZ {
-		jData.Location = time.Local
-	}
-
-	jData.Duration = *dur
-	jData.Interval = *interval
-
-	if *startTime == "" {
-		t = time.Now().UTC().Truncate(time.Hour)
 	} else {
 		var err error
-		t, err = time.Parse(time.RFC3339, *startTime)
+		jData, err = fp.jupiterData()
 		if err != nil {
-			log.Println(err)
+			fmt.Println(err)
 			os.Exit(1)
 		}
-		t = t.UTC()
-	}
-	jData.StartTime = t
 
-	var latSet, lonSet bool
-	for _, v := range os.Args {
-		if v == "-lat" {
-			latSet = true
-		} else if v == "-lon" {
-			lonSet = true
+		earth, jupiter, err := loadPlanets()
+		if err != nil {
+			log.Fatal(err)
 		}
-	}
-
-	if latSet && !lonSet || !latSet && lonSet {
-		log.Println("Both -lat and -lon, or neither, must be supplied")
-		os.Exit(1)
-	}
 
-	if latSet && lonSet {
-		// for some reason this figures longitude backwards from
-		// the way everyone else does it.
-		if *lon != 0 {
-			*lon = -*lon
-			if *lon < 0 {
-				*lon += 360
-			}
+		if err = calculateForecast(jData, earth, jupiter); err != nil {
+			log.Fatal(err)
 		}
-
-		jData.Coords.Lon = unit.NewAngle('+', *lon, 0, 0)
-		jData.Coords.Lat = unit.NewAngle('+', *lat, 0, 0)
-		jData.LocalForecast = true
 	}
 
-	earth, err := pp.LoadPlanet(pp.Earth)
-	if err != nil {
-		panic(err)
-	}
-	jupiter, err := pp.LoadPlanet(pp.Jupiter)
-	if err != nil {
-		panic(err)
-	}
-	endTime := t.Add(*dur - time.Second)
-	jData.EndTime = endTime
-
-	var jupPositions map[string]*jupiterPosition
-
-	if jData.LocalForecast {
-		// Calculate Jupiter's positions ahead of time.
-		jupPositions = make(map[string]*jupiterPosition, endTime.Sub(t)/time.Hour/24/2)
-
-		tJup := t.Add(-oneDay)
-		for tJup.Before(endTime.Add(2 * oneDay)) {
-			rounded := tJup.Truncate(oneDay)
-			// subtle, but:
-			rjd := julian.TimeToJD(rounded)
-			ra, dec := elliptic.Position(jupiter, earth, rjd)
-			th0 := sidereal.Apparent0UT(rjd)
-			h0 := rise.Stdh0Stellar
-			rising, transit, set, err := rise.ApproxTimes(jData.Coords, h0, th0, ra, dec)
-			if err != nil {
+	switch *output {
+	case "text":
+		if *tmplFile != "" {
+			if err := outputTemplate(jData, *tmplFile); err != nil {
 				log.Fatal(err)
 			}
-			jp := &jupiterPosition{EntryDate: rounded, Rising: rising, Transit: transit, Set: set, RA: ra, Dec: dec}
-			jupPositions[rounded.Format(jpFormat)] = jp
-
-			tJup = tJup.Add(oneDay)
+		} else if err := outputText(jData); err != nil {
+			log.Fatal(err)
 		}
-		jData.JupiterPositions = jupPositions
-	}
-
-	for t.Before(endTime) {
-		jd := julian.TimeToJD(t)
-		var skip bool
-		var jp *jupiterPosition
-		if jData.LocalForecast {
-			// round the day off
-			var ok bool
-			rounded := t.Truncate(oneDay)
-			secs := unit.Time(t.Sub(rounded) / time.Second)
-			if jp, ok = jupPositions[rounded.Format(jpFormat)]; ok {
-				skip = jp.Skip(secs)
-			} else {
-				log.Fatalf("Strange, no precalculated Jupiter position for %v under key %s was found.", rounded, rounded.Format(jpFormat))
-			}
+	case "json":
+		if err := outputJSON(jData); err != nil {
+			log.Fatal(err)
 		}
-		if !skip {
-			el, _, eDist := earth.Position2000(jd)
-			jl, _, jDist := jupiter.Position2000(jd)
-			meridian := systemIIIMeridian(jd)
-			dist := distance(el, eDist, jl, jDist)
-			ioPhase := ioPos(jd, dist)
-			rSource := source(meridian, ioPhase)
-
-			if rSource != NoEvent && (rSource != NonIoA || *nonIoA) {
-				fi := new(forecastInterval)
-				fi.Instant = t
-				fi.IoPhase = ioPhase
-				fi.Meridian = meridian
-				fi.Distance = dist
-				fi.RadioSource = rSource
-
-				if jData.LocalForecast {
-					cur := float64(t.Sub(jp.EntryDate) / time.Second)
-					correctTransit, err := jData.GetCorrectTransit(t)
-					if err != nil {
-						log.Fatal(err)
-					}
-					diff := cur - float64(correctTransit)
-					fi.TransitHA = unit.HourAngleFromSec(diff)
-					az, alt := coord.EqToHz(jp.RA, jp.Dec, jData.Coords.Lat, jData.Coords.Lon, sidereal.Apparent(jd))
-					fi.AltAz = &hzCoords{Altitude: alt, Azimuth: az + math.Pi}
-				}
-				jData.Intervals = append(jData.Intervals, fi)
-			}
+	case "html":
+		if err := outputHTML(jData); err != nil {
+			log.Fatal(err)
 		}
-		t = t.Add(time.Duration(*interval) * time.Minute)
-	}
-
-	switch *output {
-	case "text":
-		if err := outputText(jData); err != nil {
+	case "svg":
+		if err := outputSVG(jData); err != nil {
 			log.Fatal(err)
 		}
-	case "json":
-		if err := outputJSON(jData); err != nil {
+	case "png":
+		if err := outputPNG(jData); err != nil {
+			log.Fatal(err)
+		}
+	case "pdf":
+		if err := outputPDF(jData); err != nil {
+			log.Fatal(err)
+		}
+	case "ics":
+		if err := outputICS(jData); err != nil {
+			log.Fatal(err)
+		}
+	case "influx":
+		if err := outputInflux(jData, *station, *influxURL); err != nil {
 			log.Fatal(err)
 		}
 	default:
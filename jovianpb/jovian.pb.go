@@ -0,0 +1,901 @@
+// The gRPC interface to jovian-noise's Jupiter decameter radio storm
+// forecasts. Run 'jovian-noise grpc' to serve it.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        v7.35.1
+// source: jovianpb/jovian.proto
+
+package jovianpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type RadioSource int32
+
+const (
+	RadioSource_RADIO_SOURCE_UNSPECIFIED RadioSource = 0
+	RadioSource_RADIO_SOURCE_IO_A        RadioSource = 1
+	RadioSource_RADIO_SOURCE_IO_B        RadioSource = 2
+	RadioSource_RADIO_SOURCE_IO_C        RadioSource = 3
+	RadioSource_RADIO_SOURCE_NON_IO_A    RadioSource = 4
+)
+
+// Enum value maps for RadioSource.
+var (
+	RadioSource_name = map[int32]string{
+		0: "RADIO_SOURCE_UNSPECIFIED",
+		1: "RADIO_SOURCE_IO_A",
+		2: "RADIO_SOURCE_IO_B",
+		3: "RADIO_SOURCE_IO_C",
+		4: "RADIO_SOURCE_NON_IO_A",
+	}
+	RadioSource_value = map[string]int32{
+		"RADIO_SOURCE_UNSPECIFIED": 0,
+		"RADIO_SOURCE_IO_A":        1,
+		"RADIO_SOURCE_IO_B":        2,
+		"RADIO_SOURCE_IO_C":        3,
+		"RADIO_SOURCE_NON_IO_A":    4,
+	}
+)
+
+func (x RadioSource) Enum() *RadioSource {
+	p := new(RadioSource)
+	*p = x
+	return p
+}
+
+func (x RadioSource) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RadioSource) Descriptor() protoreflect.EnumDescriptor {
+	return file_jovianpb_jovian_proto_enumTypes[0].Descriptor()
+}
+
+func (RadioSource) Type() protoreflect.EnumType {
+	return &file_jovianpb_jovian_proto_enumTypes[0]
+}
+
+func (x RadioSource) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RadioSource.Descriptor instead.
+func (RadioSource) EnumDescriptor() ([]byte, []int) {
+	return file_jovianpb_jovian_proto_rawDescGZIP(), []int{0}
+}
+
+type WindowEvent_Type int32
+
+const (
+	WindowEvent_TYPE_UNSPECIFIED  WindowEvent_Type = 0
+	WindowEvent_TYPE_WINDOW_START WindowEvent_Type = 1
+	WindowEvent_TYPE_WINDOW_END   WindowEvent_Type = 2
+)
+
+// Enum value maps for WindowEvent_Type.
+var (
+	WindowEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "TYPE_WINDOW_START",
+		2: "TYPE_WINDOW_END",
+	}
+	WindowEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED":  0,
+		"TYPE_WINDOW_START": 1,
+		"TYPE_WINDOW_END":   2,
+	}
+)
+
+func (x WindowEvent_Type) Enum() *WindowEvent_Type {
+	p := new(WindowEvent_Type)
+	*p = x
+	return p
+}
+
+func (x WindowEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WindowEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_jovianpb_jovian_proto_enumTypes[1].Descriptor()
+}
+
+func (WindowEvent_Type) Type() protoreflect.EnumType {
+	return &file_jovianpb_jovian_proto_enumTypes[1]
+}
+
+func (x WindowEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WindowEvent_Type.Descriptor instead.
+func (WindowEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_jovianpb_jovian_proto_rawDescGZIP(), []int{7, 0}
+}
+
+// Observer is a location on Earth, in whole degrees. Longitude is positive
+// east of Greenwich.
+type Observer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LatitudeDeg   int32                  `protobuf:"varint,1,opt,name=latitude_deg,json=latitudeDeg,proto3" json:"latitude_deg,omitempty"`
+	LongitudeDeg  int32                  `protobuf:"varint,2,opt,name=longitude_deg,json=longitudeDeg,proto3" json:"longitude_deg,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Observer) Reset() {
+	*x = Observer{}
+	mi := &file_jovianpb_jovian_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Observer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Observer) ProtoMessage() {}
+
+func (x *Observer) ProtoReflect() protoreflect.Message {
+	mi := &file_jovianpb_jovian_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Observer.ProtoReflect.Descriptor instead.
+func (*Observer) Descriptor() ([]byte, []int) {
+	return file_jovianpb_jovian_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Observer) GetLatitudeDeg() int32 {
+	if x != nil {
+		return x.LatitudeDeg
+	}
+	return 0
+}
+
+func (x *Observer) GetLongitudeDeg() int32 {
+	if x != nil {
+		return x.LongitudeDeg
+	}
+	return 0
+}
+
+type ForecastRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Defaults to the start of the current hour.
+	Start *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	// Defaults to 30 days.
+	Duration *durationpb.Duration `protobuf:"bytes,2,opt,name=duration,proto3" json:"duration,omitempty"`
+	// Defaults to 30.
+	IntervalMinutes int32 `protobuf:"varint,3,opt,name=interval_minutes,json=intervalMinutes,proto3" json:"interval_minutes,omitempty"`
+	// Defaults to Io-A, Io-B, and Io-C.
+	Sources []RadioSource `protobuf:"varint,4,rep,packed,name=sources,proto3,enum=jovian.v1.RadioSource" json:"sources,omitempty"`
+	// If set, only times when Jupiter is above this observer's horizon are
+	// forecast.
+	Observer      *Observer `protobuf:"bytes,5,opt,name=observer,proto3" json:"observer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForecastRequest) Reset() {
+	*x = ForecastRequest{}
+	mi := &file_jovianpb_jovian_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForecastRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForecastRequest) ProtoMessage() {}
+
+func (x *ForecastRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jovianpb_jovian_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForecastRequest.ProtoReflect.Descriptor instead.
+func (*ForecastRequest) Descriptor() ([]byte, []int) {
+	return file_jovianpb_jovian_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ForecastRequest) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *ForecastRequest) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *ForecastRequest) GetIntervalMinutes() int32 {
+	if x != nil {
+		return x.IntervalMinutes
+	}
+	return 0
+}
+
+func (x *ForecastRequest) GetSources() []RadioSource {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *ForecastRequest) GetObserver() *Observer {
+	if x != nil {
+		return x.Observer
+	}
+	return nil
+}
+
+type Provenance struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Program       string                 `protobuf:"bytes,1,opt,name=program,proto3" json:"program,omitempty"`
+	Version       string                 `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	GeneratedAt   *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=generated_at,json=generatedAt,proto3" json:"generated_at,omitempty"`
+	Ephemeris     string                 `protobuf:"bytes,4,opt,name=ephemeris,proto3" json:"ephemeris,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Provenance) Reset() {
+	*x = Provenance{}
+	mi := &file_jovianpb_jovian_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Provenance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Provenance) ProtoMessage() {}
+
+func (x *Provenance) ProtoReflect() protoreflect.Message {
+	mi := &file_jovianpb_jovian_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Provenance.ProtoReflect.Descriptor instead.
+func (*Provenance) Descriptor() ([]byte, []int) {
+	return file_jovianpb_jovian_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Provenance) GetProgram() string {
+	if x != nil {
+		return x.Program
+	}
+	return ""
+}
+
+func (x *Provenance) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *Provenance) GetGeneratedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.GeneratedAt
+	}
+	return nil
+}
+
+func (x *Provenance) GetEphemeris() string {
+	if x != nil {
+		return x.Ephemeris
+	}
+	return ""
+}
+
+type Interval struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Instant     *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=instant,proto3" json:"instant,omitempty"`
+	RadioSource RadioSource            `protobuf:"varint,2,opt,name=radio_source,json=radioSource,proto3,enum=jovian.v1.RadioSource" json:"radio_source,omitempty"`
+	CmlDeg      float64                `protobuf:"fixed64,3,opt,name=cml_deg,json=cmlDeg,proto3" json:"cml_deg,omitempty"`
+	IoPhaseDeg  float64                `protobuf:"fixed64,4,opt,name=io_phase_deg,json=ioPhaseDeg,proto3" json:"io_phase_deg,omitempty"`
+	DistanceAu  float64                `protobuf:"fixed64,5,opt,name=distance_au,json=distanceAu,proto3" json:"distance_au,omitempty"`
+	// The rest are only set when the forecast has an observer.
+	TransitHourAngleHours *float64 `protobuf:"fixed64,6,opt,name=transit_hour_angle_hours,json=transitHourAngleHours,proto3,oneof" json:"transit_hour_angle_hours,omitempty"`
+	AltitudeDeg           *float64 `protobuf:"fixed64,7,opt,name=altitude_deg,json=altitudeDeg,proto3,oneof" json:"altitude_deg,omitempty"`
+	AzimuthDeg            *float64 `protobuf:"fixed64,8,opt,name=azimuth_deg,json=azimuthDeg,proto3,oneof" json:"azimuth_deg,omitempty"`
+	Recommended           bool     `protobuf:"varint,9,opt,name=recommended,proto3" json:"recommended,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *Interval) Reset() {
+	*x = Interval{}
+	mi := &file_jovianpb_jovian_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Interval) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Interval) ProtoMessage() {}
+
+func (x *Interval) ProtoReflect() protoreflect.Message {
+	mi := &file_jovianpb_jovian_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Interval.ProtoReflect.Descriptor instead.
+func (*Interval) Descriptor() ([]byte, []int) {
+	return file_jovianpb_jovian_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Interval) GetInstant() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Instant
+	}
+	return nil
+}
+
+func (x *Interval) GetRadioSource() RadioSource {
+	if x != nil {
+		return x.RadioSource
+	}
+	return RadioSource_RADIO_SOURCE_UNSPECIFIED
+}
+
+func (x *Interval) GetCmlDeg() float64 {
+	if x != nil {
+		return x.CmlDeg
+	}
+	return 0
+}
+
+func (x *Interval) GetIoPhaseDeg() float64 {
+	if x != nil {
+		return x.IoPhaseDeg
+	}
+	return 0
+}
+
+func (x *Interval) GetDistanceAu() float64 {
+	if x != nil {
+		return x.DistanceAu
+	}
+	return 0
+}
+
+func (x *Interval) GetTransitHourAngleHours() float64 {
+	if x != nil && x.TransitHourAngleHours != nil {
+		return *x.TransitHourAngleHours
+	}
+	return 0
+}
+
+func (x *Interval) GetAltitudeDeg() float64 {
+	if x != nil && x.AltitudeDeg != nil {
+		return *x.AltitudeDeg
+	}
+	return 0
+}
+
+func (x *Interval) GetAzimuthDeg() float64 {
+	if x != nil && x.AzimuthDeg != nil {
+		return *x.AzimuthDeg
+	}
+	return 0
+}
+
+func (x *Interval) GetRecommended() bool {
+	if x != nil {
+		return x.Recommended
+	}
+	return false
+}
+
+// Window is a run of consecutive intervals with the same radio source.
+type Window struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Start       *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End         *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	RadioSource RadioSource            `protobuf:"varint,3,opt,name=radio_source,json=radioSource,proto3,enum=jovian.v1.RadioSource" json:"radio_source,omitempty"`
+	Recommended bool                   `protobuf:"varint,4,opt,name=recommended,proto3" json:"recommended,omitempty"`
+	// Only set when the forecast has an observer.
+	PeakAltitudeDeg *float64 `protobuf:"fixed64,5,opt,name=peak_altitude_deg,json=peakAltitudeDeg,proto3,oneof" json:"peak_altitude_deg,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Window) Reset() {
+	*x = Window{}
+	mi := &file_jovianpb_jovian_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Window) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Window) ProtoMessage() {}
+
+func (x *Window) ProtoReflect() protoreflect.Message {
+	mi := &file_jovianpb_jovian_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Window.ProtoReflect.Descriptor instead.
+func (*Window) Descriptor() ([]byte, []int) {
+	return file_jovianpb_jovian_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Window) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *Window) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *Window) GetRadioSource() RadioSource {
+	if x != nil {
+		return x.RadioSource
+	}
+	return RadioSource_RADIO_SOURCE_UNSPECIFIED
+}
+
+func (x *Window) GetRecommended() bool {
+	if x != nil {
+		return x.Recommended
+	}
+	return false
+}
+
+func (x *Window) GetPeakAltitudeDeg() float64 {
+	if x != nil && x.PeakAltitudeDeg != nil {
+		return *x.PeakAltitudeDeg
+	}
+	return 0
+}
+
+type ForecastResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	Interval      *durationpb.Duration   `protobuf:"bytes,3,opt,name=interval,proto3" json:"interval,omitempty"`
+	Observer      *Observer              `protobuf:"bytes,4,opt,name=observer,proto3" json:"observer,omitempty"`
+	Intervals     []*Interval            `protobuf:"bytes,5,rep,name=intervals,proto3" json:"intervals,omitempty"`
+	Windows       []*Window              `protobuf:"bytes,6,rep,name=windows,proto3" json:"windows,omitempty"`
+	Provenance    *Provenance            `protobuf:"bytes,7,opt,name=provenance,proto3" json:"provenance,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForecastResponse) Reset() {
+	*x = ForecastResponse{}
+	mi := &file_jovianpb_jovian_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForecastResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForecastResponse) ProtoMessage() {}
+
+func (x *ForecastResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_jovianpb_jovian_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForecastResponse.ProtoReflect.Descriptor instead.
+func (*ForecastResponse) Descriptor() ([]byte, []int) {
+	return file_jovianpb_jovian_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ForecastResponse) GetStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Start
+	}
+	return nil
+}
+
+func (x *ForecastResponse) GetEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.End
+	}
+	return nil
+}
+
+func (x *ForecastResponse) GetInterval() *durationpb.Duration {
+	if x != nil {
+		return x.Interval
+	}
+	return nil
+}
+
+func (x *ForecastResponse) GetObserver() *Observer {
+	if x != nil {
+		return x.Observer
+	}
+	return nil
+}
+
+func (x *ForecastResponse) GetIntervals() []*Interval {
+	if x != nil {
+		return x.Intervals
+	}
+	return nil
+}
+
+func (x *ForecastResponse) GetWindows() []*Window {
+	if x != nil {
+		return x.Windows
+	}
+	return nil
+}
+
+func (x *ForecastResponse) GetProvenance() *Provenance {
+	if x != nil {
+		return x.Provenance
+	}
+	return nil
+}
+
+type WatchEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Defaults to 30.
+	IntervalMinutes int32 `protobuf:"varint,1,opt,name=interval_minutes,json=intervalMinutes,proto3" json:"interval_minutes,omitempty"`
+	// Defaults to Io-A, Io-B, and Io-C.
+	Sources       []RadioSource `protobuf:"varint,2,rep,packed,name=sources,proto3,enum=jovian.v1.RadioSource" json:"sources,omitempty"`
+	Observer      *Observer     `protobuf:"bytes,3,opt,name=observer,proto3" json:"observer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEventsRequest) Reset() {
+	*x = WatchEventsRequest{}
+	mi := &file_jovianpb_jovian_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEventsRequest) ProtoMessage() {}
+
+func (x *WatchEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_jovianpb_jovian_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEventsRequest.ProtoReflect.Descriptor instead.
+func (*WatchEventsRequest) Descriptor() ([]byte, []int) {
+	return file_jovianpb_jovian_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WatchEventsRequest) GetIntervalMinutes() int32 {
+	if x != nil {
+		return x.IntervalMinutes
+	}
+	return 0
+}
+
+func (x *WatchEventsRequest) GetSources() []RadioSource {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *WatchEventsRequest) GetObserver() *Observer {
+	if x != nil {
+		return x.Observer
+	}
+	return nil
+}
+
+type WindowEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  WindowEvent_Type       `protobuf:"varint,1,opt,name=type,proto3,enum=jovian.v1.WindowEvent_Type" json:"type,omitempty"`
+	// When the event happened, which is the window's start or end.
+	Time          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	Window        *Window                `protobuf:"bytes,3,opt,name=window,proto3" json:"window,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WindowEvent) Reset() {
+	*x = WindowEvent{}
+	mi := &file_jovianpb_jovian_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WindowEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WindowEvent) ProtoMessage() {}
+
+func (x *WindowEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_jovianpb_jovian_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WindowEvent.ProtoReflect.Descriptor instead.
+func (*WindowEvent) Descriptor() ([]byte, []int) {
+	return file_jovianpb_jovian_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WindowEvent) GetType() WindowEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return WindowEvent_TYPE_UNSPECIFIED
+}
+
+func (x *WindowEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *WindowEvent) GetWindow() *Window {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+var File_jovianpb_jovian_proto protoreflect.FileDescriptor
+
+const file_jovianpb_jovian_proto_rawDesc = "" +
+	"\n" +
+	"\x15jovianpb/jovian.proto\x12\tjovian.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"R\n" +
+	"\bObserver\x12!\n" +
+	"\flatitude_deg\x18\x01 \x01(\x05R\vlatitudeDeg\x12#\n" +
+	"\rlongitude_deg\x18\x02 \x01(\x05R\flongitudeDeg\"\x88\x02\n" +
+	"\x0fForecastRequest\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x125\n" +
+	"\bduration\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12)\n" +
+	"\x10interval_minutes\x18\x03 \x01(\x05R\x0fintervalMinutes\x120\n" +
+	"\asources\x18\x04 \x03(\x0e2\x16.jovian.v1.RadioSourceR\asources\x12/\n" +
+	"\bobserver\x18\x05 \x01(\v2\x13.jovian.v1.ObserverR\bobserver\"\x9d\x01\n" +
+	"\n" +
+	"Provenance\x12\x18\n" +
+	"\aprogram\x18\x01 \x01(\tR\aprogram\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\tR\aversion\x12=\n" +
+	"\fgenerated_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\vgeneratedAt\x12\x1c\n" +
+	"\tephemeris\x18\x04 \x01(\tR\tephemeris\"\xc3\x03\n" +
+	"\bInterval\x124\n" +
+	"\ainstant\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\ainstant\x129\n" +
+	"\fradio_source\x18\x02 \x01(\x0e2\x16.jovian.v1.RadioSourceR\vradioSource\x12\x17\n" +
+	"\acml_deg\x18\x03 \x01(\x01R\x06cmlDeg\x12 \n" +
+	"\fio_phase_deg\x18\x04 \x01(\x01R\n" +
+	"ioPhaseDeg\x12\x1f\n" +
+	"\vdistance_au\x18\x05 \x01(\x01R\n" +
+	"distanceAu\x12<\n" +
+	"\x18transit_hour_angle_hours\x18\x06 \x01(\x01H\x00R\x15transitHourAngleHours\x88\x01\x01\x12&\n" +
+	"\faltitude_deg\x18\a \x01(\x01H\x01R\valtitudeDeg\x88\x01\x01\x12$\n" +
+	"\vazimuth_deg\x18\b \x01(\x01H\x02R\n" +
+	"azimuthDeg\x88\x01\x01\x12 \n" +
+	"\vrecommended\x18\t \x01(\bR\vrecommendedB\x1b\n" +
+	"\x19_transit_hour_angle_hoursB\x0f\n" +
+	"\r_altitude_degB\x0e\n" +
+	"\f_azimuth_deg\"\x8c\x02\n" +
+	"\x06Window\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x129\n" +
+	"\fradio_source\x18\x03 \x01(\x0e2\x16.jovian.v1.RadioSourceR\vradioSource\x12 \n" +
+	"\vrecommended\x18\x04 \x01(\bR\vrecommended\x12/\n" +
+	"\x11peak_altitude_deg\x18\x05 \x01(\x01H\x00R\x0fpeakAltitudeDeg\x88\x01\x01B\x14\n" +
+	"\x12_peak_altitude_deg\"\xf1\x02\n" +
+	"\x10ForecastResponse\x120\n" +
+	"\x05start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05start\x12,\n" +
+	"\x03end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x03end\x125\n" +
+	"\binterval\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\binterval\x12/\n" +
+	"\bobserver\x18\x04 \x01(\v2\x13.jovian.v1.ObserverR\bobserver\x121\n" +
+	"\tintervals\x18\x05 \x03(\v2\x13.jovian.v1.IntervalR\tintervals\x12+\n" +
+	"\awindows\x18\x06 \x03(\v2\x11.jovian.v1.WindowR\awindows\x125\n" +
+	"\n" +
+	"provenance\x18\a \x01(\v2\x15.jovian.v1.ProvenanceR\n" +
+	"provenance\"\xa2\x01\n" +
+	"\x12WatchEventsRequest\x12)\n" +
+	"\x10interval_minutes\x18\x01 \x01(\x05R\x0fintervalMinutes\x120\n" +
+	"\asources\x18\x02 \x03(\x0e2\x16.jovian.v1.RadioSourceR\asources\x12/\n" +
+	"\bobserver\x18\x03 \x01(\v2\x13.jovian.v1.ObserverR\bobserver\"\xe3\x01\n" +
+	"\vWindowEvent\x12/\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x1b.jovian.v1.WindowEvent.TypeR\x04type\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12)\n" +
+	"\x06window\x18\x03 \x01(\v2\x11.jovian.v1.WindowR\x06window\"H\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11TYPE_WINDOW_START\x10\x01\x12\x13\n" +
+	"\x0fTYPE_WINDOW_END\x10\x02*\x8b\x01\n" +
+	"\vRadioSource\x12\x1c\n" +
+	"\x18RADIO_SOURCE_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11RADIO_SOURCE_IO_A\x10\x01\x12\x15\n" +
+	"\x11RADIO_SOURCE_IO_B\x10\x02\x12\x15\n" +
+	"\x11RADIO_SOURCE_IO_C\x10\x03\x12\x19\n" +
+	"\x15RADIO_SOURCE_NON_IO_A\x10\x042\x9a\x01\n" +
+	"\vJovianNoise\x12C\n" +
+	"\bForecast\x12\x1a.jovian.v1.ForecastRequest\x1a\x1b.jovian.v1.ForecastResponse\x12F\n" +
+	"\vWatchEvents\x12\x1d.jovian.v1.WatchEventsRequest\x1a\x16.jovian.v1.WindowEvent0\x01B'Z%github.com/ctdk/jovian-noise/jovianpbb\x06proto3"
+
+var (
+	file_jovianpb_jovian_proto_rawDescOnce sync.Once
+	file_jovianpb_jovian_proto_rawDescData []byte
+)
+
+func file_jovianpb_jovian_proto_rawDescGZIP() []byte {
+	file_jovianpb_jovian_proto_rawDescOnce.Do(func() {
+		file_jovianpb_jovian_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_jovianpb_jovian_proto_rawDesc), len(file_jovianpb_jovian_proto_rawDesc)))
+	})
+	return file_jovianpb_jovian_proto_rawDescData
+}
+
+var file_jovianpb_jovian_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_jovianpb_jovian_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_jovianpb_jovian_proto_goTypes = []any{
+	(RadioSource)(0),              // 0: jovian.v1.RadioSource
+	(WindowEvent_Type)(0),         // 1: jovian.v1.WindowEvent.Type
+	(*Observer)(nil),              // 2: jovian.v1.Observer
+	(*ForecastRequest)(nil),       // 3: jovian.v1.ForecastRequest
+	(*Provenance)(nil),            // 4: jovian.v1.Provenance
+	(*Interval)(nil),              // 5: jovian.v1.Interval
+	(*Window)(nil),                // 6: jovian.v1.Window
+	(*ForecastResponse)(nil),      // 7: jovian.v1.ForecastResponse
+	(*WatchEventsRequest)(nil),    // 8: jovian.v1.WatchEventsRequest
+	(*WindowEvent)(nil),           // 9: jovian.v1.WindowEvent
+	(*timestamppb.Timestamp)(nil), // 10: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),   // 11: google.protobuf.Duration
+}
+var file_jovianpb_jovian_proto_depIdxs = []int32{
+	10, // 0: jovian.v1.ForecastRequest.start:type_name -> google.protobuf.Timestamp
+	11, // 1: jovian.v1.ForecastRequest.duration:type_name -> google.protobuf.Duration
+	0,  // 2: jovian.v1.ForecastRequest.sources:type_name -> jovian.v1.RadioSource
+	2,  // 3: jovian.v1.ForecastRequest.observer:type_name -> jovian.v1.Observer
+	10, // 4: jovian.v1.Provenance.generated_at:type_name -> google.protobuf.Timestamp
+	10, // 5: jovian.v1.Interval.instant:type_name -> google.protobuf.Timestamp
+	0,  // 6: jovian.v1.Interval.radio_source:type_name -> jovian.v1.RadioSource
+	10, // 7: jovian.v1.Window.start:type_name -> google.protobuf.Timestamp
+	10, // 8: jovian.v1.Window.end:type_name -> google.protobuf.Timestamp
+	0,  // 9: jovian.v1.Window.radio_source:type_name -> jovian.v1.RadioSource
+	10, // 10: jovian.v1.ForecastResponse.start:type_name -> google.protobuf.Timestamp
+	10, // 11: jovian.v1.ForecastResponse.end:type_name -> google.protobuf.Timestamp
+	11, // 12: jovian.v1.ForecastResponse.interval:type_name -> google.protobuf.Duration
+	2,  // 13: jovian.v1.ForecastResponse.observer:type_name -> jovian.v1.Observer
+	5,  // 14: jovian.v1.ForecastResponse.intervals:type_name -> jovian.v1.Interval
+	6,  // 15: jovian.v1.ForecastResponse.windows:type_name -> jovian.v1.Window
+	4,  // 16: jovian.v1.ForecastResponse.provenance:type_name -> jovian.v1.Provenance
+	0,  // 17: jovian.v1.WatchEventsRequest.sources:type_name -> jovian.v1.RadioSource
+	2,  // 18: jovian.v1.WatchEventsRequest.observer:type_name -> jovian.v1.Observer
+	1,  // 19: jovian.v1.WindowEvent.type:type_name -> jovian.v1.WindowEvent.Type
+	10, // 20: jovian.v1.WindowEvent.time:type_name -> google.protobuf.Timestamp
+	6,  // 21: jovian.v1.WindowEvent.window:type_name -> jovian.v1.Window
+	3,  // 22: jovian.v1.JovianNoise.Forecast:input_type -> jovian.v1.ForecastRequest
+	8,  // 23: jovian.v1.JovianNoise.WatchEvents:input_type -> jovian.v1.WatchEventsRequest
+	7,  // 24: jovian.v1.JovianNoise.Forecast:output_type -> jovian.v1.ForecastResponse
+	9,  // 25: jovian.v1.JovianNoise.WatchEvents:output_type -> jovian.v1.WindowEvent
+	24, // [24:26] is the sub-list for method output_type
+	22, // [22:24] is the sub-list for method input_type
+	22, // [22:22] is the sub-list for extension type_name
+	22, // [22:22] is the sub-list for extension extendee
+	0,  // [0:22] is the sub-list for field type_name
+}
+
+func init() { file_jovianpb_jovian_proto_init() }
+func file_jovianpb_jovian_proto_init() {
+	if File_jovianpb_jovian_proto != nil {
+		return
+	}
+	file_jovianpb_jovian_proto_msgTypes[3].OneofWrappers = []any{}
+	file_jovianpb_jovian_proto_msgTypes[4].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_jovianpb_jovian_proto_rawDesc), len(file_jovianpb_jovian_proto_rawDesc)),
+			NumEnums:      2,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_jovianpb_jovian_proto_goTypes,
+		DependencyIndexes: file_jovianpb_jovian_proto_depIdxs,
+		EnumInfos:         file_jovianpb_jovian_proto_enumTypes,
+		MessageInfos:      file_jovianpb_jovian_proto_msgTypes,
+	}.Build()
+	File_jovianpb_jovian_proto = out.File
+	file_jovianpb_jovian_proto_goTypes = nil
+	file_jovianpb_jovian_proto_depIdxs = nil
+}
@@ -0,0 +1,176 @@
+// The gRPC interface to jovian-noise's Jupiter decameter radio storm
+// forecasts. Run 'jovian-noise grpc' to serve it.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             v7.35.1
+// source: jovianpb/jovian.proto
+
+package jovianpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	JovianNoise_Forecast_FullMethodName    = "/jovian.v1.JovianNoise/Forecast"
+	JovianNoise_WatchEvents_FullMethodName = "/jovian.v1.JovianNoise/WatchEvents"
+)
+
+// JovianNoiseClient is the client API for JovianNoise service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type JovianNoiseClient interface {
+	// Forecast calculates a forecast, like '-output json'.
+	Forecast(ctx context.Context, in *ForecastRequest, opts ...grpc.CallOption) (*ForecastResponse, error)
+	// WatchEvents sends an event whenever a forecast window starts or ends,
+	// as the server's clock reaches it. Windows already under way when the
+	// call starts are sent straight away. The stream runs until the client
+	// cancels it.
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WindowEvent], error)
+}
+
+type jovianNoiseClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewJovianNoiseClient(cc grpc.ClientConnInterface) JovianNoiseClient {
+	return &jovianNoiseClient{cc}
+}
+
+func (c *jovianNoiseClient) Forecast(ctx context.Context, in *ForecastRequest, opts ...grpc.CallOption) (*ForecastResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ForecastResponse)
+	err := c.cc.Invoke(ctx, JovianNoise_Forecast_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *jovianNoiseClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WindowEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &JovianNoise_ServiceDesc.Streams[0], JovianNoise_WatchEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchEventsRequest, WindowEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JovianNoise_WatchEventsClient = grpc.ServerStreamingClient[WindowEvent]
+
+// JovianNoiseServer is the server API for JovianNoise service.
+// All implementations must embed UnimplementedJovianNoiseServer
+// for forward compatibility.
+type JovianNoiseServer interface {
+	// Forecast calculates a forecast, like '-output json'.
+	Forecast(context.Context, *ForecastRequest) (*ForecastResponse, error)
+	// WatchEvents sends an event whenever a forecast window starts or ends,
+	// as the server's clock reaches it. Windows already under way when the
+	// call starts are sent straight away. The stream runs until the client
+	// cancels it.
+	WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[WindowEvent]) error
+	mustEmbedUnimplementedJovianNoiseServer()
+}
+
+// UnimplementedJovianNoiseServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedJovianNoiseServer struct{}
+
+func (UnimplementedJovianNoiseServer) Forecast(context.Context, *ForecastRequest) (*ForecastResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Forecast not implemented")
+}
+func (UnimplementedJovianNoiseServer) WatchEvents(*WatchEventsRequest, grpc.ServerStreamingServer[WindowEvent]) error {
+	return status.Error(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedJovianNoiseServer) mustEmbedUnimplementedJovianNoiseServer() {}
+func (UnimplementedJovianNoiseServer) testEmbeddedByValue()                     {}
+
+// UnsafeJovianNoiseServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to JovianNoiseServer will
+// result in compilation errors.
+type UnsafeJovianNoiseServer interface {
+	mustEmbedUnimplementedJovianNoiseServer()
+}
+
+func RegisterJovianNoiseServer(s grpc.ServiceRegistrar, srv JovianNoiseServer) {
+	// If the following call panics, it indicates UnimplementedJovianNoiseServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&JovianNoise_ServiceDesc, srv)
+}
+
+func _JovianNoise_Forecast_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ForecastRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JovianNoiseServer).Forecast(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JovianNoise_Forecast_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JovianNoiseServer).Forecast(ctx, req.(*ForecastRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JovianNoise_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(JovianNoiseServer).WatchEvents(m, &grpc.GenericServerStream[WatchEventsRequest, WindowEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type JovianNoise_WatchEventsServer = grpc.ServerStreamingServer[WindowEvent]
+
+// JovianNoise_ServiceDesc is the grpc.ServiceDesc for JovianNoise service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var JovianNoise_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "jovian.v1.JovianNoise",
+	HandlerType: (*JovianNoiseServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Forecast",
+			Handler:    _JovianNoise_Forecast_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _JovianNoise_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "jovianpb/jovian.proto",
+}
@@ -1,21 +1,22 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/soniakeys/meeus/v3/globe"
 	"github.com/soniakeys/unit"
 	"math"
+	"sort"
+	"strings"
 	"time"
 )
 
 type jupiterPosition struct {
-	EntryDate time.Time  `json:"entry_date"`
-	Rising    unit.Time  `json:"rising"`
-	Transit   unit.Time  `json:"transit"`
-	Set       unit.Time  `json:"set"`
-	RA        unit.RA    `json:"ra"`
-	Dec       unit.Angle `json:"dec"`
+	EntryDate time.Time
+	Rising    unit.Time
+	Transit   unit.Time
+	Set       unit.Time
+	RA        unit.RA
+	Dec       unit.Angle
 }
 
 type radioSource int
@@ -39,36 +40,70 @@ const dayUnitTime unit.Time = 24 * 60 * 60 // 86400
 const recommendCutoff float64 = 3.0
 
 type hzCoords struct {
-	Altitude unit.Angle `json:"altitude"`
-	Azimuth  unit.Angle `json:"azimuth"`
+	Altitude unit.Angle
+	Azimuth  unit.Angle
 }
 
 type jupiterData struct {
-	StartTime        time.Time                   `json:"start_time"`
-	EndTime          time.Time                   `json:"end_time"`
-	Duration         time.Duration               `json:"duration"`
-	Interval         int                         `json:"interval"`
-	Coords           globe.Coord                 `json:"coords"`
-	LocalForecast    bool                        `json:"local_forecast"`
-	Location         *time.Location              `json:"location_data"`
-	JupiterPositions map[string]*jupiterPosition `json:"jupiter_positions,omitempty"`
-	Intervals        []*forecastInterval         `json:"intervals"`
+	StartTime        time.Time
+	EndTime          time.Time
+	Duration         time.Duration
+	Interval         int
+	Coords           globe.Coord
+	LocalForecast    bool
+	Sources          []radioSource
+	Location         *time.Location
+	JupiterPositions map[string]*jupiterPosition
+	Intervals        []*forecastInterval
+	Provenance       *forecastProvenance
+}
+
+// forecastProvenance records what produced a forecast. It's kept with
+// forecasts loaded from JSON, so re-rendering a saved forecast doesn't
+// change where it says it came from.
+type forecastProvenance struct {
+	Program     string
+	Version     string
+	GeneratedAt time.Time
+	Ephemeris   string
 }
 
 type forecastInterval struct {
-	Instant     time.Time      `json:"instant"`
-	IoPhase     unit.Angle     `json:"io_phase"`
-	Meridian    unit.Angle     `json:"meridian"`
-	Distance    float64        `json:"distance"`
-	RadioSource radioSource    `json:"radio_source"`
-	TransitHA   unit.HourAngle `json:"transit_ha"`
-	AltAz       *hzCoords      `json:"altaz,omitempty"`
+	Instant     time.Time
+	IoPhase     unit.Angle
+	Meridian    unit.Angle
+	Distance    float64
+	RadioSource radioSource
+	TransitHA   unit.HourAngle
+	AltAz       *hzCoords
 }
 
 func (s radioSource) String() string {
 	return radioSourceNames[s-1]
 }
 
+func (s radioSource) MarshalText() ([]byte, error) {
+	if s == NoEvent {
+		return nil, fmt.Errorf("No radio source to marshal.")
+	}
+	return []byte(s.String()), nil
+}
+
+func (s *radioSource) UnmarshalText(text []byte) error {
+	rs, err := RadioSourceFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*s = rs
+	return nil
+}
+
+// slug returns a lower case name for the radio source that's safe to use in
+// identifiers, file names, and the like.
+func (s radioSource) slug() string {
+	return strings.ToLower(s.String())
+}
+
 func RadioSourceFromString(rs string) (radioSource, error) {
 	var source radioSource
 	for k, v := range radioSourceNames {
@@ -93,34 +128,14 @@ func (fi *forecastInterval) Recommended() bool {
 	return math.Abs(float64(fi.TransitHA.Hour())) < recommendCutoff
 }
 
-func (fi *forecastInterval) MarshalJSON() ([]byte, error) {
-	type Alias forecastInterval
-	return json.Marshal(&struct {
-		RadioSource string `json:"radio_source"`
-		*Alias
-	}{
-		RadioSource: fi.RadioSource.String(),
-		Alias:       (*Alias)(fi),
-	})
-}
-
-func (fi *forecastInterval) UnmarshalJSON(data []byte) error {
-	type Jfi forecastInterval
-	nj := &struct {
-		RadioSource string `json:"radio_source"`
-		*Jfi
-	}{
-		Jfi: (*Jfi)(fi),
-	}
-	if err := json.Unmarshal(data, &nj); err != nil {
-		return err
-	}
-	rs, err := RadioSourceFromString(nj.RadioSource)
-	if err != nil {
-		return err
+// includesSource returns true if the forecast is for the given radio source.
+func (jd *jupiterData) includesSource(rs radioSource) bool {
+	for _, s := range jd.Sources {
+		if s == rs {
+			return true
+		}
 	}
-	fi.RadioSource = rs
-	return nil
+	return false
 }
 
 func (jd *jupiterData) GetCorrectTransit(entryDate time.Time) (unit.Time, error) {
@@ -164,3 +179,78 @@ func (jd *jupiterData) GetCorrectTransit(entryDate time.Time) (unit.Time, error)
 
 	return trn, nil
 }
+
+// displayLocation returns the time zone results should be displayed in, which
+// is UTC if no other time zone was given.
+func (jd *jupiterData) displayLocation() *time.Location {
+	if jd.Location == nil {
+		return time.UTC
+	}
+	return jd.Location
+}
+
+// zoneInfo returns the name of the display time zone and its offset from UTC
+// at the start of the forecast, or empty strings if no time zone was given.
+func (jd *jupiterData) zoneInfo() (string, string) {
+	if jd.Location == nil {
+		return "", ""
+	}
+	var name string
+	ztz, zoff := jd.StartTime.In(jd.Location).Zone()
+	if jd.Location != time.Local {
+		name = jd.Location.String()
+	} else {
+		name = ztz
+	}
+
+	zhours := zoff / 60 / 60
+	zmin := zoff / 60 % 60
+	if zmin < 0 {
+		zmin = -zmin
+	}
+	return name, fmt.Sprintf("%+03d%02d", zhours, zmin)
+}
+
+// displayCoords returns the observer's latitude and longitude in whole
+// degrees, with longitude east of Greenwich positive like everyone else
+// expects.
+func (jd *jupiterData) displayCoords() (int, int) {
+	lat := int(jd.Coords.Lat.Deg())
+	lon := -int(jd.Coords.Lon.Deg())
+	if lon < -180 {
+		lon += 360
+	}
+	return lat, lon
+}
+
+// jupiterUp returns the spans of time Jupiter is above the horizon, as
+// calculated from the precalculated Jupiter positions. Only available for
+// local forecasts.
+func (jd *jupiterData) jupiterUp() []timeSpan {
+	spans := make([]timeSpan, 0)
+	if !jd.LocalForecast {
+		return spans
+	}
+
+	keys := make([]string, 0, len(jd.JupiterPositions))
+	for k := range jd.JupiterPositions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	secs := func(t unit.Time) time.Duration {
+		return time.Duration(t) * time.Second
+	}
+
+	for _, k := range keys {
+		jp := jd.JupiterPositions[k]
+		day := jp.EntryDate
+		if jp.Rising < jp.Set {
+			spans = appendSpan(spans, timeSpan{day.Add(secs(jp.Rising)), day.Add(secs(jp.Set))})
+		} else {
+			spans = appendSpan(spans, timeSpan{day, day.Add(secs(jp.Set))})
+			spans = appendSpan(spans, timeSpan{day.Add(secs(jp.Rising)), day.Add(oneDay)})
+		}
+	}
+	return spans
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsScheduleHorizon is how far ahead to look for each source's next
+// window. Sources with no window in that time don't get a
+// jovian_next_window_seconds sample.
+const metricsScheduleHorizon = 14 * oneDay
+
+// metricsExporter answers Prometheus scrapes with the state at the time of
+// the scrape.
+type metricsExporter struct {
+	planets  *planets
+	schedule *windowSchedule
+	base     *jupiterData
+}
+
+// promSample is one sample of a metric, with its labels in order.
+type promSample struct {
+	Labels [][2]string
+	Value  float64
+}
+
+func metricsCommand(args []string) error {
+	flags := flag.NewFlagSet("metrics", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	listen := flags.String("listen", "localhost:9464", "Address to listen for Prometheus scrapes on.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise metrics [options]\n\nServes Prometheus metrics for the current state at '/metrics'.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("metrics doesn't take any arguments.")
+	}
+	if fp.StartTime != "" {
+		return fmt.Errorf("-start-time can't be used with metrics, which always starts now.")
+	}
+	base, err := fp.jupiterData()
+	if err != nil {
+		return err
+	}
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+	me := &metricsExporter{
+		planets:  p,
+		schedule: &windowSchedule{Planets: p, Params: *fp, Horizon: metricsScheduleHorizon, Refresh: time.Hour},
+		base:     base,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", me)
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      time.Minute,
+	}
+	log.Printf("jovian-noise %s serving metrics on http://%s/metrics", version, *listen)
+	return srv.ListenAndServe()
+}
+
+func (me *metricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b bytes.Buffer
+	if err := me.write(&b, time.Now()); err != nil {
+		log.Printf("Error gathering metrics: %s", err)
+		http.Error(w, "Error gathering metrics.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// write writes the metrics for the given time in the Prometheus text
+// exposition format.
+func (me *metricsExporter) write(w io.Writer, now time.Time) error {
+	var observer = &me.base.Coords
+	if !me.base.LocalForecast {
+		observer = nil
+	}
+	st := me.planets.stateAt(now, observer)
+	_, next, err := me.schedule.next(now)
+	if err != nil {
+		return err
+	}
+
+	writeGauge(w, "jovian_cml_degrees", "Jupiter's System III central meridian longitude.", promSample{Value: st.Meridian.Deg()})
+	writeGauge(w, "jovian_io_phase_degrees", "Io's orbital phase.", promSample{Value: st.IoPhase.Deg()})
+	writeGauge(w, "jovian_distance_au", "Distance between Earth and Jupiter.", promSample{Value: st.Distance})
+	if st.AltAz != nil {
+		writeGauge(w, "jovian_altitude_degrees", "Jupiter's altitude above the observer's horizon.", promSample{Value: st.AltAz.Altitude.Deg()})
+		writeGauge(w, "jovian_azimuth_degrees", "Jupiter's azimuth from the observer, east of north.", promSample{Value: st.AltAz.Azimuth.Deg()})
+	}
+
+	active := make([]promSample, 0, len(me.base.Sources))
+	nextSecs := make([]promSample, 0, len(me.base.Sources))
+	for _, rs := range me.base.Sources {
+		labels := [][2]string{{"source", rs.String()}}
+		var v float64
+		if st.RadioSource == rs {
+			v = 1
+		}
+		active = append(active, promSample{labels, v})
+		if fw, ok := next[rs]; ok {
+			nextSecs = append(nextSecs, promSample{labels, fw.Start.Sub(now).Seconds()})
+		}
+	}
+	writeGauge(w, "jovian_active_source", "1 if the CML and Io phase are in this radio source's region, 0 if not.", active...)
+	writeGauge(w, "jovian_next_window_seconds", "Seconds until this radio source's next forecast window starts.", nextSecs...)
+	writeGauge(w, "jovian_build_info", "The jovian-noise version.", promSample{[][2]string{{"version", version}}, 1})
+	return nil
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writeGauge writes a gauge and its samples. Gauges with no samples are left
+// out.
+func writeGauge(w io.Writer, name string, help string, samples ...promSample) {
+	if len(samples) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	for _, s := range samples {
+		var labels string
+		if len(s.Labels) > 0 {
+			l := make([]string, 0, len(s.Labels))
+			for _, kv := range s.Labels {
+				l = append(l, fmt.Sprintf(`%s="%s"`, kv[0], promLabelEscaper.Replace(kv[1])))
+			}
+			labels = "{" + strings.Join(l, ",") + "}"
+		}
+		fmt.Fprintf(w, "%s%s %s\n", name, labels, strconv.FormatFloat(s.Value, 'g', -1, 64))
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// promLine matches a sample line, with its labels if it has any.
+var promLine = regexp.MustCompile(`^([a-z_]+)(\{(?:[a-z_]+="(?:[^"\\]|\\.)*",?)*\})? (\S+)$`)
+
+// checkPromText checks that the metrics are in the text exposition format,
+// with each gauge's HELP and TYPE lines before its samples, and returns the
+// sample lines.
+func checkPromText(t *testing.T, text string) []string {
+	t.Helper()
+	if !strings.HasSuffix(text, "\n") {
+		t.Errorf("the metrics don't end with a newline")
+	}
+	var samples []string
+	help := make(map[string]bool)
+	typed := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		switch f := strings.Fields(line); {
+		case strings.HasPrefix(line, "# HELP "):
+			if len(f) < 4 || help[f[2]] {
+				t.Errorf("bad or repeated HELP line %q", line)
+				continue
+			}
+			help[f[2]] = true
+		case strings.HasPrefix(line, "# TYPE "):
+			if len(f) != 4 || f[3] != "gauge" || !help[f[2]] || typed[f[2]] {
+				t.Errorf("TYPE line %q isn't a gauge's, right after its HELP", line)
+				continue
+			}
+			typed[f[2]] = true
+		default:
+			m := promLine.FindStringSubmatch(line)
+			if m == nil {
+				t.Errorf("bad sample line %q", line)
+				continue
+			}
+			if !typed[m[1]] {
+				t.Errorf("sample %q comes before its HELP and TYPE lines", line)
+			}
+			samples = append(samples, line)
+		}
+	}
+	return samples
+}
+
+func TestWriteGauge(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []promSample
+		want    string
+	}{
+		{
+			"no labels",
+			[]promSample{{Value: 200.25}},
+			"# HELP test_gauge A test gauge.\n# TYPE test_gauge gauge\ntest_gauge 200.25\n",
+		},
+		{
+			"labels",
+			[]promSample{{[][2]string{{"source", "Io-A"}}, 1}, {[][2]string{{"source", "Io-B"}, {"band", "HF"}}, 0}},
+			"# HELP test_gauge A test gauge.\n# TYPE test_gauge gauge\ntest_gauge{source=\"Io-A\"} 1\ntest_gauge{source=\"Io-B\",band=\"HF\"} 0\n",
+		},
+		{
+			"escaped labels",
+			[]promSample{{[][2]string{{"path", `C:\vsop87`}, {"quote", `say "Io"`}, {"lines", "one\ntwo"}}, -1.5e-07}},
+			"# HELP test_gauge A test gauge.\n# TYPE test_gauge gauge\ntest_gauge{path=\"C:\\\\vsop87\",quote=\"say \\\"Io\\\"\",lines=\"one\\ntwo\"} -1.5e-07\n",
+		},
+		{
+			"no samples",
+			nil,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		writeGauge(&b, "test_gauge", "A test gauge.", tt.samples...)
+		if b.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b.String(), tt.want)
+			continue
+		}
+		if tt.want == "" {
+			continue
+		}
+		if got := checkPromText(t, b.String()); len(got) != len(tt.samples) {
+			t.Errorf("%s: got %d samples, want %d", tt.name, len(got), len(tt.samples))
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	if os.Getenv("VSOP87") == "" {
+		t.Skip("VSOP87 isn't set")
+	}
+	p, err := newPlanets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := forecastParams{Duration: oneDay, Interval: defaultInterval, Lat: 40, Lon: -105, LatSet: true, LonSet: true}
+	base, err := fp.jupiterData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	me := &metricsExporter{
+		planets:  p,
+		schedule: &windowSchedule{Planets: p, Params: fp, Horizon: metricsScheduleHorizon, Refresh: time.Hour},
+		base:     base,
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", me)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status is %d: %s", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type is %q", ct)
+	}
+
+	samples := checkPromText(t, string(body))
+	names := make(map[string]int)
+	for _, s := range samples {
+		names[promLine.FindStringSubmatch(s)[1]]++
+	}
+	for _, name := range []string{"jovian_cml_degrees", "jovian_io_phase_degrees", "jovian_distance_au", "jovian_altitude_degrees", "jovian_azimuth_degrees", "jovian_build_info"} {
+		if names[name] != 1 {
+			t.Errorf("got %d %s samples, want 1", names[name], name)
+		}
+	}
+	if n := names["jovian_active_source"]; n != len(base.Sources) {
+		t.Errorf("got %d jovian_active_source samples, want one for each of the %d sources", n, len(base.Sources))
+	}
+	if !strings.Contains(string(body), `jovian_active_source{source="Io-A"} `) {
+		t.Errorf("there's no jovian_active_source sample for Io-A:\n%s", body)
+	}
+	if !strings.Contains(string(body), "jovian_build_info{version=\""+version+"\"} 1\n") {
+		t.Errorf("jovian_build_info is wrong:\n%s", body)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// mqttScheduleHorizon is how far ahead to look for the next window.
+const mqttScheduleHorizon = 8 * oneDay
+
+// jsonState is the JSON for a jovianState, plus the next window.
+type jsonState struct {
+	Time        time.Time   `json:"time"`
+	CMLDeg      float64     `json:"cml_deg"`
+	IoPhaseDeg  float64     `json:"io_phase_deg"`
+	DistanceAU  float64     `json:"distance_au"`
+	RadioSource string      `json:"radio_source"`
+	AltitudeDeg *float64    `json:"altitude_deg,omitempty"`
+	AzimuthDeg  *float64    `json:"azimuth_deg,omitempty"`
+	NextWindow  *jsonWindow `json:"next_window,omitempty"`
+}
+
+// mqttPublisher publishes the current state and window events under a topic
+// prefix.
+type mqttPublisher struct {
+	client mqtt.Client
+	prefix string
+	qos    byte
+}
+
+func mqttCommand(args []string) error {
+	flags := flag.NewFlagSet("mqtt", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	broker := flags.String("broker", "tcp://localhost:1883", "MQTT broker to publish to. The password, if needed, is read from the MQTT_PASSWORD environment variable.")
+	prefix := flags.String("topic", "jovian-noise", "Topic prefix to publish under, such as 'observatory/jupiter'.")
+	clientID := flags.String("client-id", "", "MQTT client ID (defaults to 'jovian-noise-' and the host name).")
+	username := flags.String("username", "", "Optional MQTT username.")
+	qos := flags.Int("qos", 1, "MQTT quality of service level to publish with (0, 1, or 2).")
+	every := flags.Duration("every", time.Minute, "How often to publish the current state.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise mqtt [options]\n\nRuns until stopped, publishing the current state to retained topics and window events as they happen.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("mqtt doesn't take any arguments.")
+	}
+	if fp.StartTime != "" {
+		return fmt.Errorf("-start-time can't be used with mqtt, which always starts now.")
+	}
+	if *qos < 0 || *qos > 2 {
+		return fmt.Errorf("-qos must be 0, 1, or 2.")
+	}
+	if *every < time.Second {
+		return fmt.Errorf("-every must be at least a second.")
+	}
+	base, err := fp.jupiterData()
+	if err != nil {
+		return err
+	}
+	if *clientID == "" {
+		host, _ := os.Hostname()
+		*clientID = "jovian-noise-" + host
+	}
+
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+
+	mp := &mqttPublisher{prefix: *prefix, qos: byte(*qos)}
+	opts := mqtt.NewClientOptions().AddBroker(*broker).SetClientID(*clientID).SetAutoReconnect(true)
+	opts.SetWill(mp.topic("status"), "offline", mp.qos, true)
+	if *username != "" {
+		opts.SetUsername(*username).SetPassword(os.Getenv("MQTT_PASSWORD"))
+	}
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		log.Printf("Connected to MQTT broker %s.", *broker)
+		mp.publish("status", "online", true)
+	})
+	mp.client = mqtt.NewClient(opts)
+	if tok := mp.client.Connect(); tok.WaitTimeout(30*time.Second) && tok.Error() != nil {
+		return fmt.Errorf("Error connecting to MQTT broker %s: %s", *broker, tok.Error())
+	} else if !mp.client.IsConnectionOpen() {
+		return fmt.Errorf("Timed out connecting to MQTT broker %s.", *broker)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	schedule := &windowSchedule{Planets: p, Params: *fp, Horizon: mqttScheduleHorizon, Refresh: time.Hour}
+	ww := &windowWatcher{Params: fp, Leads: []time.Duration{0}, Horizon: 2 * oneDay, Step: oneDay, Calculate: p.forecast}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- ww.Run(ctx, func(ev *windowEvent) error {
+			msg, err := eventMessage(ev)
+			if err != nil {
+				return err
+			}
+			mp.publish(msg.Topic, msg.Payload, msg.Retained)
+			return nil
+		})
+	}()
+
+	ticker := time.NewTicker(*every)
+	defer ticker.Stop()
+	for {
+		if err := mp.publishState(p, schedule, base); err != nil {
+			log.Printf("Error publishing state: %s", err)
+		}
+		select {
+		case <-ticker.C:
+		case err = <-errs:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	mp.publish("status", "offline", true).WaitTimeout(5 * time.Second)
+	mp.client.Disconnect(1000)
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Stopping.")
+		return nil
+	}
+	return err
+}
+
+// mqttMessage is a message to publish, to a topic under the prefix.
+type mqttMessage struct {
+	Topic    string
+	Payload  string
+	Retained bool
+}
+
+// publishState publishes the state right now.
+func (mp *mqttPublisher) publishState(p *planets, schedule *windowSchedule, base *jupiterData) error {
+	now := time.Now()
+	var observer = &base.Coords
+	if !base.LocalForecast {
+		observer = nil
+	}
+	st := p.stateAt(now, observer)
+	next, _, err := schedule.next(now)
+	if err != nil {
+		return err
+	}
+	msgs, err := stateMessages(st, next, base)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		mp.publish(msg.Topic, msg.Payload, msg.Retained)
+	}
+	return nil
+}
+
+// stateMessages builds the messages for a state and the next window, if
+// there is one: the whole state as one JSON message, and a separate topic for
+// each value. They're all retained, so anything subscribing gets the latest
+// values straight away.
+func stateMessages(st *jovianState, next *forecastWindow, base *jupiterData) ([]*mqttMessage, error) {
+	js := &jsonState{Time: st.Time.UTC(), CMLDeg: st.Meridian.Deg(), IoPhaseDeg: st.IoPhase.Deg(), DistanceAU: st.Distance, RadioSource: "none"}
+	if st.RadioSource != NoEvent && base.includesSource(st.RadioSource) {
+		js.RadioSource = st.RadioSource.String()
+	}
+	if st.AltAz != nil {
+		alt, az := st.AltAz.Altitude.Deg(), st.AltAz.Azimuth.Deg()
+		js.AltitudeDeg, js.AzimuthDeg = &alt, &az
+	}
+	if next != nil {
+		js.NextWindow = newJSONWindow(next)
+	}
+
+	j, err := json.Marshal(js)
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]*mqttMessage, 0)
+	add := func(topic string, payload string) {
+		msgs = append(msgs, &mqttMessage{Topic: topic, Payload: payload, Retained: true})
+	}
+	add("state", string(j))
+	add("cml_deg", strconv.FormatFloat(js.CMLDeg, 'f', 2, 64))
+	add("io_phase_deg", strconv.FormatFloat(js.IoPhaseDeg, 'f', 2, 64))
+	add("distance_au", strconv.FormatFloat(js.DistanceAU, 'f', 4, 64))
+	add("radio_source", js.RadioSource)
+	if st.AltAz != nil {
+		add("altitude_deg", strconv.FormatFloat(*js.AltitudeDeg, 'f', 2, 64))
+		add("azimuth_deg", strconv.FormatFloat(*js.AzimuthDeg, 'f', 2, 64))
+	}
+	if next != nil {
+		add("next_window_start", next.Start.UTC().Format(time.RFC3339))
+	}
+	return msgs, nil
+}
+
+// eventMessage builds the message for a window event. Events aren't
+// retained, since they're only news when they happen.
+func eventMessage(ev *windowEvent) (*mqttMessage, error) {
+	j, err := json.Marshal(newJSONAlert(ev))
+	if err != nil {
+		return nil, err
+	}
+	return &mqttMessage{Topic: "events", Payload: string(j)}, nil
+}
+
+// publish publishes a message to a topic under the prefix. Publishing
+// happens in the background, and any problems are logged.
+func (mp *mqttPublisher) publish(topic string, payload string, retained bool) mqtt.Token {
+	full := mp.topic(topic)
+	tok := mp.client.Publish(full, mp.qos, retained, payload)
+	go func() {
+		if tok.WaitTimeout(30*time.Second) && tok.Error() != nil {
+			log.Printf("Error publishing to %s: %s", full, tok.Error())
+		}
+	}()
+	return tok
+}
+
+// topic returns the full name of a topic under the prefix.
+func (mp *mqttPublisher) topic(name string) string {
+	return mp.prefix + "/" + name
+}
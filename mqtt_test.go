@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/soniakeys/unit"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testJovianState(rs radioSource, observer bool) *jovianState {
+	st := &jovianState{
+		Time:        time.Date(2024, 1, 1, 2, 15, 0, 0, time.FixedZone("MST", -7*3600)),
+		Meridian:    unit.AngleFromDeg(123.456),
+		IoPhase:     unit.AngleFromDeg(87.654),
+		Distance:    4.56789,
+		RadioSource: rs,
+	}
+	if observer {
+		st.AltAz = &hzCoords{Altitude: unit.AngleFromDeg(35.5), Azimuth: unit.AngleFromDeg(181.25)}
+	}
+	return st
+}
+
+func mqttMessageMap(msgs []*mqttMessage) map[string]*mqttMessage {
+	m := make(map[string]*mqttMessage, len(msgs))
+	for _, msg := range msgs {
+		m[msg.Topic] = msg
+	}
+	return m
+}
+
+func TestMQTTStateMessages(t *testing.T) {
+	base := &jupiterData{Sources: []radioSource{IoA, IoB, IoC}}
+	next := testWindowEvent(windowOpens, 0).Window
+	msgs, err := stateMessages(testJovianState(IoB, true), next, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"cml_deg":           "123.46",
+		"io_phase_deg":      "87.65",
+		"distance_au":       "4.5679",
+		"radio_source":      "Io-B",
+		"altitude_deg":      "35.50",
+		"azimuth_deg":       "181.25",
+		"next_window_start": "2024-01-01T02:00:00Z",
+	}
+	got := mqttMessageMap(msgs)
+	if len(got) != len(want)+1 {
+		t.Errorf("got %d topics, want %d", len(got), len(want)+1)
+	}
+	for topic, payload := range want {
+		msg, ok := got[topic]
+		if !ok {
+			t.Errorf("no %s message", topic)
+			continue
+		}
+		if msg.Payload != payload {
+			t.Errorf("%s is %q, want %q", topic, msg.Payload, payload)
+		}
+	}
+	for _, msg := range msgs {
+		if !msg.Retained {
+			t.Errorf("%s isn't retained", msg.Topic)
+		}
+	}
+
+	state, ok := got["state"]
+	if !ok {
+		t.Fatal("no state message")
+	}
+	js := new(jsonState)
+	if err := json.Unmarshal([]byte(state.Payload), js); err != nil {
+		t.Fatalf("state isn't JSON: %s", err)
+	}
+	if !js.Time.Equal(time.Date(2024, 1, 1, 9, 15, 0, 0, time.UTC)) || js.Time.Location() != time.UTC {
+		t.Errorf("state time is %s, want it in UTC", js.Time)
+	}
+	if js.RadioSource != "Io-B" || js.AltitudeDeg == nil || js.NextWindow == nil || js.NextWindow.RadioSource != IoB {
+		t.Errorf("state is missing values: %s", state.Payload)
+	}
+}
+
+func TestMQTTStateMessagesWithoutObserver(t *testing.T) {
+	// Io-B isn't one of the forecast's sources, so it's reported as none
+	base := &jupiterData{Sources: []radioSource{IoA}}
+	msgs, err := stateMessages(testJovianState(IoB, false), nil, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := mqttMessageMap(msgs)
+	for _, topic := range []string{"altitude_deg", "azimuth_deg", "next_window_start"} {
+		if _, ok := got[topic]; ok {
+			t.Errorf("got a %s message without an observer or next window", topic)
+		}
+	}
+	if rs := got["radio_source"]; rs == nil || rs.Payload != "none" {
+		t.Errorf("radio_source should be none for a source that isn't forecast")
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(got["state"].Payload), &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"altitude_deg", "azimuth_deg", "next_window"} {
+		if _, ok := fields[f]; ok {
+			t.Errorf("state has %s without an observer or next window", f)
+		}
+	}
+}
+
+func TestMQTTEventMessage(t *testing.T) {
+	msg, err := eventMessage(testWindowEvent(windowCloses, 10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Topic != "events" || msg.Retained {
+		t.Errorf("event message is on %s (retained %t), want events, not retained", msg.Topic, msg.Retained)
+	}
+	ja := new(jsonAlert)
+	if err := json.Unmarshal([]byte(msg.Payload), ja); err != nil {
+		t.Fatal(err)
+	}
+	if ja.Event != windowCloses || ja.Lead != "PT10M" || ja.RadioSource != IoB {
+		t.Errorf("event payload is wrong: %s", msg.Payload)
+	}
+}
+
+func TestMQTTTopic(t *testing.T) {
+	mp := &mqttPublisher{prefix: "observatory/jupiter"}
+	if got := mp.topic("cml_deg"); got != "observatory/jupiter/cml_deg" {
+		t.Errorf("topic is %q", got)
+	}
+}
+
+// TestMQTTBroker publishes to a real broker, given in MQTT_TEST_BROKER or at
+// localhost:1883, and is skipped if there isn't one.
+func TestMQTTBroker(t *testing.T) {
+	broker := os.Getenv("MQTT_TEST_BROKER")
+	if broker == "" {
+		broker = "tcp://localhost:1883"
+	}
+	u, err := url.Parse(broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.DialTimeout("tcp", u.Host, time.Second)
+	if err != nil {
+		t.Skipf("no MQTT broker at %s: %s", broker, err)
+	}
+	conn.Close()
+
+	connect := func(id string) mqtt.Client {
+		c := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker).SetClientID(id))
+		if tok := c.Connect(); !tok.WaitTimeout(10*time.Second) || tok.Error() != nil {
+			t.Fatalf("couldn't connect to %s: %v", broker, tok.Error())
+		}
+		t.Cleanup(func() { c.Disconnect(100) })
+		return c
+	}
+	prefix := fmt.Sprintf("jovian-noise-test/%d", time.Now().UnixNano())
+
+	var mu sync.Mutex
+	received := make(map[string]string)
+	done := make(chan struct{})
+	msgs, err := stateMessages(testJovianState(IoA, true), nil, &jupiterData{Sources: []radioSource{IoA}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := connect("jovian-noise-test-sub")
+	tok := sub.Subscribe(prefix+"/#", 1, func(_ mqtt.Client, m mqtt.Message) {
+		mu.Lock()
+		defer mu.Unlock()
+		before := len(received)
+		received[m.Topic()] = string(m.Payload())
+		if before < len(msgs) && len(received) == len(msgs) {
+			close(done)
+		}
+	})
+	if !tok.WaitTimeout(10*time.Second) || tok.Error() != nil {
+		t.Fatalf("couldn't subscribe: %v", tok.Error())
+	}
+
+	mp := &mqttPublisher{client: connect("jovian-noise-test-pub"), prefix: prefix, qos: 1}
+	for _, msg := range msgs {
+		// retained messages would hang around on the broker
+		mp.publish(msg.Topic, msg.Payload, false).WaitTimeout(10 * time.Second)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, msg := range msgs {
+		if got := received[mp.topic(msg.Topic)]; got != msg.Payload {
+			t.Errorf("%s is %q, want %q", msg.Topic, got, msg.Payload)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// notifier sends alerts about window events somewhere.
+type notifier interface {
+	Notify(ctx context.Context, ev *windowEvent) error
+	Close() error
+}
+
+// jsonAlert is the JSON description of a window event that notifiers send.
+type jsonAlert struct {
+	Event           windowEventKind `json:"event"`
+	Lead            string          `json:"lead"`
+	Due             time.Time       `json:"due"`
+	RadioSource     radioSource     `json:"radio_source"`
+	Start           time.Time       `json:"start"`
+	End             time.Time       `json:"end"`
+	Duration        string          `json:"duration"`
+	CMLStartDeg     float64         `json:"cml_start_deg"`
+	CMLEndDeg       float64         `json:"cml_end_deg"`
+	IoPhaseStartDeg float64         `json:"io_phase_start_deg"`
+	IoPhaseEndDeg   float64         `json:"io_phase_end_deg"`
+	DistanceAU      float64         `json:"distance_au"`
+	PeakAltitudeDeg *float64        `json:"peak_altitude_deg,omitempty"`
+	Recommended     bool            `json:"recommended"`
+}
+
+func newJSONAlert(ev *windowEvent) *jsonAlert {
+	fw := ev.Window
+	first, last := fw.Intervals[0], fw.Intervals[len(fw.Intervals)-1]
+	ja := &jsonAlert{
+		Event:           ev.Kind,
+		Lead:            isoDuration(ev.Lead),
+		Due:             ev.At,
+		RadioSource:     fw.RadioSource,
+		Start:           fw.Start,
+		End:             fw.End,
+		Duration:        isoDuration(fw.Duration()),
+		CMLStartDeg:     first.Meridian.Deg(),
+		CMLEndDeg:       last.Meridian.Deg(),
+		IoPhaseStartDeg: first.IoPhase.Deg(),
+		IoPhaseEndDeg:   last.IoPhase.Deg(),
+		DistanceAU:      first.Distance,
+		Recommended:     fw.Recommended(),
+	}
+	if peak, ok := fw.PeakAltitude(); ok {
+		p := peak.Deg()
+		ja.PeakAltitudeDeg = &p
+	}
+	return ja
+}
+
+// alertText describes a window event in a line of text, with times in the
+// given time zone. It's meant to be called when the event is due.
+func alertText(ev *windowEvent, loc *time.Location) string {
+	fw := ev.Window
+	// Alerts that were caught up on are sent late, so go by the clock
+	// rather than the lead time.
+	until := time.Until(ev.When()).Round(time.Minute)
+	what := fmt.Sprintf("%s now", ev.Kind)
+	switch {
+	case until > 0:
+		what = fmt.Sprintf("%s in %s", ev.Kind, until)
+	case until < 0 && ev.Kind == windowOpens:
+		what = "is already open"
+	}
+	first, last := fw.Intervals[0], fw.Intervals[len(fw.Intervals)-1]
+	s := fmt.Sprintf("%s window %s (%s - %s, CML %.0fº-%.0fº, Io phase %.0fº-%.0fº", fw.RadioSource, what, fw.Start.In(loc).Format("Jan 02 15:04"), fw.End.In(loc).Format("15:04 MST"), first.Meridian.Deg(), last.Meridian.Deg(), first.IoPhase.Deg(), last.IoPhase.Deg())
+	if peak, ok := fw.PeakAltitude(); ok {
+		s += fmt.Sprintf(", peak altitude %.0fº", peak.Deg())
+	}
+	if fw.Recommended() {
+		s += ", recommended"
+	}
+	return s + ")"
+}
+
+// jsonLinesNotifier writes each alert as a line of JSON.
+type jsonLinesNotifier struct {
+	enc *json.Encoder
+}
+
+func newJSONLinesNotifier(w io.Writer) *jsonLinesNotifier {
+	return &jsonLinesNotifier{enc: json.NewEncoder(w)}
+}
+
+func (n *jsonLinesNotifier) Notify(ctx context.Context, ev *windowEvent) error {
+	return n.enc.Encode(newJSONAlert(ev))
+}
+
+func (n *jsonLinesNotifier) Close() error {
+	return nil
+}
+
+// logNotifier writes each alert as a line of text to a log.
+type logNotifier struct {
+	logger *log.Logger
+	loc    *time.Location
+	file   *os.File
+}
+
+// newLogNotifier appends alerts to the given file, or writes them to stderr
+// if the path is empty.
+func newLogNotifier(path string, loc *time.Location) (*logNotifier, error) {
+	n := &logNotifier{loc: loc}
+	if path == "" {
+		n.logger = log.New(os.Stderr, "", log.LstdFlags)
+		return n, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	n.file = f
+	n.logger = log.New(f, "", log.LstdFlags)
+	return n, nil
+}
+
+func (n *logNotifier) Notify(ctx context.Context, ev *windowEvent) error {
+	n.logger.Print(alertText(ev, n.loc))
+	return nil
+}
+
+func (n *logNotifier) Close() error {
+	if n.file != nil {
+		return n.file.Close()
+	}
+	return nil
+}
+
+// execNotifier runs a shell command for each alert. The alert is passed to
+// the command as JSON on stdin, and in JOVIAN_* environment variables.
+type execNotifier struct {
+	command string
+}
+
+func (n *execNotifier) Notify(ctx context.Context, ev *windowEvent) error {
+	ja := newJSONAlert(ev)
+	j, err := json.Marshal(ja)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", n.command)
+	cmd.Stdin = bytes.NewReader(j)
+	// keep stdout for -json
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"JOVIAN_EVENT="+string(ja.Event),
+		"JOVIAN_LEAD="+ja.Lead,
+		"JOVIAN_LEAD_SECONDS="+strconv.Itoa(int(ev.Lead.Seconds())),
+		"JOVIAN_SOURCE="+ja.RadioSource.String(),
+		"JOVIAN_START="+ja.Start.Format(time.RFC3339),
+		"JOVIAN_END="+ja.End.Format(time.RFC3339),
+		"JOVIAN_RECOMMENDED="+strconv.FormatBool(ja.Recommended),
+	)
+	if ja.PeakAltitudeDeg != nil {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("JOVIAN_PEAK_ALTITUDE_DEG=%.1f", *ja.PeakAltitudeDeg))
+	}
+
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("Alert command '%s' failed: %s", n.command, err)
+	}
+	return nil
+}
+
+func (n *execNotifier) Close() error {
+	return nil
+}
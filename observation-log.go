@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const defaultObservationLog = "observations.jsonl"
+
+// Kinds of observation. A detection is when something was heard, and a
+// session is when a station was listening, whether or not anything was
+// heard, so verify can tell a quiet night from a night nobody listened.
+const (
+	observationDetection = "detection"
+	observationSession   = "session"
+)
+
+// observation is one entry in the observation log. Intensity is on whatever
+// scale the station uses, like dB over the noise, or 1 to 5.
+type observation struct {
+	ID          int       `json:"id"`
+	Kind        string    `json:"kind"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	FrequencyHz int64     `json:"frequency_hz,omitempty"`
+	Station     string    `json:"station,omitempty"`
+	Intensity   *float64  `json:"intensity,omitempty"`
+	Notes       string    `json:"notes,omitempty"`
+	Logged      time.Time `json:"logged"`
+}
+
+// observationLog is the file observations are kept in, one JSON object a
+// line. New observations are appended, so the file can be kept in version
+// control or copied between stations and merged with cat.
+type observationLog struct {
+	Path string
+}
+
+// load reads all the observations in the log, sorted by when they started.
+// A log that doesn't exist yet is empty.
+func (ol *observationLog) load() ([]*observation, error) {
+	f, err := os.Open(ol.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make([]*observation, 0), nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	obs := make([]*observation, 0)
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		o := new(observation)
+		if err = json.Unmarshal([]byte(line), o); err != nil {
+			return nil, fmt.Errorf("Error reading line %d of %s: %s", n, ol.Path, err)
+		}
+		obs = append(obs, o)
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(obs, func(i, j int) bool {
+		return obs[i].Start.Before(obs[j].Start)
+	})
+	return obs, nil
+}
+
+// add checks the new observations, gives them IDs following on from the
+// ones already in the log, and appends them to it.
+func (ol *observationLog) add(obs ...*observation) error {
+	existing, err := ol.load()
+	if err != nil {
+		return err
+	}
+	id := 0
+	for _, o := range existing {
+		id = max(id, o.ID)
+	}
+	now := time.Now().UTC()
+	for _, o := range obs {
+		if err = o.check(); err != nil {
+			return err
+		}
+		id++
+		o.ID, o.Logged = id, now
+	}
+
+	f, err := os.OpenFile(ol.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, o := range obs {
+		if err = enc.Encode(o); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// list returns the observations that overlap the span, only from the
+// station and of the kind given, if they're given.
+func (ol *observationLog) list(station string, kind string, span timeSpan) ([]*observation, error) {
+	all, err := ol.load()
+	if err != nil {
+		return nil, err
+	}
+	obs := make([]*observation, 0, len(all))
+	for _, o := range all {
+		if (station == "" || o.Station == station) && (kind == "" || o.Kind == kind) && o.span().overlaps(span) {
+			obs = append(obs, o)
+		}
+	}
+	return obs, nil
+}
+
+// remove takes the observations with the given IDs out of the log,
+// rewriting it. It's an error if any of them aren't there.
+func (ol *observationLog) remove(ids map[int]bool) error {
+	obs, err := ol.load()
+	if err != nil {
+		return err
+	}
+	kept := make([]*observation, 0, len(obs))
+	for _, o := range obs {
+		if ids[o.ID] {
+			delete(ids, o.ID)
+			continue
+		}
+		kept = append(kept, o)
+	}
+	if len(ids) > 0 {
+		missing := make([]int, 0, len(ids))
+		for id := range ids {
+			missing = append(missing, id)
+		}
+		sort.Ints(missing)
+		return fmt.Errorf("There's no observation %d in %s.", missing[0], ol.Path)
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].ID < kept[j].ID
+	})
+
+	// write the new log next to the old one, so it's replaced all at
+	// once
+	tmp, err := os.CreateTemp(filepath.Dir(ol.Path), filepath.Base(ol.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, o := range kept {
+		if err = enc.Encode(o); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), ol.Path)
+}
+
+func (o *observation) check() error {
+	if o.Kind != observationDetection && o.Kind != observationSession {
+		return fmt.Errorf("'%s' isn't a kind of observation. It should be '%s' or '%s'.", o.Kind, observationDetection, observationSession)
+	}
+	if o.Start.IsZero() || o.End.IsZero() {
+		return fmt.Errorf("Observations need a start and an end time.")
+	}
+	if !o.End.After(o.Start) {
+		return fmt.Errorf("The observation starting %s must end after it starts.", o.Start.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (o *observation) span() timeSpan {
+	return timeSpan{o.Start, o.End}
+}
+
+func logCommand(args []string) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: jovian-noise log <add|import|list|remove> [options]\n\nKeeps a log of observations: detections, with their times, frequency, station, intensity, and notes, and sessions, when a station was listening. The log is a file of JSON lines, %s by default. Run 'jovian-noise log <subcommand> -h' for each subcommand's options.\n", defaultObservationLog)
+	}
+	if len(args) < 1 {
+		usage()
+		return fmt.Errorf("log needs a subcommand.")
+	}
+	switch args[0] {
+	case "add":
+		return logAddCommand(args[1:])
+	case "import":
+		return logImportCommand(args[1:])
+	case "list":
+		return logListCommand(args[1:])
+	case "remove":
+		return logRemoveCommand(args[1:])
+	case "-h", "-help", "--help":
+		usage()
+		return nil
+	}
+	usage()
+	return fmt.Errorf("'%s' isn't a log subcommand.", args[0])
+}
+
+func logAddCommand(args []string) error {
+	flags := flag.NewFlagSet("log add", flag.ExitOnError)
+	db := flags.String("db", defaultObservationLog, "Path to the observation log.")
+	kind := flags.String("kind", observationDetection, "What kind of observation it is: 'detection', or 'session' for a time the station was listening.")
+	start := flags.String("start", "", "When the observation started, in RFC 3339 format.")
+	end := flags.String("end", "", "When the observation ended, in RFC 3339 format.")
+	length := flags.Duration("length", 0, "How long the observation lasted, instead of -end.")
+	freq := flags.String("freq", "", "Optional frequency, in Hz or with a kHz or MHz suffix.")
+	station := flags.String("station", "", "Optional name of the station that made the observation.")
+	intensity := flags.String("intensity", "", "Optional intensity, on whatever scale the station uses.")
+	notes := flags.String("notes", "", "Optional notes.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise log add [options]\n\nAdds an observation to the observation log.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("log add doesn't take any arguments.")
+	}
+	if *end != "" && *length != 0 {
+		return fmt.Errorf("Give -end or -length, not both.")
+	}
+	o := &observation{Kind: *kind, Station: *station, Notes: *notes}
+	var err error
+	if o.Start, err = parseObservationTime("-start", *start); err != nil {
+		return err
+	}
+	if *length != 0 {
+		o.End = o.Start.Add(*length)
+	} else if o.End, err = parseObservationTime("-end", *end); err != nil {
+		return err
+	}
+	if *freq != "" {
+		if o.FrequencyHz, err = parseFrequency(*freq); err != nil {
+			return err
+		}
+	}
+	if *intensity != "" {
+		v, err := strconv.ParseFloat(*intensity, 64)
+		if err != nil {
+			return fmt.Errorf("-intensity must be a number.")
+		}
+		o.Intensity = &v
+	}
+
+	ol := &observationLog{Path: *db}
+	if err = ol.add(o); err != nil {
+		return err
+	}
+	fmt.Printf("Logged %s %d.\n", o.Kind, o.ID)
+	return nil
+}
+
+// logImportCommand imports observations from CSV, like a spreadsheet saved
+// as CSV. The first row names the columns; start and end (or length) are
+// needed, and kind, frequency, station, intensity, and notes are optional.
+// Other columns are ignored.
+func logImportCommand(args []string) error {
+	flags := flag.NewFlagSet("log import", flag.ExitOnError)
+	db := flags.String("db", defaultObservationLog, "Path to the observation log.")
+	station := flags.String("station", "", "Optional station name for rows that don't have one.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise log import [options] <file.csv>\n\nImports observations from a CSV file, or stdin if the file is '-'. The first row names the columns: 'start', and 'end' or 'length', are needed, and 'kind', 'frequency', 'station', 'intensity', and 'notes' are optional. Times are in RFC 3339 format, and lengths like '5m30s'.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("log import needs a CSV file to import.")
+	}
+	var r io.Reader = os.Stdin
+	if flags.Arg(0) != "-" {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	obs, err := readObservationCSV(r, *station)
+	if err != nil {
+		return err
+	}
+	ol := &observationLog{Path: *db}
+	if err = ol.add(obs...); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %d observations into %s.\n", len(obs), ol.Path)
+	return nil
+}
+
+func readObservationCSV(r io.Reader, station string) ([]*observation, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the CSV header: %s", err)
+	}
+	cols := make(map[string]int)
+	for i, h := range header {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := cols["start"]; !ok {
+		return nil, fmt.Errorf("The CSV needs a 'start' column.")
+	}
+	_, hasEnd := cols["end"]
+	_, hasLength := cols["length"]
+	if !hasEnd && !hasLength {
+		return nil, fmt.Errorf("The CSV needs an 'end' or 'length' column.")
+	}
+
+	obs := make([]*observation, 0)
+	for row := 2; ; row++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		o := &observation{Kind: strings.ToLower(field("kind")), Station: field("station"), Notes: field("notes")}
+		if o.Kind == "" {
+			o.Kind = observationDetection
+		}
+		if o.Station == "" {
+			o.Station = station
+		}
+		if o.Start, err = parseObservationTime("start", field("start")); err != nil {
+			return nil, fmt.Errorf("Row %d: %s", row, err)
+		}
+		if e := field("end"); e != "" {
+			if o.End, err = parseObservationTime("end", e); err != nil {
+				return nil, fmt.Errorf("Row %d: %s", row, err)
+			}
+		} else {
+			d, err := time.ParseDuration(field("length"))
+			if err != nil {
+				return nil, fmt.Errorf("Row %d: the length isn't a duration like '5m30s'.", row)
+			}
+			o.End = o.Start.Add(d)
+		}
+		if f := field("frequency"); f != "" {
+			if o.FrequencyHz, err = parseFrequency(f); err != nil {
+				return nil, fmt.Errorf("Row %d: %s", row, err)
+			}
+		}
+		if in := field("intensity"); in != "" {
+			v, err := strconv.ParseFloat(in, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Row %d: the intensity must be a number.", row)
+			}
+			o.Intensity = &v
+		}
+		if err = o.check(); err != nil {
+			return nil, fmt.Errorf("Row %d: %s", row, err)
+		}
+		obs = append(obs, o)
+	}
+	return obs, nil
+}
+
+func logListCommand(args []string) error {
+	flags := flag.NewFlagSet("log list", flag.ExitOnError)
+	db := flags.String("db", defaultObservationLog, "Path to the observation log.")
+	station := flags.String("station", "", "Only list this station's observations.")
+	kind := flags.String("kind", "", "Only list this kind of observation, 'detection' or 'session'.")
+	from := flags.String("from", "", "Only list observations ending after this time, in RFC 3339 format.")
+	to := flags.String("to", "", "Only list observations starting before this time, in RFC 3339 format.")
+	output := flags.String("output", "text", "How to format the list. Currently acceptable options are: text (default), json, csv.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise log list [options]\n\nLists the observations in the observation log.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("log list doesn't take any arguments.")
+	}
+	if *output != "text" && *output != "json" && *output != "csv" {
+		return fmt.Errorf("Output format '%s' is not a valid selection.", *output)
+	}
+	span := timeSpan{time.Time{}, time.Unix(1<<40, 0)}
+	var err error
+	if *from != "" {
+		if span.Start, err = parseObservationTime("-from", *from); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if span.End, err = parseObservationTime("-to", *to); err != nil {
+			return err
+		}
+	}
+
+	ol := &observationLog{Path: *db}
+	obs, err := ol.list(*station, *kind, span)
+	if err != nil {
+		return err
+	}
+
+	switch *output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		for _, o := range obs {
+			if err = enc.Encode(o); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		return writeObservationCSV(os.Stdout, obs)
+	}
+
+	if len(obs) == 0 {
+		fmt.Printf("No observations.\n")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "ID\tKind\tStart\tEnd\tLength\tFreq. MHz\tStation\tIntensity\tNotes\t\n")
+	fmt.Fprintf(w, "--\t----\t-----\t---\t------\t---------\t-------\t---------\t-----\t\n")
+	for _, o := range obs {
+		freq, intensity := "-", "-"
+		if o.FrequencyHz != 0 {
+			freq = strconv.FormatFloat(float64(o.FrequencyHz)/1e6, 'f', -1, 64)
+		}
+		if o.Intensity != nil {
+			intensity = strconv.FormatFloat(*o.Intensity, 'f', -1, 64)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", o.ID, o.Kind, o.Start.UTC().Format("2006-01-02 15:04:05"), o.End.UTC().Format("2006-01-02 15:04:05"), o.End.Sub(o.Start), freq, o.Station, intensity, o.Notes)
+	}
+	return w.Flush()
+}
+
+// writeObservationCSV writes observations as CSV that log import can read
+// back in.
+func writeObservationCSV(w io.Writer, obs []*observation) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "kind", "start", "end", "frequency", "station", "intensity", "notes"})
+	for _, o := range obs {
+		var freq, intensity string
+		if o.FrequencyHz != 0 {
+			freq = strconv.FormatInt(o.FrequencyHz, 10)
+		}
+		if o.Intensity != nil {
+			intensity = strconv.FormatFloat(*o.Intensity, 'f', -1, 64)
+		}
+		cw.Write([]string{strconv.Itoa(o.ID), o.Kind, o.Start.UTC().Format(time.RFC3339), o.End.UTC().Format(time.RFC3339), freq, o.Station, intensity, o.Notes})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func logRemoveCommand(args []string) error {
+	flags := flag.NewFlagSet("log remove", flag.ExitOnError)
+	db := flags.String("db", defaultObservationLog, "Path to the observation log.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise log remove [options] <id>...\n\nRemoves observations from the observation log by their IDs.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("log remove needs the IDs of the observations to remove.")
+	}
+	ids := make(map[int]bool)
+	for _, a := range flags.Args() {
+		id, err := strconv.Atoi(a)
+		if err != nil {
+			return fmt.Errorf("'%s' isn't an observation ID.", a)
+		}
+		ids[id] = true
+	}
+	n := len(ids)
+	ol := &observationLog{Path: *db}
+	if err := ol.remove(ids); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d observations from %s.\n", n, ol.Path)
+	return nil
+}
+
+func parseObservationTime(name string, s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, fmt.Errorf("%s is needed.", name)
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Error parsing %s: %s", name, err)
+	}
+	return t.UTC(), nil
+}
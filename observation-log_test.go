@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testObservationLog(t *testing.T) *observationLog {
+	return &observationLog{Path: filepath.Join(t.TempDir(), "observations.jsonl")}
+}
+
+func loadTestLog(t *testing.T, ol *observationLog) []*observation {
+	t.Helper()
+	obs, err := ol.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obs
+}
+
+func TestLogAdd(t *testing.T) {
+	ol := testObservationLog(t)
+	if obs := loadTestLog(t, ol); len(obs) != 0 {
+		t.Fatalf("a log that doesn't exist has %d observations", len(obs))
+	}
+	if err := logAddCommand([]string{"-db", ol.Path, "-start", "2024-01-01T05:00:00Z", "-length", "5m", "-freq", "20.1MHz", "-station", "home", "-intensity", "3.5", "-notes", "L-bursts"}); err != nil {
+		t.Fatal(err)
+	}
+	// times are kept in UTC
+	if err := logAddCommand([]string{"-db", ol.Path, "-kind", "session", "-start", "2024-01-01T02:00:00-03:00", "-end", "2024-01-01T03:00:00-03:00"}); err != nil {
+		t.Fatal(err)
+	}
+
+	obs := loadTestLog(t, ol)
+	if len(obs) != 2 {
+		t.Fatalf("the log has %d observations, want 2", len(obs))
+	}
+	d, s := obs[0], obs[1]
+	if d.ID != 1 || d.Kind != observationDetection || !d.Start.Equal(time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)) || d.End.Sub(d.Start) != 5*time.Minute {
+		t.Errorf("the detection is %d, a %s from %s to %s", d.ID, d.Kind, d.Start, d.End)
+	}
+	if d.FrequencyHz != 20100000 || d.Station != "home" || d.Intensity == nil || *d.Intensity != 3.5 || d.Notes != "L-bursts" || d.Logged.IsZero() {
+		t.Errorf("the detection is %+v", d)
+	}
+	if s.ID != 2 || s.Kind != observationSession || s.Start.Location() != time.UTC || !s.Start.Equal(time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)) || !s.End.Equal(s.Start.Add(time.Hour)) {
+		t.Errorf("the session is %d, a %s from %s to %s", s.ID, s.Kind, s.Start, s.End)
+	}
+}
+
+func TestLogAddErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		msg  string
+	}{
+		{"end before start", []string{"-start", "2024-01-01T05:00:00Z", "-end", "2024-01-01T04:00:00Z"}, "must end after it starts"},
+		{"end at the start", []string{"-start", "2024-01-01T05:00:00Z", "-end", "2024-01-01T05:00:00Z"}, "must end after it starts"},
+		{"negative length", []string{"-start", "2024-01-01T05:00:00Z", "-length", "-5m"}, "must end after it starts"},
+		{"end and length", []string{"-start", "2024-01-01T05:00:00Z", "-end", "2024-01-01T06:00:00Z", "-length", "5m"}, "not both"},
+		{"no start", []string{"-length", "5m"}, "-start is needed."},
+		{"no end", []string{"-start", "2024-01-01T05:00:00Z"}, "-end is needed."},
+		{"bad time", []string{"-start", "2024-01-01 05:00", "-length", "5m"}, "Error parsing -start"},
+		{"bad kind", []string{"-kind", "burst", "-start", "2024-01-01T05:00:00Z", "-length", "5m"}, "isn't a kind of observation"},
+		{"bad intensity", []string{"-start", "2024-01-01T05:00:00Z", "-length", "5m", "-intensity", "loud"}, "-intensity must be a number."},
+	}
+	for _, tt := range tests {
+		ol := testObservationLog(t)
+		err := logAddCommand(append([]string{"-db", ol.Path}, tt.args...))
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: got error %v, want one about %q", tt.name, err, tt.msg)
+		}
+		if _, err := os.Stat(ol.Path); err == nil {
+			t.Errorf("%s: the log was written to", tt.name)
+		}
+	}
+}
+
+func TestLogImport(t *testing.T) {
+	ol := testObservationLog(t)
+	if err := ol.add(&observation{Kind: observationDetection, Start: time.Date(2023, 12, 31, 5, 0, 0, 0, time.UTC), End: time.Date(2023, 12, 31, 5, 1, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	withEnd := filepath.Join(dir, "end.csv")
+	os.WriteFile(withEnd, []byte("Start, End, Frequency, Station, Intensity, Notes, Weather\n2024-01-01T05:00:00Z, 2024-01-01T05:10:00Z, 20.1MHz, , 4, \"S-bursts, then L\", clear\n2024-01-01T06:00:00Z,2024-01-01T06:02:00Z,,away,,,\n"), 0644)
+	withLength := filepath.Join(dir, "length.csv")
+	os.WriteFile(withLength, []byte("kind,start,length\nsession,2024-01-01T04:00:00Z,3h\n"), 0644)
+
+	for _, path := range []string{withEnd, withLength} {
+		if err := logImportCommand([]string{"-db", ol.Path, "-station", "home", path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	obs := loadTestLog(t, ol)
+	if len(obs) != 4 {
+		t.Fatalf("the log has %d observations, want 4", len(obs))
+	}
+	// sorted by start time, with IDs following on from the ones there
+	// already
+	want := []struct {
+		id      int
+		kind    string
+		station string
+		length  time.Duration
+	}{
+		{1, observationDetection, "", time.Minute},
+		{4, observationSession, "home", 3 * time.Hour},
+		{2, observationDetection, "home", 10 * time.Minute},
+		{3, observationDetection, "away", 2 * time.Minute},
+	}
+	for i, w := range want {
+		o := obs[i]
+		if o.ID != w.id || o.Kind != w.kind || o.Station != w.station || o.End.Sub(o.Start) != w.length {
+			t.Errorf("observation %d is %d, a %s from %s for %s, want %d, a %s from %s for %s", i, o.ID, o.Kind, o.Station, o.End.Sub(o.Start), w.id, w.kind, w.station, w.length)
+		}
+	}
+	if o := obs[2]; o.FrequencyHz != 20100000 || o.Intensity == nil || *o.Intensity != 4 || o.Notes != "S-bursts, then L" {
+		t.Errorf("the imported detection is %+v", o)
+	}
+}
+
+func TestReadObservationCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		msg  string
+	}{
+		{"no end or length column", "start,station\n2024-01-01T05:00:00Z,home\n", "needs an 'end' or 'length' column"},
+		{"no start column", "end,length\n2024-01-01T05:00:00Z,5m\n", "needs a 'start' column"},
+		{"empty", "", "Error reading the CSV header"},
+		{"end before start", "start,end\n2024-01-01T05:00:00Z,2024-01-01T05:10:00Z\n2024-01-01T06:00:00Z,2024-01-01T05:50:00Z\n", "Row 3: The observation starting 2024-01-01T06:00:00Z must end after it starts."},
+		{"no end or length", "start,end,length\n2024-01-01T05:00:00Z,,\n", "Row 2: the length isn't a duration"},
+		{"bad length", "start,length\n2024-01-01T05:00:00Z,five minutes\n", "Row 2: the length isn't a duration"},
+		{"bad start", "start,length\nyesterday,5m\n", "Row 2: Error parsing start"},
+		{"bad kind", "kind,start,length\nburst,2024-01-01T05:00:00Z,5m\n", "Row 2: 'burst' isn't a kind of observation"},
+		{"bad intensity", "start,length,intensity\n2024-01-01T05:00:00Z,5m,loud\n", "Row 2: the intensity must be a number."},
+	}
+	for _, tt := range tests {
+		_, err := readObservationCSV(strings.NewReader(tt.csv), "")
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: got error %v, want one about %q", tt.name, err, tt.msg)
+		}
+	}
+
+	// nothing's imported from a file with a bad row
+	ol := testObservationLog(t)
+	path := filepath.Join(t.TempDir(), "bad.csv")
+	os.WriteFile(path, []byte(tests[3].csv), 0644)
+	if err := logImportCommand([]string{"-db", ol.Path, path}); err == nil {
+		t.Error("a CSV with a bad row was imported")
+	}
+	if obs := loadTestLog(t, ol); len(obs) != 0 {
+		t.Errorf("%d observations were imported from a CSV with a bad row", len(obs))
+	}
+}
+
+func TestObservationCSVRoundTrip(t *testing.T) {
+	intensity := 2.5
+	start := time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)
+	obs := []*observation{
+		{ID: 7, Kind: observationDetection, Start: start, End: start.Add(90 * time.Second), FrequencyHz: 20100000, Station: "home", Intensity: &intensity, Notes: "quote \" and, comma"},
+		{ID: 8, Kind: observationSession, Start: start.Add(-time.Hour), End: start.Add(time.Hour)},
+	}
+	var b bytes.Buffer
+	if err := writeObservationCSV(&b, obs); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readObservationCSV(&b, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(obs) {
+		t.Fatalf("read back %d observations, want %d", len(got), len(obs))
+	}
+	for i, o := range obs {
+		g := got[i]
+		if g.Kind != o.Kind || !g.Start.Equal(o.Start) || !g.End.Equal(o.End) || g.FrequencyHz != o.FrequencyHz || g.Station != o.Station || g.Notes != o.Notes || (g.Intensity == nil) != (o.Intensity == nil) {
+			t.Errorf("read back %+v, want %+v", g, o)
+		}
+	}
+}
+
+func TestLogList(t *testing.T) {
+	ol := testObservationLog(t)
+	at := func(h int) time.Time {
+		return time.Date(2024, 1, 1, h, 0, 0, 0, time.UTC)
+	}
+	err := ol.add(
+		&observation{Kind: observationSession, Start: at(2), End: at(8), Station: "home"},
+		&observation{Kind: observationDetection, Start: at(3), End: at(4), Station: "home"},
+		&observation{Kind: observationDetection, Start: at(5), End: at(6), Station: "away"},
+		&observation{Kind: observationDetection, Start: at(1), End: at(2), Station: "home"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := timeSpan{time.Time{}, time.Unix(1<<40, 0)}
+	tests := []struct {
+		name    string
+		station string
+		kind    string
+		span    timeSpan
+		ids     []int
+	}{
+		{"everything, by start time", "", "", all, []int{4, 1, 2, 3}},
+		{"station", "home", "", all, []int{4, 1, 2}},
+		{"kind", "", observationDetection, all, []int{4, 2, 3}},
+		{"station and kind", "home", observationSession, all, []int{1}},
+		// observations that only touch the span's ends are left out
+		{"span", "", "", timeSpan{at(4), at(5)}, []int{1}},
+		{"from", "", "", timeSpan{at(4), all.End}, []int{1, 3}},
+		{"nothing", "nobody", "", all, []int{}},
+	}
+	for _, tt := range tests {
+		obs, err := ol.list(tt.station, tt.kind, tt.span)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]int, 0, len(obs))
+		for _, o := range obs {
+			ids = append(ids, o.ID)
+		}
+		if len(ids) != len(tt.ids) {
+			t.Errorf("%s: got %v, want %v", tt.name, ids, tt.ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.ids[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, ids, tt.ids)
+				break
+			}
+		}
+	}
+}
+
+func TestLogRemove(t *testing.T) {
+	ol := testObservationLog(t)
+	start := time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		if err := ol.add(&observation{Kind: observationDetection, Start: start.Add(time.Duration(i) * time.Hour), End: start.Add(time.Duration(i)*time.Hour + time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := logRemoveCommand([]string{"-db", ol.Path, "2", "4"}); err != nil {
+		t.Fatal(err)
+	}
+	obs := loadTestLog(t, ol)
+	if len(obs) != 2 || obs[0].ID != 1 || obs[1].ID != 3 {
+		t.Fatalf("after removing 2 and 4, the log has %d observations", len(obs))
+	}
+
+	// removing one that isn't there leaves the log alone
+	before, _ := os.ReadFile(ol.Path)
+	err := logRemoveCommand([]string{"-db", ol.Path, "1", "2"})
+	if err == nil || !strings.Contains(err.Error(), "There's no observation 2") {
+		t.Errorf("removing a missing observation got %v", err)
+	}
+	if after, _ := os.ReadFile(ol.Path); !bytes.Equal(before, after) {
+		t.Error("a failed remove changed the log")
+	}
+	if err = logRemoveCommand([]string{"-db", ol.Path, "one"}); err == nil || !strings.Contains(err.Error(), "isn't an observation ID") {
+		t.Errorf("removing 'one' got %v", err)
+	}
+
+	// and IDs carry on from the highest left
+	if err = ol.add(&observation{Kind: observationSession, Start: start, End: start.Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range loadTestLog(t, ol) {
+		if o.Kind == observationSession && o.ID != 4 {
+			t.Errorf("a new observation after 3 got ID %d, want 4", o.ID)
+		}
+	}
+	if files, _ := os.ReadDir(filepath.Dir(ol.Path)); len(files) != 1 {
+		t.Errorf("removing left %d files behind", len(files)-1)
+	}
+}
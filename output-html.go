@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	sexa "github.com/soniakeys/sexagesimal"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const htmlTimeFormat string = "2006-01-02 15:04 MST"
+
+type htmlOutput struct {
+	Version  string
+	Start    string
+	End      string
+	Duration time.Duration
+	Interval int
+	Local    bool
+	Lat      int
+	Lon      int
+	Location string
+	Offset   string
+	Sources  []*htmlSource
+	Hours    []*htmlHour
+	Windows  []*htmlWindow
+	Days     []*htmlDay
+}
+
+type htmlSource struct {
+	Name  string
+	Class string
+}
+
+type htmlHour struct {
+	Left  string
+	Label string
+}
+
+type htmlWindow struct {
+	Source       string
+	Class        string
+	Start        string
+	StartUnix    int64
+	End          string
+	EndUnix      int64
+	Duration     time.Duration
+	Minutes      int
+	PeakAltitude string
+	PeakDeg      float64
+	Recommended  bool
+}
+
+// htmlDay is one (local) day's timeline bar. Segments are positioned with
+// percentages of the day.
+type htmlDay struct {
+	Date   string
+	Up     []*htmlSegment
+	Active []*htmlSegment
+}
+
+type htmlSegment struct {
+	Class string
+	Left  string
+	Width string
+	Title string
+}
+
+func outputHTML(jData *jupiterData) error {
+	return writeHTML(os.Stdout, jData)
+}
+
+// writeHTML writes the forecast as a standalone HTML report.
+func writeHTML(w io.Writer, jData *jupiterData) error {
+	tmpl, err := template.New("htmlOut").Parse(strings.TrimSpace(htmlOutputTemplate))
+	if err != nil {
+		return err
+	}
+
+	loc := jData.displayLocation()
+	outData := new(htmlOutput)
+	outData.Version = version
+	outData.Start = jData.StartTime.In(loc).Format(htmlTimeFormat)
+	outData.End = jData.EndTime.In(loc).Format(htmlTimeFormat)
+	outData.Duration = jData.Duration
+	outData.Interval = jData.Interval
+	outData.Local = jData.LocalForecast
+	outData.Lat, outData.Lon = jData.displayCoords()
+	outData.Location, outData.Offset = jData.zoneInfo()
+	if outData.Location == "" {
+		outData.Location = "UTC"
+	}
+
+	windows := jData.Windows()
+	seen := make(map[radioSource]bool)
+	for _, fw := range windows {
+		if !seen[fw.RadioSource] {
+			seen[fw.RadioSource] = true
+			outData.Sources = append(outData.Sources, &htmlSource{Name: fw.RadioSource.String(), Class: fw.RadioSource.slug()})
+		}
+		hw := &htmlWindow{
+			Source:      fw.RadioSource.String(),
+			Class:       fw.RadioSource.slug(),
+			Start:       fw.Start.In(loc).Format(htmlTimeFormat),
+			StartUnix:   fw.Start.Unix(),
+			End:         fw.End.In(loc).Format(htmlTimeFormat),
+			EndUnix:     fw.End.Unix(),
+			Duration:    fw.Duration(),
+			Minutes:     int(fw.Duration().Minutes()),
+			Recommended: fw.Recommended(),
+		}
+		if peak, ok := fw.PeakAltitude(); ok {
+			hw.PeakAltitude = fmt.Sprintf("%.1j", sexa.FmtAngle(peak))
+			hw.PeakDeg = peak.Deg()
+		}
+		outData.Windows = append(outData.Windows, hw)
+	}
+
+	for h := 0; h <= 24; h += 3 {
+		outData.Hours = append(outData.Hours, &htmlHour{Left: fmt.Sprintf("%.3f", float64(h)/24*100), Label: fmt.Sprintf("%02d:00", h)})
+	}
+
+	up := jData.jupiterUp()
+	start := jData.StartTime.In(loc)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	for day.Before(jData.EndTime) {
+		next := day.AddDate(0, 0, 1)
+		span := timeSpan{day, next}
+		hd := &htmlDay{Date: day.Format("Mon Jan 02")}
+		for _, u := range up {
+			if c, ok := u.clip(span); ok {
+				hd.Up = append(hd.Up, newHTMLSegment(span, c, "up", fmt.Sprintf("Jupiter up %s - %s", c.Start.In(loc).Format("15:04"), c.End.In(loc).Format("15:04"))))
+			}
+		}
+		for _, fw := range windows {
+			if c, ok := (timeSpan{fw.Start, fw.End}).clip(span); ok {
+				hd.Active = append(hd.Active, newHTMLSegment(span, c, fw.RadioSource.slug(), fmt.Sprintf("%s %s - %s", fw.RadioSource, c.Start.In(loc).Format("15:04"), c.End.In(loc).Format("15:04"))))
+			}
+		}
+		outData.Days = append(outData.Days, hd)
+		day = next
+	}
+
+	return tmpl.Execute(w, outData)
+}
+
+func newHTMLSegment(day timeSpan, ts timeSpan, class string, title string) *htmlSegment {
+	dayLen := float64(day.End.Sub(day.Start))
+	left := float64(ts.Start.Sub(day.Start)) / dayLen * 100
+	width := float64(ts.End.Sub(ts.Start)) / dayLen * 100
+	return &htmlSegment{Class: class, Left: fmt.Sprintf("%.3f", left), Width: fmt.Sprintf("%.3f", width), Title: title}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTML(t *testing.T) {
+	jData := testForecast(t)
+	var b bytes.Buffer
+	if err := writeHTML(&b, jData); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "<!DOCTYPE html>") {
+		t.Errorf("output doesn't start with a doctype: %.40q", b.String())
+	}
+
+	d := xml.NewDecoder(&b)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	var stack []string
+	var rows, bars int
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("the HTML doesn't parse: %s", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if tok.Name.Local == "tr" && len(stack) > 0 && stack[len(stack)-1] == "tbody" {
+				rows++
+			}
+			for _, a := range tok.Attr {
+				if a.Name.Local == "class" && a.Value == "bar" {
+					bars++
+				}
+			}
+			stack = append(stack, tok.Name.Local)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) != 0 {
+		t.Errorf("elements left open: %v", stack)
+	}
+	if want := len(jData.Windows()); rows != want {
+		t.Errorf("the window table has %d rows, want %d", rows, want)
+	}
+	// 1 March to 15 March, Boulder time
+	if bars != 15 {
+		t.Errorf("the timeline has %d days, want 15", bars)
+	}
+}
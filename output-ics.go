@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const icsTimeFormat = "20060102T150405Z"
+
+// icsLineLimit is the longest a content line may be, in octets, before it
+// has to be folded.
+const icsLineLimit = 75
+
+// icsCalendar describes the calendar a forecast's windows are written out
+// as. UIDSuffix is appended to each event's UID, so that calendars for
+// different places don't share UIDs. Refresh, if set, tells calendar apps
+// how often to check a subscribed calendar for updates.
+type icsCalendar struct {
+	Name      string
+	UIDSuffix string
+	Refresh   time.Duration
+	Stamp     time.Time
+}
+
+func outputICS(jData *jupiterData) error {
+	cal := &icsCalendar{Name: "Jupiter radio storm forecast", Stamp: time.Now()}
+	if jData.Provenance != nil {
+		cal.Stamp = jData.Provenance.GeneratedAt
+	}
+	if jData.LocalForecast {
+		lat, lon := jData.displayCoords()
+		cal.Name = fmt.Sprintf("%s for %dº, %dº", cal.Name, lat, lon)
+		cal.UIDSuffix = icsCoordsID(lat, lon)
+	}
+	return writeICS(os.Stdout, jData, cal)
+}
+
+// writeICS writes the forecast's windows as an iCalendar file, one event for
+// each window. Event UIDs are made from the window's radio source and start
+// time, so a window gets the same UID whenever the forecast is regenerated.
+func writeICS(w io.Writer, jData *jupiterData, cal *icsCalendar) error {
+	var b bytes.Buffer
+	line := func(format string, a ...interface{}) {
+		b.WriteString(icsFold(fmt.Sprintf(format, a...)))
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//jovian-noise//jovian-noise %s//EN", version)
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("NAME:%s", icsEscape(cal.Name))
+	line("X-WR-CALNAME:%s", icsEscape(cal.Name))
+	if cal.Refresh > 0 {
+		line("REFRESH-INTERVAL;VALUE=DURATION:%s", isoDuration(cal.Refresh))
+		line("X-PUBLISHED-TTL:%s", isoDuration(cal.Refresh))
+	}
+
+	stamp := cal.Stamp.UTC().Format(icsTimeFormat)
+	for _, fw := range jData.Windows() {
+		first, last := fw.Intervals[0], fw.Intervals[len(fw.Intervals)-1]
+		desc := fmt.Sprintf("Radio source: %s\nCML: %.0fº - %.0fº\nIo phase: %.0fº - %.0fº\nDistance: %.2f AU", fw.RadioSource, first.Meridian.Deg(), last.Meridian.Deg(), first.IoPhase.Deg(), last.IoPhase.Deg(), first.Distance)
+		if peak, ok := fw.PeakAltitude(); ok {
+			desc += fmt.Sprintf("\nPeak altitude: %.0fº", peak.Deg())
+		}
+		summary := fmt.Sprintf("Jupiter %s storm", fw.RadioSource)
+		if fw.Recommended() {
+			summary += " (recommended)"
+			desc += fmt.Sprintf("\nRecommended: Jupiter is within %.0f hours of transit.", recommendCutoff)
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:%s-%s%s@jovian-noise", fw.RadioSource.slug(), fw.Start.UTC().Format(icsTimeFormat), cal.UIDSuffix)
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART:%s", fw.Start.UTC().Format(icsTimeFormat))
+		line("DTEND:%s", fw.End.UTC().Format(icsTimeFormat))
+		line("SUMMARY:%s", icsEscape(summary))
+		line("DESCRIPTION:%s", icsEscape(desc))
+		line("CATEGORIES:%s", icsEscape(fw.RadioSource.String()))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// icsCoordsID turns whole degree coordinates into something that can go in
+// a UID, like "-40n105w".
+func icsCoordsID(lat int, lon int) string {
+	ns, ew := 'n', 'e'
+	if lat < 0 {
+		ns, lat = 's', -lat
+	}
+	if lon < 0 {
+		ew, lon = 'w', -lon
+	}
+	return fmt.Sprintf("-%d%c%d%c", lat, ns, lon, ew)
+}
+
+// icsEscape escapes the characters that aren't allowed as is in iCalendar
+// text values.
+func icsEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// icsFold ends a content line with CRLF, folding it onto continuation lines
+// if it's too long. It's careful not to split UTF-8 sequences.
+func icsFold(s string) string {
+	var b strings.Builder
+	n := 0
+	limit := icsLineLimit
+	for _, r := range s {
+		l := len(string(r))
+		if n+l > limit {
+			b.WriteString("\r\n ")
+			n = 0
+			// the leading space counts against the limit
+			limit = icsLineLimit - 1
+		}
+		b.WriteRune(r)
+		n += l
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
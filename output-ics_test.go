@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestICSFold(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		lines []int
+	}{
+		{"short", "SUMMARY:Jupiter Io-B storm", []int{26}},
+		{"exactly the limit", strings.Repeat("a", 75), []int{75}},
+		{"one over", strings.Repeat("a", 76), []int{75, 2}},
+		{"several continuations", strings.Repeat("a", 75+74+74+10), []int{75, 75, 75, 11}},
+		// "º" is two octets, and would end at octet 76
+		{"two octets across the limit", strings.Repeat("a", 74) + "ºb", []int{74, 4}},
+		{"two octets at the limit", strings.Repeat("a", 73) + "ºb", []int{75, 2}},
+		// "♃" is three octets
+		{"three octets across the continuation's limit", strings.Repeat("a", 75+72) + "♃", []int{75, 73, 4}},
+		{"all multi-byte", strings.Repeat("♃", 30), []int{75, 16}},
+	}
+	for _, tt := range tests {
+		folded := icsFold(tt.line)
+		if !strings.HasSuffix(folded, "\r\n") {
+			t.Errorf("%s: doesn't end with CRLF", tt.name)
+			continue
+		}
+		lines := strings.Split(strings.TrimSuffix(folded, "\r\n"), "\r\n")
+		got := make([]int, len(lines))
+		for i, l := range lines {
+			got[i] = len(l)
+			if len(l) > icsLineLimit {
+				t.Errorf("%s: line %d is %d octets", tt.name, i, len(l))
+			}
+			if i > 0 && !strings.HasPrefix(l, " ") {
+				t.Errorf("%s: continuation line %d doesn't start with a space", tt.name, i)
+			}
+			if !utf8.ValidString(l) {
+				t.Errorf("%s: line %d splits a UTF-8 sequence: %q", tt.name, i, l)
+			}
+		}
+		if len(got) != len(tt.lines) {
+			t.Errorf("%s: folded into lines of %v octets, want %v", tt.name, got, tt.lines)
+		} else {
+			for i := range got {
+				if got[i] != tt.lines[i] {
+					t.Errorf("%s: folded into lines of %v octets, want %v", tt.name, got, tt.lines)
+					break
+				}
+			}
+		}
+		if unfolded := strings.ReplaceAll(strings.TrimSuffix(folded, "\r\n"), "\r\n ", ""); unfolded != tt.line {
+			t.Errorf("%s: unfolds to %q, want %q", tt.name, unfolded, tt.line)
+		}
+	}
+}
+
+func TestICSEscape(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"Jupiter Io-B storm", "Jupiter Io-B storm"},
+		{"Radio source: Io-A\nCML: 200º - 220º", `Radio source: Io-A\nCML: 200º - 220º`},
+		{"Boulder, CO; home", `Boulder\, CO\; home`},
+		{`C:\logs`, `C:\\logs`},
+		{`\n`, `\\n`},
+	}
+	for _, tt := range tests {
+		if got := icsEscape(tt.s); got != tt.want {
+			t.Errorf("icsEscape(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestICSCoordsID(t *testing.T) {
+	tests := []struct {
+		lat, lon int
+		want     string
+	}{
+		{40, -105, "-40n105w"},
+		{-33, 151, "-33s151e"},
+		{0, 0, "-0n0e"},
+	}
+	for _, tt := range tests {
+		if got := icsCoordsID(tt.lat, tt.lon); got != tt.want {
+			t.Errorf("icsCoordsID(%d, %d) = %q, want %q", tt.lat, tt.lon, got, tt.want)
+		}
+	}
+}
+
+var icsUIDRe = regexp.MustCompile(`(?m)^UID:(.*)\r$`)
+
+func icsUIDs(t *testing.T, jData *jupiterData, cal *icsCalendar) []string {
+	t.Helper()
+	var b bytes.Buffer
+	if err := writeICS(&b, jData, cal); err != nil {
+		t.Fatal(err)
+	}
+	var uids []string
+	for _, m := range icsUIDRe.FindAllStringSubmatch(b.String(), -1) {
+		uids = append(uids, m[1])
+	}
+	return uids
+}
+
+func TestICSStableUIDs(t *testing.T) {
+	jData := testRoundTripForecast(nil)
+	cal := &icsCalendar{Name: "test", UIDSuffix: icsCoordsID(40, -105), Stamp: jData.StartTime}
+	uids := icsUIDs(t, jData, cal)
+	want := []string{"io-b-20240301T000000Z-40n105w@jovian-noise", "io-a-20240301T013000Z-40n105w@jovian-noise"}
+	if strings.Join(uids, " ") != strings.Join(want, " ") {
+		t.Fatalf("UIDs are %q, want %q", uids, want)
+	}
+
+	// the same windows in a forecast made later, that starts earlier, get
+	// the same UIDs
+	later := testRoundTripForecast(nil)
+	later.StartTime = later.StartTime.Add(-time.Hour)
+	later.Intervals = append([]*forecastInterval{{Instant: later.StartTime, RadioSource: IoC, Meridian: jData.Intervals[0].Meridian}}, later.Intervals...)
+	later.Intervals[1].Distance = 4.76
+	laterCal := &icsCalendar{Name: "test", UIDSuffix: cal.UIDSuffix, Stamp: cal.Stamp.Add(6 * time.Hour)}
+	got := icsUIDs(t, later, laterCal)
+	if len(got) != 3 || got[0] != "io-c-20240229T230000Z-40n105w@jovian-noise" || got[1] != uids[0] || got[2] != uids[1] {
+		t.Errorf("UIDs in the later forecast are %q, want the Io-C window and then %q", got, uids)
+	}
+
+	// and calendars for other places don't share them
+	for _, uid := range icsUIDs(t, jData, &icsCalendar{Name: "test", UIDSuffix: icsCoordsID(-33, 151)}) {
+		for _, u := range uids {
+			if uid == u {
+				t.Errorf("%s is used in both calendars", uid)
+			}
+		}
+	}
+}
+
+func TestCalendarFeedCaching(t *testing.T) {
+	lat, lon := 40, -105
+	profile := &observerProfile{Name: "home", Lat: &lat, Lon: &lon, Days: 1}
+	fs := &forecastServer{profiles: map[string]*observerProfile{"home": profile}, refresh: time.Hour, feeds: make(map[string]*calendarFeed)}
+	body := []byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n")
+	generated := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	fs.feeds["home"] = &calendarFeed{body: body, etag: `"abc123"`, generated: generated, expires: time.Now().Add(time.Hour)}
+
+	get := func(header string, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/calendar/home.ics", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		fs.handleCalendar(rec, req)
+		return rec
+	}
+
+	rec := get("", "")
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), body) {
+		t.Fatalf("got %d %q, want the cached feed", rec.Code, rec.Body)
+	}
+	if etag := rec.Header().Get("ETag"); etag != `"abc123"` {
+		t.Errorf("ETag is %q", etag)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/calendar; charset=utf-8" {
+		t.Errorf("Content-Type is %q", ct)
+	}
+	if cc := rec.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "public, max-age=") || cc == "public, max-age=0" {
+		t.Errorf("Cache-Control is %q", cc)
+	}
+
+	tests := []struct {
+		header string
+		value  string
+		status int
+	}{
+		{"If-None-Match", `"abc123"`, http.StatusNotModified},
+		{"If-None-Match", `"def456", "abc123"`, http.StatusNotModified},
+		{"If-None-Match", `"def456"`, http.StatusOK},
+		{"If-Modified-Since", generated.Format(http.TimeFormat), http.StatusNotModified},
+		{"If-Modified-Since", generated.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := get(tt.header, tt.value)
+		if rec.Code != tt.status {
+			t.Errorf("%s: %s got %d, want %d", tt.header, tt.value, rec.Code, tt.status)
+		}
+		if tt.status == http.StatusNotModified && rec.Body.Len() != 0 {
+			t.Errorf("%s: %s got a body with the 304", tt.header, tt.value)
+		}
+	}
+
+	for _, path := range []string{"/calendar/away.ics", "/calendar/home"} {
+		rec := httptest.NewRecorder()
+		fs.handleCalendar(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s got %d, want 404", path, rec.Code)
+		}
+	}
+}
+
+func TestCalendarFeedRefresh(t *testing.T) {
+	if os.Getenv("VSOP87") == "" {
+		t.Skip("VSOP87 isn't set")
+	}
+	earth, jupiter, err := loadPlanets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lat, lon := 40, -105
+	profile := &observerProfile{Name: "home", Lat: &lat, Lon: &lon, Days: 2}
+	fs := &forecastServer{earth: earth, jupiter: jupiter, refresh: time.Hour, feeds: make(map[string]*calendarFeed)}
+
+	first, err := fs.calendarFeed(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := fs.calendarFeed(profile); again != first {
+		t.Error("the feed was recalculated before it was due")
+	}
+
+	// once it's due, it's recalculated, but since nothing's changed it
+	// keeps its ETag and Last-Modified time
+	first.expires = time.Now().Add(-time.Second)
+	second, err := fs.calendarFeed(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Fatal("the feed wasn't recalculated when it was due")
+	}
+	if second.etag != first.etag || !second.generated.Equal(first.generated) || !bytes.Equal(second.body, first.body) {
+		t.Errorf("the recalculated feed changed: ETag %s to %s, generated %s to %s", first.etag, second.etag, first.generated, second.generated)
+	}
+	if !second.expires.After(time.Now()) {
+		t.Errorf("the recalculated feed expires at %s", second.expires)
+	}
+}
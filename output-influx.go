@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const influxMeasurement = "jovian_forecast"
+
+// influxBatchSize is how many points are sent to an InfluxDB write endpoint
+// in each request.
+const influxBatchSize = 5000
+
+var influxTagEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+
+// outputInflux writes the forecast as InfluxDB line protocol, either to
+// stdout or, if writeURL is given, to an InfluxDB HTTP write endpoint.
+func outputInflux(jData *jupiterData, station string, writeURL string) error {
+	if writeURL == "" {
+		return writeInflux(os.Stdout, jData, station)
+	}
+	var b bytes.Buffer
+	if err := writeInflux(&b, jData, station); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	return postInflux(ctx, writeURL, b.Bytes())
+}
+
+// writeInflux writes one point for each of the forecast's intervals, tagged
+// with the station (if there is one) and radio source. Altitude and azimuth
+// are only included for forecasts for a location. Timestamps are in
+// nanoseconds, the line protocol's default precision.
+func writeInflux(w io.Writer, jData *jupiterData, station string) error {
+	var tags string
+	if station != "" {
+		tags = ",station=" + influxTagEscaper.Replace(station)
+	}
+	for _, fi := range jData.Intervals {
+		fields := []string{
+			"cml_deg=" + influxFloat(fi.Meridian.Deg()),
+			"io_phase_deg=" + influxFloat(fi.IoPhase.Deg()),
+			"distance_au=" + influxFloat(fi.Distance),
+		}
+		if fi.AltAz != nil {
+			fields = append(fields, "altitude_deg="+influxFloat(fi.AltAz.Altitude.Deg()), "azimuth_deg="+influxFloat(fi.AltAz.Azimuth.Mod1().Deg()))
+		}
+		if _, err := fmt.Fprintf(w, "%s%s,source=%s %s %d\n", influxMeasurement, tags, influxTagEscaper.Replace(fi.RadioSource.String()), strings.Join(fields, ","), fi.Instant.UnixNano()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func influxFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// postInflux sends line protocol to an InfluxDB write endpoint, such as
+// 'http://localhost:8086/api/v2/write?org=home&bucket=jupiter' or, for
+// InfluxDB 1.x, 'http://localhost:8086/write?db=jupiter'. Large exports are
+// sent in batches. If the INFLUX_TOKEN environment variable is set, it's
+// sent as the API token.
+func postInflux(ctx context.Context, writeURL string, lines []byte) error {
+	token := os.Getenv("INFLUX_TOKEN")
+	for len(lines) > 0 {
+		batch := lines
+		n := 0
+		for i, c := range lines {
+			if c != '\n' {
+				continue
+			}
+			if n++; n == influxBatchSize {
+				batch = lines[:i+1]
+				break
+			}
+		}
+		lines = lines[len(batch):]
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, writeURL, bytes.NewReader(batch))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		req.Header.Set("User-Agent", "jovian-noise/"+version)
+		if token != "" {
+			req.Header.Set("Authorization", "Token "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("Error writing to InfluxDB at %s: %s", writeURL, err)
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("InfluxDB at %s returned %s: %s", writeURL, resp.Status, strings.TrimSpace(string(body)))
+		}
+	}
+	return nil
+}
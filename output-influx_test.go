@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/soniakeys/unit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testInfluxForecast(observer bool) *jupiterData {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	jData := &jupiterData{StartTime: start, Interval: 30, LocalForecast: observer}
+	for i, rs := range []radioSource{IoA, NonIoA} {
+		fi := &forecastInterval{
+			Instant:     start.Add(time.Duration(i) * 30 * time.Minute),
+			Meridian:    unit.AngleFromDeg(200.5),
+			IoPhase:     unit.AngleFromDeg(90),
+			Distance:    5.125,
+			RadioSource: rs,
+		}
+		if observer {
+			fi.AltAz = &hzCoords{Altitude: unit.AngleFromDeg(12.5), Azimuth: unit.AngleFromDeg(-90)}
+		}
+		jData.Intervals = append(jData.Intervals, fi)
+	}
+	return jData
+}
+
+func TestWriteInflux(t *testing.T) {
+	tests := []struct {
+		name     string
+		observer bool
+		station  string
+		want     string
+	}{
+		{
+			name: "no station or observer",
+			want: "jovian_forecast,source=Io-A cml_deg=200.5,io_phase_deg=90,distance_au=5.125 1704067200000000000\n" +
+				"jovian_forecast,source=non-Io-A cml_deg=200.5,io_phase_deg=90,distance_au=5.125 1704069000000000000\n",
+		},
+		{
+			name:     "station and observer",
+			observer: true,
+			station:  "boulder",
+			want: "jovian_forecast,station=boulder,source=Io-A cml_deg=200.5,io_phase_deg=90,distance_au=5.125,altitude_deg=12.5,azimuth_deg=270 1704067200000000000\n" +
+				"jovian_forecast,station=boulder,source=non-Io-A cml_deg=200.5,io_phase_deg=90,distance_au=5.125,altitude_deg=12.5,azimuth_deg=270 1704069000000000000\n",
+		},
+		{
+			name:    "escaped station",
+			station: `Boulder, CO=home`,
+			want: `jovian_forecast,station=Boulder\,\ CO\=home,source=Io-A cml_deg=200.5,io_phase_deg=90,distance_au=5.125 1704067200000000000` + "\n" +
+				`jovian_forecast,station=Boulder\,\ CO\=home,source=non-Io-A cml_deg=200.5,io_phase_deg=90,distance_au=5.125 1704069000000000000` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := writeInflux(&b, testInfluxForecast(tt.observer), tt.station); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b.String(), tt.want)
+		}
+	}
+}
+
+// influxRecorder is an InfluxDB write endpoint that keeps what it's sent.
+type influxRecorder struct {
+	mu      sync.Mutex
+	status  int
+	paths   []string
+	bodies  []string
+	headers []http.Header
+}
+
+func (ir *influxRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	ir.mu.Lock()
+	defer ir.mu.Unlock()
+	ir.paths = append(ir.paths, r.URL.RequestURI())
+	ir.bodies = append(ir.bodies, string(body))
+	ir.headers = append(ir.headers, r.Header.Clone())
+	if ir.status != 0 {
+		w.WriteHeader(ir.status)
+		io.WriteString(w, `{"code":"invalid","message":"partial write: points beyond retention policy dropped=1"}`)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestOutputInfluxWrite(t *testing.T) {
+	ir := new(influxRecorder)
+	srv := httptest.NewServer(ir)
+	defer srv.Close()
+	t.Setenv("INFLUX_TOKEN", "t0ken")
+
+	if err := outputInflux(testInfluxForecast(true), "boulder", srv.URL+"/write?db=jupiter"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ir.bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(ir.bodies))
+	}
+	if ir.paths[0] != "/write?db=jupiter" {
+		t.Errorf("wrote to %s, want /write?db=jupiter", ir.paths[0])
+	}
+	if auth := ir.headers[0].Get("Authorization"); auth != "Token t0ken" {
+		t.Errorf("Authorization header is %q", auth)
+	}
+	var want bytes.Buffer
+	writeInflux(&want, testInfluxForecast(true), "boulder")
+	if ir.bodies[0] != want.String() {
+		t.Errorf("body is\n%s\nwant\n%s", ir.bodies[0], want.String())
+	}
+	for _, line := range strings.Split(strings.TrimSpace(ir.bodies[0]), "\n") {
+		if !strings.HasPrefix(line, "jovian_forecast,station=boulder,") {
+			t.Errorf("line isn't tagged with the station: %s", line)
+		}
+	}
+}
+
+func TestPostInfluxBatches(t *testing.T) {
+	tests := []struct {
+		lines   int
+		batches []int
+	}{
+		{1, []int{1}},
+		{influxBatchSize, []int{influxBatchSize}},
+		{influxBatchSize + 1, []int{influxBatchSize, 1}},
+		{2*influxBatchSize + 7, []int{influxBatchSize, influxBatchSize, 7}},
+	}
+	for _, tt := range tests {
+		ir := new(influxRecorder)
+		srv := httptest.NewServer(ir)
+		var b bytes.Buffer
+		for i := 0; i < tt.lines; i++ {
+			fmt.Fprintf(&b, "jovian_forecast,source=Io-A cml_deg=%d %d\n", i%360, i)
+		}
+		err := postInflux(context.Background(), srv.URL+"/write", b.Bytes())
+		srv.Close()
+		if err != nil {
+			t.Errorf("%d lines: %s", tt.lines, err)
+			continue
+		}
+		if len(ir.bodies) != len(tt.batches) {
+			t.Errorf("%d lines: got %d requests, want %d", tt.lines, len(ir.bodies), len(tt.batches))
+			continue
+		}
+		if strings.Join(ir.bodies, "") != b.String() {
+			t.Errorf("%d lines: the batches don't add up to the lines sent", tt.lines)
+		}
+		for i, body := range ir.bodies {
+			if n := strings.Count(body, "\n"); n != tt.batches[i] {
+				t.Errorf("%d lines: batch %d has %d lines, want %d", tt.lines, i, n, tt.batches[i])
+			}
+		}
+	}
+}
+
+func TestPostInfluxErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError} {
+		ir := &influxRecorder{status: status}
+		srv := httptest.NewServer(ir)
+		var b bytes.Buffer
+		for i := 0; i < influxBatchSize+1; i++ {
+			fmt.Fprintf(&b, "jovian_forecast,source=Io-A cml_deg=1 %d\n", i)
+		}
+		err := postInflux(context.Background(), srv.URL+"/write", b.Bytes())
+		srv.Close()
+		if err == nil {
+			t.Errorf("%d: expected an error", status)
+			continue
+		}
+		if !strings.Contains(err.Error(), fmt.Sprint(status)) || !strings.Contains(err.Error(), "partial write") {
+			t.Errorf("%d: error doesn't give the status and InfluxDB's message: %s", status, err)
+		}
+		if len(ir.bodies) != 1 {
+			t.Errorf("%d: got %d requests, want it to stop after the first failed batch", status, len(ir.bodies))
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-pdf/fpdf"
+	"io"
+	"os"
+	"time"
+)
+
+// Page layout for the PDF schedule, in millimetres.
+const (
+	pdfMargin      float64 = 15
+	pdfRowHeight   float64 = 6
+	pdfBarHeight   float64 = 7
+	pdfNightHeight float64 = 17
+)
+
+type pdfColor struct {
+	r, g, b int
+}
+
+type pdfLegendEntry struct {
+	c     pdfColor
+	label string
+}
+
+type pdfColumn struct {
+	title string
+	width float64
+}
+
+var pdfSourceColors = map[radioSource]pdfColor{
+	IoA:    {0xd6, 0x27, 0x28},
+	IoB:    {0x1f, 0x77, 0xb4},
+	IoC:    {0x2c, 0xa0, 0x2c},
+	NonIoA: {0x94, 0x67, 0xbd},
+}
+
+var (
+	pdfDaylight  = pdfColor{0xee, 0xee, 0xee}
+	pdfNight     = pdfColor{0x3c, 0x3c, 0x50}
+	pdfJupiterUp = pdfColor{0xff, 0xd7, 0x64}
+)
+
+// outputPDF writes a printable observing schedule: a header page with the
+// forecast's parameters, a table of the forecast windows, and a timeline
+// chart for each night of the forecast.
+func outputPDF(jData *jupiterData) error {
+	return writePDF(os.Stdout, jData)
+}
+
+func writePDF(w io.Writer, jData *jupiterData) error {
+	loc := jData.displayLocation()
+	windows := jData.Windows()
+
+	pdf := fpdf.New("P", "mm", "Letter", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	pdf.SetTitle("Jovian Decameter Radio Storm Forecast", false)
+	pdf.SetCreator(fmt.Sprintf("jovian-noise %s", version), false)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pageWidth, pageHeight := pdf.GetPageSize()
+	contentWidth := pageWidth - 2*pdfMargin
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-pdfMargin + 4)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(0x66, 0x66, 0x66)
+		pdf.CellFormat(0, 4, fmt.Sprintf("jovian-noise %s - page %d", version, pdf.PageNo()), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	// header page
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.CellFormat(0, 12, "Jovian Decameter Radio Storm Forecast", "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+
+	zone := "UTC"
+	if name, offset := jData.zoneInfo(); name != "" {
+		zone = fmt.Sprintf("%s (%s)", name, offset)
+	}
+	observer := "Not given; results are not limited to when Jupiter is up"
+	if jData.LocalForecast {
+		lat, lon := jData.displayCoords()
+		observer = tr(fmt.Sprintf("%d°, %d°", lat, lon))
+	}
+	var recommended int
+	for _, fw := range windows {
+		if fw.Recommended() {
+			recommended++
+		}
+	}
+	params := [][2]string{
+		{"Forecast from", jData.StartTime.In(loc).Format(htmlTimeFormat)},
+		{"Until", jData.EndTime.In(loc).Format(htmlTimeFormat)},
+		{"Duration", jData.Duration.String()},
+		{"Interval", fmt.Sprintf("%d minutes", jData.Interval)},
+		{"Time zone", zone},
+		{"Observer location", observer},
+		{"Windows", fmt.Sprintf("%d", len(windows))},
+	}
+	if jData.LocalForecast {
+		params = append(params, [2]string{"Recommended windows", fmt.Sprintf("%d", recommended)})
+	}
+	for _, p := range params {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(50, 8, p[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.CellFormat(0, 8, p[1], "", 1, "L", false, 0, "")
+	}
+
+	pdf.Ln(8)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 8, "Legend", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	legend := []pdfLegendEntry{
+		{pdfSourceColors[IoA], IoA.String()},
+		{pdfSourceColors[IoB], IoB.String()},
+		{pdfSourceColors[IoC], IoC.String()},
+		{pdfSourceColors[NonIoA], NonIoA.String()},
+	}
+	if jData.LocalForecast {
+		legend = append(legend, pdfLegendEntry{pdfJupiterUp, "Jupiter above the horizon"}, pdfLegendEntry{pdfNight, "Sun below the horizon"})
+	}
+	for _, l := range legend {
+		y := pdf.GetY()
+		pdfFill(pdf, l.c)
+		pdf.Rect(pdfMargin, y+1.5, 5, 4, "F")
+		pdf.SetX(pdfMargin + 8)
+		pdf.CellFormat(0, 7, l.label, "", 1, "L", false, 0, "")
+	}
+
+	// window table
+	columns := []pdfColumn{
+		{"Date", 34}, {"Start", 22}, {"End", 22}, {"Duration", 26}, {"Source", 34},
+	}
+	if jData.LocalForecast {
+		columns = append(columns, pdfColumn{"Peak Alt.", 26}, pdfColumn{"Rec.", 16})
+	}
+	tableHeader := func() {
+		pdf.SetFont("Helvetica", "B", 16)
+		pdf.CellFormat(0, 10, "Forecast Windows", "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.SetFillColor(0xdd, 0xdd, 0xdd)
+		for _, c := range columns {
+			pdf.CellFormat(c.width, pdfRowHeight, c.title, "B", 0, "L", true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 10)
+	}
+	pdf.AddPage()
+	tableHeader()
+	if len(windows) == 0 {
+		pdf.CellFormat(0, pdfRowHeight, "No radio storms are forecast for this period.", "", 1, "L", false, 0, "")
+	}
+	for _, fw := range windows {
+		if pdf.GetY()+pdfRowHeight > pageHeight-pdfMargin {
+			pdf.AddPage()
+			tableHeader()
+		}
+		start := fw.Start.In(loc)
+		end := fw.End.In(loc)
+		cells := []string{start.Format("Mon Jan 02"), start.Format("15:04"), end.Format("15:04"), fw.Duration().String(), fw.RadioSource.String()}
+		if jData.LocalForecast {
+			var peak string
+			if p, ok := fw.PeakAltitude(); ok {
+				peak = tr(fmt.Sprintf("%.1f°", p.Deg()))
+			}
+			rec := "N"
+			if fw.Recommended() {
+				rec = "Y"
+			}
+			cells = append(cells, peak, rec)
+		}
+		y := pdf.GetY()
+		for i, c := range cells {
+			x := pdf.GetX()
+			if columns[i].title == "Source" {
+				pdfFill(pdf, pdfSourceColors[fw.RadioSource])
+				pdf.Rect(x, y+1.5, 3, 3, "F")
+				pdf.SetX(x + 4)
+				pdf.CellFormat(columns[i].width-4, pdfRowHeight, c, "", 0, "L", false, 0, "")
+				continue
+			}
+			pdf.CellFormat(columns[i].width, pdfRowHeight, c, "", 0, "L", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	// nightly timelines
+	up := jData.jupiterUp()
+	nightHeader := func() {
+		pdf.SetFont("Helvetica", "B", 16)
+		pdf.CellFormat(0, 10, "Nightly Timelines", "", 1, "L", false, 0, "")
+	}
+	pdf.AddPage()
+	nightHeader()
+	for _, night := range localNights(jData.StartTime, jData.EndTime, loc) {
+		if pdf.GetY()+pdfNightHeight > pageHeight-pdfMargin {
+			pdf.AddPage()
+			nightHeader()
+		}
+		y := pdf.GetY()
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(0, 5, fmt.Sprintf("%s - %s", night.Start.Format("Mon Jan 02"), night.End.Format("Mon Jan 02")), "", 1, "L", false, 0, "")
+		barY := y + 5
+		nightLen := float64(night.End.Sub(night.Start))
+		xOf := func(t time.Time) float64 {
+			return pdfMargin + float64(t.Sub(night.Start))/nightLen*contentWidth
+		}
+		drawSpan := func(ts timeSpan, top float64, height float64) {
+			if c, ok := ts.clip(night); ok {
+				pdf.Rect(xOf(c.Start), top, xOf(c.End)-xOf(c.Start), height, "F")
+			}
+		}
+
+		pdfFill(pdf, pdfDaylight)
+		pdf.Rect(pdfMargin, barY, contentWidth, pdfBarHeight, "F")
+		if jData.LocalForecast {
+			pdfFill(pdf, pdfNight)
+			for _, d := range sunDown(night, jData.Coords) {
+				drawSpan(d, barY, pdfBarHeight)
+			}
+			pdfFill(pdf, pdfJupiterUp)
+			for _, u := range up {
+				drawSpan(u, barY+pdfBarHeight-2, 2)
+			}
+		}
+		for _, fw := range windows {
+			pdfFill(pdf, pdfSourceColors[fw.RadioSource])
+			drawSpan(timeSpan{fw.Start, fw.End}, barY+1.5, pdfBarHeight-4)
+		}
+
+		// hour ticks every two hours
+		pdf.SetFont("Helvetica", "", 7)
+		pdf.SetDrawColor(0x99, 0x99, 0x99)
+		pdf.SetLineWidth(0.1)
+		for _, t := range pdfHourTicks(night) {
+			x := xOf(t)
+			pdf.Line(x, barY+pdfBarHeight, x, barY+pdfBarHeight+1)
+			label := fmt.Sprintf("%02d", t.Hour())
+			pdf.Text(x-pdf.GetStringWidth(label)/2, barY+pdfBarHeight+4, label)
+		}
+		pdf.SetY(y + pdfNightHeight)
+	}
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// pdfHourTicks returns where the hour ticks go on a night's timeline: every
+// two hours by the local clock, so they stay on the hour on the nights the
+// clocks change. An hour that's skipped when the clocks go forward doesn't
+// get a tick.
+func pdfHourTicks(night timeSpan) []time.Time {
+	ticks := make([]time.Time, 0)
+	s := night.Start
+	for h := 0; ; h += 2 {
+		t := time.Date(s.Year(), s.Month(), s.Day(), s.Hour()+h, 0, 0, 0, s.Location())
+		if t.After(night.End) {
+			return ticks
+		}
+		if t.Hour() == (s.Hour()+h)%24 {
+			ticks = append(ticks, t)
+		}
+	}
+}
+
+func pdfFill(pdf *fpdf.Fpdf, c pdfColor) {
+	pdf.SetFillColor(c.r, c.g, c.b)
+}
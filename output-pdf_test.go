@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePDF(t *testing.T) {
+	jData := testForecast(t)
+	var b bytes.Buffer
+	if err := writePDF(&b, jData); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b.Bytes(), []byte("%PDF-")) {
+		t.Errorf("output doesn't start with %%PDF: %.10q", b.String())
+	}
+	if !bytes.HasSuffix(bytes.TrimSpace(b.Bytes()), []byte("%%EOF")) {
+		t.Errorf("output doesn't end with %%%%EOF")
+	}
+}
+
+func TestPDFHourTicks(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		day   time.Time
+		hours string
+		hrs   time.Duration
+	}{
+		{"ordinary night", time.Date(2024, 3, 1, 12, 0, 0, 0, denver), "12 14 16 18 20 22 00 02 04 06 08 10 12", 24},
+		{"clocks go forward", time.Date(2024, 3, 9, 12, 0, 0, 0, denver), "12 14 16 18 20 22 00 04 06 08 10 12", 23},
+		{"clocks go back", time.Date(2024, 11, 2, 12, 0, 0, 0, denver), "12 14 16 18 20 22 00 02 04 06 08 10 12", 25},
+		{"UTC", time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC), "12 14 16 18 20 22 00 02 04 06 08 10 12", 24},
+	}
+	for _, tt := range tests {
+		nights := localNights(tt.day, tt.day.Add(time.Hour), tt.day.Location())
+		if len(nights) != 1 {
+			t.Fatalf("%s: got %d nights", tt.name, len(nights))
+		}
+		night := nights[0]
+		if got := night.End.Sub(night.Start); got != tt.hrs*time.Hour {
+			t.Errorf("%s: the night is %s long, want %d hours", tt.name, got, tt.hrs)
+		}
+		ticks := pdfHourTicks(night)
+		labels := make([]string, 0, len(ticks))
+		for _, tick := range ticks {
+			labels = append(labels, fmt.Sprintf("%02d", tick.Hour()))
+			if tick.Minute() != 0 || tick.Before(night.Start) || tick.After(night.End) {
+				t.Errorf("%s: tick at %s isn't on the hour during the night", tt.name, tick)
+			}
+		}
+		if got := strings.Join(labels, " "); got != tt.hours {
+			t.Errorf("%s: ticks are %s, want %s", tt.name, got, tt.hours)
+		}
+	}
+}
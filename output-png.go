@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"time"
+)
+
+// Layout of the observing planner heatmap, in pixels.
+const (
+	pngMarginLeft   = 100
+	pngMarginTop    = 56
+	pngMarginRight  = 20
+	pngMarginBottom = 60
+	pngPlotWidth    = 24 * 24
+	pngRowHeight    = 14
+	pngFontScale    = 2
+)
+
+var (
+	pngBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	pngText       = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	pngGrid       = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	pngEmpty      = color.RGBA{0x3c, 0x3c, 0x50, 0xff}
+)
+
+var pngSourceColors = map[radioSource]color.RGBA{
+	IoA:    {0xd6, 0x27, 0x28, 0xff},
+	IoB:    {0x1f, 0x77, 0xb4, 0xff},
+	IoC:    {0x2c, 0xa0, 0x2c, 0xff},
+	NonIoA: {0x94, 0x67, 0xbd, 0xff},
+}
+
+// How much to dim a cell when the Sun is up.
+const pngSunUpDim = 0.55
+
+// outputPNG draws a calendar heatmap of the forecast, with a row for each
+// local day and the local time of day across. Cells are coloured by the
+// active radio source, and dimmed when the Sun is up (for local forecasts).
+// A local forecast only has samples while Jupiter is above the horizon, so
+// the cells when it's down are empty.
+func outputPNG(jData *jupiterData) error {
+	return writePNG(os.Stdout, jData)
+}
+
+func writePNG(w io.Writer, jData *jupiterData) error {
+	loc := jData.displayLocation()
+	start := jData.StartTime.In(loc)
+	firstDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+
+	days := make([]timeSpan, 0)
+	for day := firstDay; day.Before(jData.EndTime); day = day.AddDate(0, 0, 1) {
+		days = append(days, timeSpan{day, day.AddDate(0, 0, 1)})
+	}
+
+	width := pngMarginLeft + pngPlotWidth + pngMarginRight
+	height := pngMarginTop + len(days)*pngRowHeight + pngMarginBottom
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(pngBackground), image.Point{}, draw.Src)
+
+	title := "Jovian radio forecast " + start.Format("2006-01-02") + " - " + jData.EndTime.In(loc).Format("2006-01-02")
+	if name, offset := jData.zoneInfo(); name != "" {
+		title += " " + name + " (" + offset + ")"
+	} else {
+		title += " UTC"
+	}
+	drawText(img, pngMarginLeft, 10, title, pngFontScale, pngText)
+	for h := 0; h <= 24; h += 3 {
+		x := pngMarginLeft + h*pngPlotWidth/24
+		label := fmt.Sprintf("%02d", h)
+		drawText(img, x-textWidth(label, pngFontScale)/2, pngMarginTop-18, label, pngFontScale, pngText)
+	}
+
+	samples := make(map[int64]*forecastInterval, len(jData.Intervals))
+	for _, fi := range jData.Intervals {
+		samples[fi.Instant.Unix()] = fi
+	}
+	step := time.Duration(jData.Interval) * time.Minute
+
+	row := 0
+	for t := jData.StartTime; t.Before(jData.EndTime); t = t.Add(step) {
+		cell := timeSpan{t, t.Add(step)}
+		for row < len(days)-1 && !cell.Start.Before(days[row].End) {
+			row++
+		}
+
+		base := pngEmpty
+		if fi, ok := samples[t.Unix()]; ok {
+			base = pngSourceColors[fi.RadioSource]
+		}
+		c := base
+		if jData.LocalForecast && sunUp(t.Add(step/2), jData.Coords) {
+			c = dimColor(base, pngSunUpDim)
+		}
+
+		// a cell may cross midnight, so draw it in every row it
+		// touches.
+		for r := row; r < len(days); r++ {
+			clipped, ok := cell.clip(days[r])
+			if !ok {
+				break
+			}
+			dayLen := float64(days[r].End.Sub(days[r].Start))
+			x0 := pngMarginLeft + int(float64(clipped.Start.Sub(days[r].Start))/dayLen*pngPlotWidth)
+			x1 := pngMarginLeft + int(float64(clipped.End.Sub(days[r].Start))/dayLen*pngPlotWidth)
+			y0 := pngMarginTop + r*pngRowHeight
+			draw.Draw(img, image.Rect(x0, y0, x1, y0+pngRowHeight-1), image.NewUniform(c), image.Point{}, draw.Src)
+		}
+	}
+
+	for r, day := range days {
+		y := pngMarginTop + r*pngRowHeight
+		drawText(img, 8, y+2, day.Start.Format("Mon Jan 02"), pngFontScale, pngText)
+	}
+	for h := 0; h <= 24; h += 3 {
+		x := pngMarginLeft + h*pngPlotWidth/24
+		draw.Draw(img, image.Rect(x, pngMarginTop-4, x+1, pngMarginTop+len(days)*pngRowHeight), image.NewUniform(pngGrid), image.Point{}, draw.Src)
+	}
+
+	// legend
+	lx := pngMarginLeft
+	ly := pngMarginTop + len(days)*pngRowHeight + 14
+	for _, rs := range []radioSource{IoA, IoB, IoC, NonIoA} {
+		draw.Draw(img, image.Rect(lx, ly, lx+10, ly+10), image.NewUniform(pngSourceColors[rs]), image.Point{}, draw.Src)
+		drawText(img, lx+14, ly, rs.String(), pngFontScale, pngText)
+		lx += 14 + textWidth(rs.String(), pngFontScale) + 16
+	}
+	if jData.LocalForecast {
+		lx = pngMarginLeft
+		ly += 18
+		draw.Draw(img, image.Rect(lx, ly, lx+10, ly+10), image.NewUniform(dimColor(pngSourceColors[IoA], pngSunUpDim)), image.Point{}, draw.Src)
+		drawText(img, lx+14, ly, "Sun up", pngFontScale, pngText)
+	}
+
+	return png.Encode(w, img)
+}
+
+func dimColor(c color.RGBA, f float64) color.RGBA {
+	return color.RGBA{uint8(float64(c.R) * f), uint8(float64(c.G) * f), uint8(float64(c.B) * f), c.A}
+}
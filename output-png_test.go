@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func TestWritePNG(t *testing.T) {
+	tests := []struct {
+		name  string
+		jData func(t *testing.T) *jupiterData
+		days  int
+	}{
+		{
+			name:  "local forecast",
+			jData: testForecast,
+			// 1 March to 15 March, Boulder time
+			days: 15,
+		},
+		{
+			name: "no samples",
+			jData: func(t *testing.T) *jupiterData {
+				start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+				return &jupiterData{StartTime: start, EndTime: start.Add(3 * oneDay), Duration: 3 * oneDay, Interval: 30}
+			},
+			days: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := writePNG(&b, tt.jData(t)); err != nil {
+				t.Fatal(err)
+			}
+			img, err := png.Decode(&b)
+			if err != nil {
+				t.Fatalf("the PNG doesn't decode: %s", err)
+			}
+			width := pngMarginLeft + pngPlotWidth + pngMarginRight
+			height := pngMarginTop + tt.days*pngRowHeight + pngMarginBottom
+			if got := img.Bounds().Size(); got.X != width || got.Y != height {
+				t.Errorf("the image is %dx%d, want %dx%d", got.X, got.Y, width, height)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Layout of the CML/Io phase diagram, in SVG user units.
+const (
+	svgMarginLeft   float64 = 70
+	svgMarginTop    float64 = 60
+	svgMarginRight  float64 = 200
+	svgMarginBottom float64 = 60
+	svgPlotSize     float64 = 640
+	svgScale        float64 = svgPlotSize / 360
+)
+
+var svgSourceColors = map[radioSource]string{
+	IoA:    "#d62728",
+	IoB:    "#1f77b4",
+	IoC:    "#2ca02c",
+	NonIoA: "#9467bd",
+}
+
+// outputSVG draws the source regions in the CML/Io phase plane, with the
+// forecast's samples on top of them.
+func outputSVG(jData *jupiterData) error {
+	return writeSVG(os.Stdout, jData)
+}
+
+func writeSVG(out io.Writer, jData *jupiterData) error {
+	w := bufio.NewWriter(out)
+	width := svgMarginLeft + svgPlotSize + svgMarginRight
+	height := svgMarginTop + svgPlotSize + svgMarginBottom
+	loc := jData.displayLocation()
+
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(w, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%g\" height=\"%g\" viewBox=\"0 0 %g %g\" font-family=\"sans-serif\" font-size=\"12\">\n", width, height, width, height)
+	fmt.Fprintf(w, "<title>Jovian decameter radio sources: CML vs. Io phase</title>\n")
+	fmt.Fprintf(w, "<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+	fmt.Fprintf(w, "<text x=\"%g\" y=\"25\" font-size=\"16\">Jovian Decameter Radio Storm Forecast</text>\n", svgMarginLeft)
+	fmt.Fprintf(w, "<text x=\"%g\" y=\"45\">%s until %s</text>\n", svgMarginLeft, jData.StartTime.In(loc).Format(htmlTimeFormat), jData.EndTime.In(loc).Format(htmlTimeFormat))
+
+	// source regions, with non-Io-A drawn first since it's underneath
+	// Io-A.
+	fmt.Fprintf(w, "<g id=\"regions\" fill-opacity=\"0.25\" stroke-width=\"1\">\n")
+	for i := len(sourceRegions) - 1; i >= 0; i-- {
+		sr := sourceRegions[i]
+		x, y := svgPoint(sr.CML.Min, sr.IoPhase.Max)
+		color := svgSourceColors[sr.RadioSource]
+		fmt.Fprintf(w, "<rect x=\"%.2f\" y=\"%.2f\" width=\"%.2f\" height=\"%.2f\" fill=\"%s\" stroke=\"%s\"><title>%s</title></rect>\n", x, y, (sr.CML.Max-sr.CML.Min)*svgScale, (sr.IoPhase.Max-sr.IoPhase.Min)*svgScale, color, color, sr.RadioSource)
+		lx, ly := svgPoint((sr.CML.Min+sr.CML.Max)/2, sr.IoPhase.Max)
+		fmt.Fprintf(w, "<text x=\"%.2f\" y=\"%.2f\" text-anchor=\"middle\" fill=\"%s\" fill-opacity=\"1\" font-weight=\"bold\">%s</text>\n", lx, ly-4, color, sr.RadioSource)
+	}
+	fmt.Fprintf(w, "</g>\n")
+
+	// axes and grid
+	fmt.Fprintf(w, "<g id=\"axes\" stroke=\"#ccc\" stroke-width=\"0.5\">\n")
+	for d := 0.0; d <= 360; d += 30 {
+		x0, y0 := svgPoint(d, 0)
+		x1, y1 := svgPoint(d, 360)
+		fmt.Fprintf(w, "<line x1=\"%.2f\" y1=\"%.2f\" x2=\"%.2f\" y2=\"%.2f\"/>\n", x0, y0, x1, y1)
+		fmt.Fprintf(w, "<text x=\"%.2f\" y=\"%.2f\" text-anchor=\"middle\" stroke=\"none\" fill=\"black\">%g</text>\n", x0, y0+16, d)
+		x0, y0 = svgPoint(0, d)
+		x1, y1 = svgPoint(360, d)
+		fmt.Fprintf(w, "<line x1=\"%.2f\" y1=\"%.2f\" x2=\"%.2f\" y2=\"%.2f\"/>\n", x0, y0, x1, y1)
+		fmt.Fprintf(w, "<text x=\"%.2f\" y=\"%.2f\" text-anchor=\"end\" stroke=\"none\" fill=\"black\">%g</text>\n", x0-6, y0+4, d)
+	}
+	fmt.Fprintf(w, "</g>\n")
+	fmt.Fprintf(w, "<rect x=\"%g\" y=\"%g\" width=\"%g\" height=\"%g\" fill=\"none\" stroke=\"black\"/>\n", svgMarginLeft, svgMarginTop, svgPlotSize, svgPlotSize)
+	fmt.Fprintf(w, "<text x=\"%g\" y=\"%g\" text-anchor=\"middle\">System III CML (°)</text>\n", svgMarginLeft+svgPlotSize/2, svgMarginTop+svgPlotSize+40)
+	fmt.Fprintf(w, "<text transform=\"translate(%g %g) rotate(-90)\" text-anchor=\"middle\">Io phase (°)</text>\n", svgMarginLeft-45, svgMarginTop+svgPlotSize/2)
+
+	// the forecast track. Consecutive samples are joined, unless the
+	// track wraps around the edge of the plot.
+	step := time.Duration(jData.Interval) * time.Minute
+	fmt.Fprintf(w, "<g id=\"track\">\n")
+	var prev *forecastInterval
+	for _, fi := range jData.Intervals {
+		if prev != nil && fi.Instant.Sub(prev.Instant) == step && math.Abs(fi.Meridian.Deg()-prev.Meridian.Deg()) < 180 && math.Abs(fi.IoPhase.Deg()-prev.IoPhase.Deg()) < 180 {
+			x0, y0 := svgPoint(prev.Meridian.Deg(), prev.IoPhase.Deg())
+			x1, y1 := svgPoint(fi.Meridian.Deg(), fi.IoPhase.Deg())
+			fmt.Fprintf(w, "<line x1=\"%.2f\" y1=\"%.2f\" x2=\"%.2f\" y2=\"%.2f\" stroke=\"#555\" stroke-width=\"0.75\"/>\n", x0, y0, x1, y1)
+		}
+		prev = fi
+	}
+	for _, fi := range jData.Intervals {
+		x, y := svgPoint(fi.Meridian.Deg(), fi.IoPhase.Deg())
+		class := svgTrackClass(fi)
+		fmt.Fprintf(w, "<circle cx=\"%.2f\" cy=\"%.2f\" r=\"3\" fill=\"%s\" stroke=\"black\" stroke-width=\"0.5\"><title>%s %s: CML %.1f°, Io phase %.1f°</title></circle>\n", x, y, svgTrackColors[class], fi.Instant.In(loc).Format(htmlTimeFormat), fi.RadioSource, fi.Meridian.Deg(), fi.IoPhase.Deg())
+	}
+	fmt.Fprintf(w, "</g>\n")
+
+	// legend
+	lx := svgMarginLeft + svgPlotSize + 20
+	ly := svgMarginTop + 10
+	fmt.Fprintf(w, "<g id=\"legend\">\n")
+	for _, rs := range []radioSource{IoA, IoB, IoC, NonIoA} {
+		fmt.Fprintf(w, "<rect x=\"%g\" y=\"%g\" width=\"14\" height=\"14\" fill=\"%s\" fill-opacity=\"0.25\" stroke=\"%s\"/>\n", lx, ly, svgSourceColors[rs], svgSourceColors[rs])
+		fmt.Fprintf(w, "<text x=\"%g\" y=\"%g\">%s</text>\n", lx+22, ly+12, rs)
+		ly += 22
+	}
+	ly += 10
+	classes := []string{"sample"}
+	if jData.LocalForecast {
+		classes = []string{"recommended", "up"}
+	}
+	for _, class := range classes {
+		fmt.Fprintf(w, "<circle cx=\"%g\" cy=\"%g\" r=\"4\" fill=\"%s\" stroke=\"black\" stroke-width=\"0.5\"/>\n", lx+7, ly+7, svgTrackColors[class])
+		fmt.Fprintf(w, "<text x=\"%g\" y=\"%g\">%s</text>\n", lx+22, ly+12, svgTrackLabels[class])
+		ly += 22
+	}
+	fmt.Fprintf(w, "</g>\n")
+
+	fmt.Fprintf(w, "</svg>\n")
+	return w.Flush()
+}
+
+var svgTrackColors = map[string]string{
+	"recommended": "#ffcc00",
+	"up":          "#ff7f0e",
+	"sample":      "#ff7f0e",
+}
+
+var svgTrackLabels = map[string]string{
+	"recommended": "Recommended",
+	"up":          "Jupiter above horizon",
+	"sample":      "Forecast sample",
+}
+
+// svgTrackClass sorts a forecast sample by whether it's recommended. With an
+// observer location the forecast only has samples with Jupiter above the
+// horizon; samples from forecasts without one are just samples.
+func svgTrackClass(fi *forecastInterval) string {
+	switch {
+	case fi.AltAz == nil:
+		return "sample"
+	case fi.Recommended():
+		return "recommended"
+	default:
+		return "up"
+	}
+}
+
+// svgPoint converts a CML and Io phase, in degrees, to a point on the
+// diagram.
+func svgPoint(cml float64, ioPhase float64) (float64, float64) {
+	return svgMarginLeft + cml*svgScale, svgMarginTop + (360-ioPhase)*svgScale
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"testing"
+)
+
+func TestWriteSVG(t *testing.T) {
+	jData := testForecast(t)
+	var b bytes.Buffer
+	if err := writeSVG(&b, jData); err != nil {
+		t.Fatal(err)
+	}
+
+	d := xml.NewDecoder(&b)
+	var root *xml.StartElement
+	var group string
+	var samples, regions int
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("the SVG isn't valid XML: %s", err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root == nil {
+			se := se.Copy()
+			root = &se
+		}
+		switch se.Name.Local {
+		case "g":
+			for _, a := range se.Attr {
+				if a.Name.Local == "id" {
+					group = a.Value
+				}
+			}
+		case "circle":
+			if group == "track" {
+				samples++
+			}
+		case "rect":
+			if group == "regions" {
+				regions++
+			}
+		}
+	}
+	if root == nil || root.Name.Local != "svg" || root.Name.Space != "http://www.w3.org/2000/svg" {
+		t.Fatalf("the root element is %v, want an SVG svg element", root)
+	}
+	if samples != len(jData.Intervals) {
+		t.Errorf("the track has %d samples, want %d", samples, len(jData.Intervals))
+	}
+	if regions != len(sourceRegions) {
+		t.Errorf("%d source regions are drawn, want %d", regions, len(sourceRegions))
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	sexa "github.com/soniakeys/sexagesimal"
+	"github.com/soniakeys/unit"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"text/tabwriter"
 	"text/template"
@@ -25,12 +28,17 @@ type textOutput struct {
 }
 
 func outputJSON(jData *jupiterData) error {
-	if j, err := json.MarshalIndent(jData, "", "\t"); err != nil {
+	return writeJSON(os.Stdout, jData)
+}
+
+// writeJSON writes the forecast in the '-output json' format.
+func writeJSON(w io.Writer, jData *jupiterData) error {
+	j, err := json.MarshalIndent(jData, "", "\t")
+	if err != nil {
 		return err
-	} else {
-		os.Stdout.Write(j)
 	}
-	return nil
+	_, err = w.Write(j)
+	return err
 }
 
 func outputText(jData *jupiterData) error {
@@ -44,29 +52,21 @@ func outputText(jData *jupiterData) error {
 	outData := new(textOutput)
 	outData.Start = jData.StartTime
 	outData.End = jData.EndTime
-	outData.Lat = int(jData.Coords.Lat.Deg())
-	outData.Lon = -int(jData.Coords.Lon.Deg())
+	outData.Lat, outData.Lon = jData.displayCoords()
 	outData.Local = jData.LocalForecast
-	if jData.Location != nil {
-		ztz, zoff := jData.StartTime.In(jData.Location).Zone()
-		if jData.Location != time.Local {
-			outData.Location = jData.Location.String()
-		} else {
-			outData.Location = ztz
-		}
+	outData.Location, outData.Offset = jData.zoneInfo()
 
-		zhours := zoff / 60 / 60
-		zmin := zoff / 60 % 60
-		if zmin < 0 {
-			zmin = -zmin
-		}
-		outData.Offset = fmt.Sprintf("%+03d%02d", zhours, zmin)
-	}
-	if outData.Lon < -180 {
-		outData.Lon += 360
+	outData.Data = intervalTable(jData, jData.Intervals)
+
+	if err = tmpl.Execute(os.Stdout, outData); err != nil {
+		return err
 	}
 
-	// the actual data
+	return nil
+}
+
+// intervalTable formats forecast intervals as the table in the text output.
+func intervalTable(jData *jupiterData, intervals []*forecastInterval) string {
 	var b bytes.Buffer
 	bio := bufio.NewWriter(&b)
 	w := tabwriter.NewWriter(bio, 1, 8, 1, ' ', 0)
@@ -81,7 +81,7 @@ func outputText(jData *jupiterData) error {
 	if jData.LocalForecast {
 		fmt.Fprintf(w, "DY\tDate\tUTC\t%sPhase°\tCML\tDist.\tTrHA\tSrc\tAlt.\tAz.\tRec\t\n", localHeading)
 		fmt.Fprintf(w, "--\t----\t---\t%s------\t---\t-----\t----\t---\t----\t---\t---\t\n", localDash)
-		for _, fi := range jData.Intervals {
+		for _, fi := range intervals {
 			var rec string
 			if fi.Recommended() {
 				rec = "Y"
@@ -103,7 +103,7 @@ func outputText(jData *jupiterData) error {
 	} else {
 		fmt.Fprintf(w, "DY\tDate\tUTC\t%sPhase°\tCML\tDist.\tSrc\t\n", localHeading)
 		fmt.Fprintf(w, "--\t----\t---\t%s------\t---\t-----\t---\t\n", localDash)
-		for _, fi := range jData.Intervals {
+		for _, fi := range intervals {
 			var localData string
 			if jData.Location != nil {
 				var nextDay string
@@ -119,11 +119,50 @@ func outputText(jData *jupiterData) error {
 
 	w.Flush()
 	bio.Flush()
-	outData.Data = strings.TrimSpace(b.String())
+	return strings.TrimSpace(b.String())
+}
 
-	if err = tmpl.Execute(os.Stdout, outData); err != nil {
+// outputTemplate renders the forecast through a user supplied text/template
+// file. Unlike the built-in text output, the template gets the whole
+// jupiterData, so it can use the intervals, windows, and Jupiter's positions
+// however it likes.
+func outputTemplate(jData *jupiterData, tmplFile string) error {
+	return writeTemplate(os.Stdout, jData, tmplFile)
+}
+
+func writeTemplate(w io.Writer, jData *jupiterData, tmplFile string) error {
+	tmpl, err := template.New(filepath.Base(tmplFile)).Funcs(templateFuncs(jData)).ParseFiles(tmplFile)
+	if err != nil {
 		return err
 	}
+	return tmpl.Execute(w, jData)
+}
 
-	return nil
+func templateFuncs(jData *jupiterData) template.FuncMap {
+	return template.FuncMap{
+		"deg": func(a unit.Angle) float64 {
+			return a.Deg()
+		},
+		"sexa": func(a unit.Angle) *sexa.Angle {
+			return sexa.FmtAngle(a)
+		},
+		"hours": func(h unit.HourAngle) float64 {
+			return h.Hour()
+		},
+		"ra": func(ra unit.RA) *sexa.RA {
+			return sexa.FmtRA(ra)
+		},
+		"clock": func(t unit.Time) *sexa.Time {
+			return sexa.FmtTime(t)
+		},
+		"local": func(t time.Time) time.Time {
+			return t.In(jData.displayLocation())
+		},
+		"recommended": func(fi *forecastInterval) string {
+			if fi.Recommended() {
+				return "Y"
+			}
+			return "N"
+		},
+	}
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testForecast calculates a two week forecast for Boulder over the start of
+// daylight saving time, using the VSOP87 files in $VSOP87. The test is
+// skipped if there aren't any.
+func testForecast(t *testing.T) *jupiterData {
+	t.Helper()
+	if os.Getenv("VSOP87") == "" {
+		t.Skip("VSOP87 isn't set")
+	}
+	earth, jupiter, err := loadPlanets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := &forecastParams{
+		StartTime: "2024-03-01T00:00:00Z",
+		Duration:  14 * oneDay,
+		Interval:  defaultInterval,
+		Timezone:  "America/Denver",
+		Lat:       40,
+		Lon:       -105,
+		LatSet:    true,
+		LonSet:    true,
+		NonIoA:    true,
+	}
+	jData, err := fp.jupiterData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = calculateForecast(jData, earth, jupiter); err != nil {
+		t.Fatal(err)
+	}
+	if len(jData.Windows()) == 0 {
+		t.Fatal("the test forecast has no windows")
+	}
+	return jData
+}
+
+func TestWriteTemplate(t *testing.T) {
+	jData := testForecast(t)
+	tmplFile := filepath.Join(t.TempDir(), "windows.tmpl")
+	tmpl := `{{range .Windows}}{{.RadioSource}} {{(local .Start).Format "2006-01-02 15:04 MST"}}
+{{end}}{{range .Intervals}}{{printf "%.1f" (deg .Meridian)}} {{recommended .}}
+{{end}}`
+	if err := os.WriteFile(tmplFile, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := writeTemplate(&b, jData, tmplFile); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	windows := jData.Windows()
+	if len(lines) != len(windows)+len(jData.Intervals) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(windows)+len(jData.Intervals))
+	}
+	loc := jData.displayLocation()
+	for i, fw := range windows {
+		want := fmt.Sprintf("%s %s", fw.RadioSource, fw.Start.In(loc).Format("2006-01-02 15:04 MST"))
+		if lines[i] != want {
+			t.Errorf("window %d is %q, want %q", i, lines[i], want)
+		}
+	}
+	for i, fi := range jData.Intervals {
+		rec := "N"
+		if fi.Recommended() {
+			rec = "Y"
+		}
+		want := fmt.Sprintf("%.1f %s", fi.Meridian.Deg(), rec)
+		if got := lines[len(windows)+i]; got != want {
+			t.Errorf("interval %d is %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestWriteTemplateBadFile(t *testing.T) {
+	jData := &jupiterData{StartTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tmplFile := filepath.Join(t.TempDir(), "bad.tmpl")
+	if err := os.WriteFile(tmplFile, []byte("{{range .Windows}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeTemplate(new(bytes.Buffer), jData, tmplFile); err == nil {
+		t.Error("expected an error from a template that doesn't parse")
+	}
+	if err := writeTemplate(new(bytes.Buffer), jData, filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Error("expected an error from a template that doesn't exist")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/soniakeys/unit"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const defaultInterval int = 30
+const defaultDuration time.Duration = 30 * oneDay
+
+// forecastParams are the parameters for a forecast as they come in from the
+// command line or an HTTP request, before they've been checked.
+type forecastParams struct {
+	StartTime   string
+	Duration    time.Duration
+	Interval    int
+	Timezone    string
+	OffsetHours float64
+	LocalTZ     bool
+	Lat         int
+	Lon         int
+	LatSet      bool
+	LonSet      bool
+	NonIoA      bool
+	Sources     string
+}
+
+// paramError is a problem with one of a forecast's parameters. Param is the
+// parameter's name, without any leading '-'.
+type paramError struct {
+	Param   string
+	Message string
+}
+
+func (e *paramError) Error() string {
+	return e.Message
+}
+
+// flagName matches the flag names in a paramError's message, and not
+// negative numbers.
+var flagName = regexp.MustCompile(`(^|\s)-([a-z][a-z-]*)`)
+
+// plainMessage returns the error's message with the flag names in it written
+// like query parameters ('-offset-hours' becomes 'offset_hours'), for when
+// the parameters didn't come from the command line.
+func (e *paramError) plainMessage() string {
+	return flagName.ReplaceAllStringFunc(e.Message, func(m string) string {
+		return strings.Replace(strings.ReplaceAll(m, "-", "_"), "_", "", 1)
+	})
+}
+
+func newParamError(param string, format string, a ...interface{}) *paramError {
+	return &paramError{Param: param, Message: fmt.Sprintf(format, a...)}
+}
+
+// addForecastFlags adds the flags for a forecast's parameters to a flag set.
+// Call setFlags after the flags are parsed to find out whether -lat and -lon
+// were given.
+func addForecastFlags(fs *flag.FlagSet) *forecastParams {
+	fp := new(forecastParams)
+	fs.StringVar(&fp.StartTime, "start-time", "", "Start time (in RFC 3339 format) to calculate Jupiter radio storm forecasts (defaults to the start of the current hour)")
+	fs.DurationVar(&fp.Duration, "duration", defaultDuration, "Duration (in golang ParseDuration format) from the start time to calculate the forecast")
+	fs.IntVar(&fp.Interval, "interval", defaultInterval, "Interval in minutes to calculate the forecast")
+	fs.StringVar(&fp.Timezone, "timezone", "", "Optional timezone for displaying results. Conflicts with -offset-hours and -local.")
+	fs.Float64Var(&fp.OffsetHours, "offset-hours", 0, "Optional offset in hours east of UTC to display results. Offsets to the west should be given with negative numbers (e.g. '-offset-hours -7' or '-offset-hours 9.5'). Conflicts with -timezone and -local.")
+	fs.BoolVar(&fp.LocalTZ, "local", false, "Optionally use this computer's timzone to display results. Conflicts with -timezone and -offset-hours.")
+	fs.IntVar(&fp.Lat, "lat", 0, "Optional latitute. If given, will limit results to when Jupiter is above the horizon at this location. Requires -lon")
+	fs.IntVar(&fp.Lon, "lon", 0, "Optional longitude. If given, will limit results to when Jupiter is above the horizon at this location. Requires -lat")
+	fs.BoolVar(&fp.NonIoA, "non-io-a", false, "Include forecasts for the non-Io-A radio source.")
+	fs.StringVar(&fp.Sources, "sources", "", "Optional comma separated list of the radio sources to forecast (e.g. 'Io-A,Io-B'). Defaults to Io-A, Io-B, and Io-C. Overrides -non-io-a.")
+	return fp
+}
+
+// setFlags notes which of the forecast flags were actually given.
+func (fp *forecastParams) setFlags(fs *flag.FlagSet) {
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "lat":
+			fp.LatSet = true
+		case "lon":
+			fp.LonSet = true
+		}
+	})
+}
+
+// location returns the time zone to display results in, or nil if none was
+// given.
+func (fp *forecastParams) location() (*time.Location, error) {
+	roundOffset := int(fp.OffsetHours)
+	// bleh
+	if fp.Timezone != "" && roundOffset != 0 || fp.Timezone != "" && fp.LocalTZ || roundOffset != 0 && fp.LocalTZ {
+		return nil, newParamError("timezone", "One of the -timezone, -offset-hours, and -local flags can be specified, but not more than that. None are required, however.")
+	}
+
+	switch {
+	case fp.Timezone != "":
+		loc, err := time.LoadLocation(fp.Timezone)
+		if err != nil {
+			return nil, newParamError("timezone", "Error loading timezone %s: %s", fp.Timezone, err)
+		}
+		return loc, nil
+	case roundOffset != 0:
+		m := time.Duration(fp.OffsetHours * 60)
+		secondsEast := int((m * time.Minute).Seconds())
+		return time.FixedZone("Manual Offset Zone", secondsEast), nil
+	case fp.LocalTZ:
+		return time.Local, nil
+	}
+	return nil, nil
+}
+
+// jupiterData checks the parameters, and returns a jupiterData with them set
+// that's ready for calculateForecast.
+func (fp *forecastParams) jupiterData() (*jupiterData, error) {
+	jData := new(jupiterData)
+	jData.Intervals = make([]*forecastInterval, 0)
+
+	if fp.Interval < 1 {
+		return nil, newParamError("interval", "-interval must be at least 1 minute.")
+	}
+	if fp.Duration < time.Duration(fp.Interval)*time.Minute {
+		return nil, newParamError("duration", "-duration really should be longer than the interval specified.")
+	}
+
+	loc, err := fp.location()
+	if err != nil {
+		return nil, err
+	}
+	jData.Location = loc
+
+	jData.Duration = fp.Duration
+	jData.Interval = fp.Interval
+
+	if fp.Sources != "" {
+		jData.Sources, err = parseSources(fp.Sources)
+		if err != nil {
+			return nil, newParamError("sources", "%s", err)
+		}
+	} else {
+		jData.Sources = []radioSource{IoA, IoB, IoC}
+		if fp.NonIoA {
+			jData.Sources = append(jData.Sources, NonIoA)
+		}
+	}
+
+	if fp.StartTime == "" {
+		jData.StartTime = time.Now().UTC().Truncate(time.Hour)
+	} else {
+		t, err := time.Parse(time.RFC3339, fp.StartTime)
+		if err != nil {
+			return nil, newParamError("start-time", "%s", err)
+		}
+		jData.StartTime = t.UTC()
+	}
+
+	if fp.LatSet && !fp.LonSet || !fp.LatSet && fp.LonSet {
+		return nil, newParamError("lat", "Both -lat and -lon, or neither, must be supplied")
+	}
+
+	if fp.LatSet && fp.LonSet {
+		if fp.Lat < -90 || fp.Lat > 90 {
+			return nil, newParamError("lat", "-lat must be between -90 and 90.")
+		}
+		if fp.Lon < -180 || fp.Lon > 180 {
+			return nil, newParamError("lon", "-lon must be between -180 and 180.")
+		}
+		// for some reason this figures longitude backwards from the
+		// way everyone else does it.
+		lon := fp.Lon
+		if lon != 0 {
+			lon = -lon
+			if lon < 0 {
+				lon += 360
+			}
+		}
+
+		jData.Coords.Lon = unit.NewAngle('+', lon, 0, 0)
+		jData.Coords.Lat = unit.NewAngle('+', fp.Lat, 0, 0)
+		jData.LocalForecast = true
+	}
+
+	return jData, nil
+}
+
+// parseSources parses a comma separated list of radio source names, ignoring
+// case.
+func parseSources(s string) ([]radioSource, error) {
+	sources := make([]radioSource, 0)
+	seen := make(map[radioSource]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		// be forgiving about case here
+		for _, n := range radioSourceNames {
+			if strings.EqualFold(n, name) {
+				name = n
+			}
+		}
+		rs, err := RadioSourceFromString(name)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[rs] {
+			seen[rs] = true
+			sources = append(sources, rs)
+		}
+	}
+	return sources, nil
+}
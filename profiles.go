@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+)
+
+const defaultProfileDays = 14
+
+// observerProfile is a saved set of forecast parameters for a station, so
+// that a calendar can be subscribed to by name. Lat and Lon are optional,
+// like -lat and -lon.
+type observerProfile struct {
+	Name     string `json:"-"`
+	Lat      *int   `json:"lat,omitempty"`
+	Lon      *int   `json:"lon,omitempty"`
+	Sources  string `json:"sources,omitempty"`
+	NonIoA   bool   `json:"non_io_a,omitempty"`
+	Days     int    `json:"days,omitempty"`
+	Interval int    `json:"interval,omitempty"`
+}
+
+var profileNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// loadProfiles reads observer profiles from a JSON file, which holds an
+// object with the profiles keyed by name, like:
+//
+//	{"home": {"lat": 40, "lon": -105, "sources": "Io-A,Io-B", "days": 14}}
+func loadProfiles(path string) (map[string]*observerProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]*observerProfile)
+	if err = json.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("Error reading observer profiles from %s: %s", path, err)
+	}
+	for name, p := range profiles {
+		if !profileNameRe.MatchString(name) {
+			return nil, fmt.Errorf("Observer profile name '%s' may only have letters, numbers, '-', and '_'.", name)
+		}
+		p.Name = name
+		if p.Days == 0 {
+			p.Days = defaultProfileDays
+		}
+		if p.Days < 1 {
+			return nil, fmt.Errorf("Observer profile '%s' needs to cover at least one day.", name)
+		}
+		if _, err := p.params(time.Now()).jupiterData(); err != nil {
+			return nil, fmt.Errorf("Observer profile '%s' is invalid: %s", name, err)
+		}
+	}
+	return profiles, nil
+}
+
+// params returns the forecast parameters for the profile's next Days days
+// from the given start.
+func (p *observerProfile) params(start time.Time) *forecastParams {
+	fp := &forecastParams{
+		StartTime: start.UTC().Format(time.RFC3339),
+		Duration:  time.Duration(p.Days) * oneDay,
+		Interval:  p.Interval,
+		Sources:   p.Sources,
+		NonIoA:    p.NonIoA,
+	}
+	if fp.Interval == 0 {
+		fp.Interval = defaultInterval
+	}
+	if p.Lat != nil {
+		fp.Lat, fp.LatSet = *p.Lat, true
+	}
+	if p.Lon != nil {
+		fp.Lon, fp.LonSet = *p.Lon, true
+	}
+	return fp
+}
+
+// uidSuffix keeps the UIDs of different profiles' events apart.
+func (p *observerProfile) uidSuffix() string {
+	return "-" + p.Name
+}
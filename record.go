@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// rtl_tcp's commands are a byte followed by a big endian 32 bit parameter.
+const (
+	rtlTCPSetFrequency  byte = 0x01
+	rtlTCPSetSampleRate byte = 0x02
+	rtlTCPSetGainMode   byte = 0x03
+	rtlTCPSetGain       byte = 0x04
+)
+
+type rtlTCPCommand struct {
+	Cmd   byte
+	Param uint32
+}
+
+const recordingTimeFormat = "20060102T150405Z"
+
+// rtlTCPTuners are the tuner types rtl_tcp reports when a client connects.
+var rtlTCPTuners = []string{"unknown", "E4000", "FC0012", "FC0013", "FC2580", "R820T", "R828D"}
+
+// recorder records IQ samples from an rtl_tcp server. Gain is in dB, and is
+// automatic if it's nil. Upconverter is added to each frequency to get the
+// frequency to tune the RTL-SDR to.
+type recorder struct {
+	Addr            string
+	Dir             string
+	Frequency       int64
+	SourceFrequency map[radioSource]int64
+	Upconverter     int64
+	SampleRate      int
+	Gain            *float64
+	Timeout         time.Duration
+	Interval        time.Duration
+	Observer        *jsonObserver
+}
+
+// recordingSidecar is the JSON written next to each recording, describing it
+// and the forecast for the time it covers. It's written when recording
+// starts, and again with the end time and size when it stops. Synthetic
+// recordings from the synthesize command have one too, with what went into
+// them.
+type recordingSidecar struct {
+	File             string              `json:"file"`
+	Format           string              `json:"format"`
+	RadioSource      radioSource         `json:"radio_source"`
+	Start            time.Time           `json:"start"`
+	End              *time.Time          `json:"end,omitempty"`
+	FrequencyHz      int64               `json:"frequency_hz,omitempty"`
+	TunerFrequencyHz int64               `json:"tuner_frequency_hz,omitempty"`
+	SampleRateHz     int                 `json:"sample_rate_hz"`
+	GainDB           *float64            `json:"gain_db,omitempty"`
+	Tuner            string              `json:"tuner,omitempty"`
+	Bytes            int64               `json:"bytes"`
+	Samples          int64               `json:"samples"`
+	Observer         *jsonObserver       `json:"observer,omitempty"`
+	Window           *jsonWindow         `json:"window"`
+	Intervals        []*forecastInterval `json:"intervals"`
+	Synthesis        *jsonSynthesis      `json:"synthesis,omitempty"`
+	Version          string              `json:"jovian_noise_version"`
+}
+
+func recordCommand(args []string) error {
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	addr := flags.String("rtl-tcp", "localhost:1234", "Address of the rtl_tcp server to record from.")
+	dir := flags.String("dir", ".", "Directory to write recordings to.")
+	freq := flags.String("freq", defaultRigFrequency, "Frequency to record, in Hz or with a kHz or MHz suffix.")
+	sourceFreq := flags.String("source-freq", "", "Optional comma separated list of frequencies for particular radio sources, overriding -freq (e.g. 'Io-A=20.1MHz,Io-B=22.2MHz').")
+	upconverter := flags.String("upconverter", "", "Optional upconverter offset to add to the frequencies, like '125MHz'.")
+	sampleRate := flags.Int("sample-rate", 250000, "Sample rate in Hz. The RTL-SDR takes 225001 to 300000, and 900001 to 3200000.")
+	gain := flags.String("gain", "auto", "Tuner gain in dB, or 'auto'.")
+	timeout := flags.Duration("timeout", 10*time.Second, "How long to wait to connect to rtl_tcp.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise record [options]\n\nRuns until stopped, recording IQ samples from rtl_tcp during each forecast window.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("record doesn't take any arguments.")
+	}
+	if fp.StartTime != "" {
+		return fmt.Errorf("-start-time can't be used with record, which always starts now.")
+	}
+	base, err := fp.jupiterData()
+	if err != nil {
+		return err
+	}
+
+	rec := &recorder{Addr: *addr, Dir: *dir, SampleRate: *sampleRate, Timeout: *timeout, Interval: time.Duration(fp.Interval) * time.Minute}
+	if rec.Frequency, err = parseFrequency(*freq); err != nil {
+		return err
+	}
+	if rec.SourceFrequency, err = parseSourceFrequencies(*sourceFreq); err != nil {
+		return err
+	}
+	if *upconverter != "" {
+		if rec.Upconverter, err = parseFrequency(*upconverter); err != nil {
+			return err
+		}
+	}
+	if !(rec.SampleRate > 225000 && rec.SampleRate <= 300000) && !(rec.SampleRate > 900000 && rec.SampleRate <= 3200000) {
+		return fmt.Errorf("-sample-rate must be from 225001 to 300000, or 900001 to 3200000.")
+	}
+	if !strings.EqualFold(*gain, "auto") {
+		g, err := strconv.ParseFloat(*gain, 64)
+		if err != nil {
+			return fmt.Errorf("-gain must be a number of dB, or 'auto'.")
+		}
+		rec.Gain = &g
+	}
+	if base.LocalForecast {
+		lat, lon := coordsToDeg(base.Coords)
+		rec.Observer = &jsonObserver{LatitudeDeg: lat, LongitudeDeg: lon}
+	}
+	if st, err := os.Stat(rec.Dir); err != nil {
+		return err
+	} else if !st.IsDir() {
+		return fmt.Errorf("%s isn't a directory.", rec.Dir)
+	}
+
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+	ww := &windowWatcher{Params: fp, Leads: []time.Duration{0}, Horizon: 2 * oneDay, Step: oneDay, Calculate: p.forecast}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("jovian-noise %s recording from rtl_tcp at %s.", version, *addr)
+	// Recording blocks until the window closes, so a window that opens as
+	// another closes is seen as soon as the first recording stops.
+	err = ww.Run(ctx, func(ev *windowEvent) error {
+		if ev.Kind != windowOpens {
+			return nil
+		}
+		err := rec.record(ctx, ev.Window)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("Error recording the %s window: %s", ev.Window.RadioSource, err)
+		}
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Stopping.")
+		return nil
+	}
+	return err
+}
+
+// record connects to rtl_tcp, sets it up for the window's radio source, and
+// writes its samples to disk until the window ends or the context is
+// canceled. A window that's already over, because recording the one before
+// it ran past its end, is skipped.
+func (rec *recorder) record(ctx context.Context, fw *forecastWindow) error {
+	if !fw.End.After(time.Now()) {
+		log.Printf("Skipping the %s window, which ended at %s.", fw.RadioSource, fw.End.UTC().Format(time.RFC3339))
+		return nil
+	}
+	d := &net.Dialer{Timeout: rec.Timeout}
+	conn, err := d.DialContext(ctx, "tcp", rec.Addr)
+	if err != nil {
+		return fmt.Errorf("Error connecting to rtl_tcp at %s: %s", rec.Addr, err)
+	}
+	defer conn.Close()
+
+	tuner, err := readRTLTCPHeader(conn, rec.Timeout)
+	if err != nil {
+		return err
+	}
+
+	freq := rec.Frequency
+	if f, ok := rec.SourceFrequency[fw.RadioSource]; ok {
+		freq = f
+	}
+	tunerFreq := freq + rec.Upconverter
+	if tunerFreq > math.MaxUint32 {
+		return fmt.Errorf("%d Hz is too high a frequency for rtl_tcp.", tunerFreq)
+	}
+	cmds := []rtlTCPCommand{
+		{rtlTCPSetSampleRate, uint32(rec.SampleRate)},
+		{rtlTCPSetFrequency, uint32(tunerFreq)},
+	}
+	if rec.Gain == nil {
+		cmds = append(cmds, rtlTCPCommand{rtlTCPSetGainMode, 0})
+	} else {
+		// the gain is in tenths of a dB
+		cmds = append(cmds, rtlTCPCommand{rtlTCPSetGainMode, 1}, rtlTCPCommand{rtlTCPSetGain, uint32(int32(math.Round(*rec.Gain * 10)))})
+	}
+	conn.SetWriteDeadline(time.Now().Add(rec.Timeout))
+	for _, c := range cmds {
+		var b [5]byte
+		b[0] = c.Cmd
+		binary.BigEndian.PutUint32(b[1:], c.Param)
+		if _, err = conn.Write(b[:]); err != nil {
+			return fmt.Errorf("Error sending command 0x%02x to rtl_tcp at %s: %s", c.Cmd, rec.Addr, err)
+		}
+		log.Printf("%s <- 0x%02x %d", rec.Addr, c.Cmd, int32(c.Param))
+	}
+
+	start := time.Now().UTC()
+	name := fmt.Sprintf("%s-%s", fw.RadioSource.slug(), start.Format(recordingTimeFormat))
+	sc := &recordingSidecar{
+		File:             name + ".cu8",
+		Format:           "cu8",
+		RadioSource:      fw.RadioSource,
+		Start:            start,
+		FrequencyHz:      freq,
+		TunerFrequencyHz: tunerFreq,
+		SampleRateHz:     rec.SampleRate,
+		GainDB:           rec.Gain,
+		Tuner:            tuner,
+		Observer:         rec.Observer,
+		Window:           newJSONWindow(fw),
+		Intervals:        make([]*forecastInterval, 0),
+		Version:          version,
+	}
+	for _, fi := range fw.Intervals {
+		if fi.Instant.Add(rec.Interval).After(start) {
+			sc.Intervals = append(sc.Intervals, fi)
+		}
+	}
+	sidecarPath := filepath.Join(rec.Dir, name+".json")
+	if err = writeSidecar(sidecarPath, sc); err != nil {
+		return err
+	}
+
+	f, err := os.Create(filepath.Join(rec.Dir, sc.File))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	log.Printf("Recording the %s window at %d Hz to %s until %s.", fw.RadioSource, freq, f.Name(), fw.End.UTC().Format(time.RFC3339))
+
+	// Stop reading when the window ends, or right away if we're told to
+	// stop.
+	conn.SetReadDeadline(fw.End)
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-stopped:
+		}
+	}()
+
+	// Copying straight to the file lets it be spliced from the connection
+	// where that's possible. The deadline's error then comes wrapped in
+	// the file's, so look for it rather than a net.Error.
+	n, err := io.Copy(f, conn)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = nil
+	} else if err == nil {
+		err = fmt.Errorf("rtl_tcp at %s closed the connection.", rec.Addr)
+	}
+
+	end := time.Now().UTC()
+	sc.End = &end
+	// only whole I/Q pairs count as samples
+	sc.Bytes, sc.Samples = n, n/2
+	if serr := writeSidecar(sidecarPath, sc); serr != nil && err == nil {
+		err = serr
+	}
+	log.Printf("Recorded %d samples to %s.", sc.Samples, f.Name())
+	return err
+}
+
+// readRTLTCPHeader reads the header rtl_tcp sends when a client connects:
+// 'RTL0', then the tuner type and how many gain settings it has. It returns
+// the tuner's name.
+func readRTLTCPHeader(conn net.Conn, timeout time.Duration) (string, error) {
+	var h [12]byte
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	if _, err := io.ReadFull(conn, h[:]); err != nil {
+		return "", fmt.Errorf("Error reading rtl_tcp's header: %s", err)
+	}
+	if string(h[:4]) != "RTL0" {
+		return "", fmt.Errorf("The server doesn't look like rtl_tcp; its header starts with %q.", h[:4])
+	}
+	t := binary.BigEndian.Uint32(h[4:8])
+	if int(t) < len(rtlTCPTuners) {
+		return rtlTCPTuners[t], nil
+	}
+	return rtlTCPTuners[0], nil
+}
+
+func writeSidecar(path string, sc *recordingSidecar) error {
+	j, err := json.MarshalIndent(sc, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(j, '\n'), 0644)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRTLTCP is a stand-in for rtl_tcp on a loopback port. It sends the
+// header for an R820T, then streams samples until the client hangs up, and
+// keeps the commands it was sent.
+type fakeRTLTCP struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	conns    int
+	commands []rtlTCPCommand
+}
+
+func newFakeRTLTCP(t *testing.T) *fakeRTLTCP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr := &fakeRTLTCP{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			fr.mu.Lock()
+			fr.conns++
+			fr.mu.Unlock()
+			go fr.serve(conn)
+		}
+	}()
+	return fr
+}
+
+func (fr *fakeRTLTCP) serve(conn net.Conn) {
+	defer conn.Close()
+	var h [12]byte
+	copy(h[:], "RTL0")
+	binary.BigEndian.PutUint32(h[4:8], 5)
+	binary.BigEndian.PutUint32(h[8:], 29)
+	if _, err := conn.Write(h[:]); err != nil {
+		return
+	}
+	go func() {
+		for {
+			var b [5]byte
+			if _, err := io.ReadFull(conn, b[:]); err != nil {
+				return
+			}
+			fr.mu.Lock()
+			fr.commands = append(fr.commands, rtlTCPCommand{b[0], binary.BigEndian.Uint32(b[1:])})
+			fr.mu.Unlock()
+		}
+	}()
+	// unsigned 8 bit I/Q at about 800 kB/s, a silent carrier
+	samples := bytes.Repeat([]byte{127, 128}, 2048)
+	for {
+		if _, err := conn.Write(samples); err != nil {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (fr *fakeRTLTCP) sent() (int, []rtlTCPCommand) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.conns, append([]rtlTCPCommand(nil), fr.commands...)
+}
+
+func TestRecord(t *testing.T) {
+	gain := 19.7
+	tests := []struct {
+		name string
+		rec  recorder
+		cmds []rtlTCPCommand
+		freq int64
+	}{
+		{
+			"automatic gain",
+			recorder{Frequency: 20100000, SampleRate: 250000},
+			[]rtlTCPCommand{{rtlTCPSetSampleRate, 250000}, {rtlTCPSetFrequency, 20100000}, {rtlTCPSetGainMode, 0}},
+			20100000,
+		},
+		{
+			"fixed gain",
+			recorder{Frequency: 20100000, SampleRate: 1024000, Gain: &gain},
+			[]rtlTCPCommand{{rtlTCPSetSampleRate, 1024000}, {rtlTCPSetFrequency, 20100000}, {rtlTCPSetGainMode, 1}, {rtlTCPSetGain, 197}},
+			20100000,
+		},
+		{
+			"source frequency through an upconverter",
+			recorder{Frequency: 20100000, SourceFrequency: map[radioSource]int64{IoB: 22200000}, Upconverter: 125000000, SampleRate: 250000},
+			[]rtlTCPCommand{{rtlTCPSetSampleRate, 250000}, {rtlTCPSetFrequency, 147200000}, {rtlTCPSetGainMode, 0}},
+			22200000,
+		},
+	}
+	for _, tt := range tests {
+		fr := newFakeRTLTCP(t)
+		rec := tt.rec
+		rec.Addr, rec.Dir, rec.Timeout, rec.Interval = fr.ln.Addr().String(), t.TempDir(), 5*time.Second, 30*time.Minute
+		now := time.Now()
+		fw := &forecastWindow{Start: now.Add(-50 * time.Minute), End: now.Add(300 * time.Millisecond), RadioSource: IoB}
+		fw.Intervals = []*forecastInterval{{Instant: fw.Start, RadioSource: IoB}, {Instant: fw.Start.Add(30 * time.Minute), RadioSource: IoB}}
+
+		if err := rec.record(context.Background(), fw); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if stopped := time.Now(); stopped.Before(fw.End) || stopped.After(fw.End.Add(2*time.Second)) {
+			t.Errorf("%s: recording stopped at %s, want %s", tt.name, stopped, fw.End)
+		}
+		_, cmds := fr.sent()
+		if len(cmds) != len(tt.cmds) {
+			t.Errorf("%s: sent %v, want %v", tt.name, cmds, tt.cmds)
+		} else {
+			for i := range cmds {
+				if cmds[i] != tt.cmds[i] {
+					t.Errorf("%s: sent %v, want %v", tt.name, cmds, tt.cmds)
+					break
+				}
+			}
+		}
+
+		sidecars, _ := filepath.Glob(filepath.Join(rec.Dir, "*.json"))
+		if len(sidecars) != 1 {
+			t.Errorf("%s: got sidecars %v", tt.name, sidecars)
+			continue
+		}
+		data, err := os.ReadFile(sidecars[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, []byte("{\n\t\"file\"")) {
+			t.Errorf("%s: the sidecar isn't indented with tabs:\n%.40s", tt.name, data)
+		}
+		sc := new(recordingSidecar)
+		if err = json.Unmarshal(data, sc); err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if sc.End == nil || sc.End.Before(fw.End) {
+			t.Errorf("%s: the sidecar says recording ended at %v, before the window did", tt.name, sc.End)
+		}
+		if sc.FrequencyHz != tt.freq || int64(tt.cmds[1].Param) != sc.TunerFrequencyHz || sc.SampleRateHz != rec.SampleRate || sc.Tuner != "R820T" {
+			t.Errorf("%s: the sidecar says %d Hz tuned to %d Hz at %d samples/s with an %s", tt.name, sc.FrequencyHz, sc.TunerFrequencyHz, sc.SampleRateHz, sc.Tuner)
+		}
+		if len(sc.Intervals) != 1 {
+			t.Errorf("%s: the sidecar has %d intervals, want only the one the recording covers", tt.name, len(sc.Intervals))
+		}
+		st, err := os.Stat(filepath.Join(rec.Dir, sc.File))
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if st.Size() == 0 || st.Size() != sc.Bytes || sc.Samples != sc.Bytes/2 {
+			t.Errorf("%s: recorded %d bytes, and the sidecar says %d bytes and %d samples", tt.name, st.Size(), sc.Bytes, sc.Samples)
+		}
+	}
+}
+
+func TestRecordSkipsEndedWindow(t *testing.T) {
+	fr := newFakeRTLTCP(t)
+	rec := &recorder{Addr: fr.ln.Addr().String(), Dir: t.TempDir(), Frequency: 20100000, SampleRate: 250000, Timeout: 5 * time.Second, Interval: 30 * time.Minute}
+	now := time.Now()
+	fw := &forecastWindow{Start: now.Add(-2 * time.Hour), End: now.Add(-time.Minute), RadioSource: IoA}
+	if err := rec.record(context.Background(), fw); err != nil {
+		t.Fatal(err)
+	}
+	if conns, _ := fr.sent(); conns != 0 {
+		t.Errorf("connected to rtl_tcp %d times for a window that's over", conns)
+	}
+	if files, _ := os.ReadDir(rec.Dir); len(files) != 0 {
+		t.Errorf("wrote %d files for a window that's over", len(files))
+	}
+}
+
+func TestRecordCanceled(t *testing.T) {
+	fr := newFakeRTLTCP(t)
+	rec := &recorder{Addr: fr.ln.Addr().String(), Dir: t.TempDir(), Frequency: 20100000, SampleRate: 250000, Timeout: 5 * time.Second, Interval: 30 * time.Minute}
+	now := time.Now()
+	fw := &forecastWindow{Start: now, End: now.Add(time.Hour), RadioSource: IoC}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := rec.record(ctx, fw); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(now) > 5*time.Second {
+		t.Errorf("recording took %s to stop after being canceled", time.Since(now))
+	}
+	if files, _ := filepath.Glob(filepath.Join(rec.Dir, "io-c-*.cu8")); len(files) != 1 {
+		t.Errorf("got recordings %v", files)
+	}
+}
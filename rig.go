@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultRigFrequency is 20.1 MHz, a quiet spot in the range Jupiter's
+// decameter emissions are usually heard in.
+const defaultRigFrequency = "20.1MHz"
+
+// rigSettings is how to set up the receiver for each window. Antenna is left
+// alone if it's 0, and the preamp if Preamp is negative.
+type rigSettings struct {
+	Frequency       int64
+	SourceFrequency map[radioSource]int64
+	Mode            string
+	Passband        int
+	Antenna         int
+	Preamp          int
+}
+
+func rigCommand(args []string) error {
+	flags := flag.NewFlagSet("rig", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	addr := flags.String("rigctld", "localhost:4532", "Address of the rigctld server to control the receiver with.")
+	freq := flags.String("freq", defaultRigFrequency, "Frequency to tune to at the start of each window, in Hz or with a kHz or MHz suffix.")
+	sourceFreq := flags.String("source-freq", "", "Optional comma separated list of frequencies for particular radio sources, overriding -freq (e.g. 'Io-A=20.1MHz,Io-B=22.2MHz').")
+	mode := flags.String("mode", "AM", "Mode to set at the start of each window, such as AM, USB, or FM.")
+	passband := flags.Int("passband", 0, "Passband in Hz to set with the mode. 0 uses the rig's default for the mode.")
+	antenna := flags.Int("antenna", 0, "Optional antenna to switch to at the start of each window, starting from 1.")
+	preamp := flags.Int("preamp", -1, "Optional preamp level to set at the start of each window (0 turns it off).")
+	timeout := flags.Duration("timeout", 10*time.Second, "How long to wait for rigctld to answer.")
+	test := flags.Bool("test", false, "Tune for the next window right away, then exit, to check the receiver is set up right.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise rig [options]\n\nRuns until stopped, tuning the receiver through rigctld at the start of each forecast window.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("rig doesn't take any arguments.")
+	}
+	if fp.StartTime != "" {
+		return fmt.Errorf("-start-time can't be used with rig, which always starts now.")
+	}
+	if _, err := fp.jupiterData(); err != nil {
+		return err
+	}
+
+	rs := &rigSettings{Mode: strings.ToUpper(*mode), Passband: *passband, Antenna: *antenna, Preamp: *preamp}
+	var err error
+	if rs.Frequency, err = parseFrequency(*freq); err != nil {
+		return err
+	}
+	if rs.SourceFrequency, err = parseSourceFrequencies(*sourceFreq); err != nil {
+		return err
+	}
+	if rs.Passband < 0 {
+		return fmt.Errorf("-passband can't be negative.")
+	}
+	if rs.Antenna < 0 {
+		return fmt.Errorf("-antenna starts from 1, or is 0 to leave the antenna alone.")
+	}
+
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+	ww := &windowWatcher{Params: fp, Leads: []time.Duration{0}, Horizon: 2 * oneDay, Step: oneDay, Calculate: p.forecast}
+
+	if *test {
+		fpNext := *fp
+		fpNext.StartTime = time.Now().Truncate(time.Duration(fp.Interval) * time.Minute).UTC().Format(time.RFC3339)
+		fpNext.Duration = ww.Horizon
+		jData, err := p.forecast(&fpNext)
+		if err != nil {
+			return err
+		}
+		windows := jData.Windows()
+		if len(windows) == 0 {
+			return fmt.Errorf("There aren't any windows in the next %s to tune for.", ww.Horizon)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		return rs.tune(ctx, *addr, *timeout, windows[0])
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("jovian-noise %s tuning through rigctld at %s.", version, *addr)
+	err = ww.Run(ctx, func(ev *windowEvent) error {
+		if ev.Kind != windowOpens {
+			return nil
+		}
+		if err := rs.tune(ctx, *addr, *timeout, ev.Window); err != nil {
+			log.Printf("Error tuning for the %s window: %s", ev.Window.RadioSource, err)
+		}
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Stopping.")
+		return nil
+	}
+	return err
+}
+
+// commands returns the rigctld commands to set up the receiver for a radio
+// source.
+func (rs *rigSettings) commands(src radioSource) []string {
+	freq := rs.Frequency
+	if f, ok := rs.SourceFrequency[src]; ok {
+		freq = f
+	}
+	cmds := []string{
+		fmt.Sprintf("F %d", freq),
+		fmt.Sprintf("M %s %d", rs.Mode, rs.Passband),
+	}
+	if rs.Antenna > 0 {
+		cmds = append(cmds, fmt.Sprintf("Y %d 0", rs.Antenna))
+	}
+	if rs.Preamp >= 0 {
+		cmds = append(cmds, fmt.Sprintf("L PREAMP %d", rs.Preamp))
+	}
+	return cmds
+}
+
+// tune connects to rigctld and sets up the receiver for a window. A new
+// connection is made for each window, since they're hours apart and rigctld
+// may have been restarted in between.
+func (rs *rigSettings) tune(ctx context.Context, addr string, timeout time.Duration, fw *forecastWindow) error {
+	log.Printf("Tuning for the %s window from %s to %s.", fw.RadioSource, fw.Start.UTC().Format(time.RFC3339), fw.End.UTC().Format(time.RFC3339))
+	hc, err := dialHamlib(ctx, addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer hc.Close()
+	for _, cmd := range rs.commands(fw.RadioSource) {
+		if err = hc.set(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseFrequency parses a frequency in Hz, like '20100000', or with a unit,
+// like '20.1MHz' or '20100 kHz'.
+func parseFrequency(s string) (int64, error) {
+	num := strings.ToLower(strings.TrimSpace(s))
+	mult := 1.0
+	for _, u := range []struct {
+		suffix string
+		mult   float64
+	}{{"mhz", 1e6}, {"khz", 1e3}, {"hz", 1}, {"m", 1e6}, {"k", 1e3}} {
+		if strings.HasSuffix(num, u.suffix) {
+			num, mult = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.mult
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("Invalid frequency '%s'.", s)
+	}
+	return int64(math.Round(f * mult)), nil
+}
+
+// parseSourceFrequencies parses a list of frequencies for radio sources, like
+// 'Io-A=20.1MHz,Io-B=22.2MHz'.
+func parseSourceFrequencies(s string) (map[radioSource]int64, error) {
+	freqs := make(map[radioSource]int64)
+	if s == "" {
+		return freqs, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		name, f, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("Invalid source frequency '%s'. It should look like 'Io-A=20.1MHz'.", pair)
+		}
+		sources, err := parseSources(name)
+		if err != nil {
+			return nil, err
+		}
+		if freqs[sources[0]], err = parseFrequency(f); err != nil {
+			return nil, err
+		}
+	}
+	return freqs, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRigCommands(t *testing.T) {
+	tests := []struct {
+		name string
+		rs   *rigSettings
+		src  radioSource
+		want []string
+	}{
+		{
+			name: "frequency and mode only",
+			rs:   &rigSettings{Frequency: 20100000, Mode: "AM", Passband: 6000, Preamp: -1},
+			src:  IoA,
+			want: []string{"F 20100000", "M AM 6000"},
+		},
+		{
+			name: "antenna and preamp",
+			rs:   &rigSettings{Frequency: 20100000, Mode: "USB", Passband: 0, Antenna: 2, Preamp: 10},
+			src:  IoB,
+			want: []string{"F 20100000", "M USB 0", "Y 2 0", "L PREAMP 10"},
+		},
+		{
+			name: "preamp off",
+			rs:   &rigSettings{Frequency: 20100000, Mode: "AM", Passband: 6000, Preamp: 0},
+			src:  IoC,
+			want: []string{"F 20100000", "M AM 6000", "L PREAMP 0"},
+		},
+		{
+			name: "source frequency",
+			rs:   &rigSettings{Frequency: 20100000, SourceFrequency: map[radioSource]int64{IoB: 22200000}, Mode: "AM", Passband: 6000, Preamp: -1},
+			src:  IoB,
+			want: []string{"F 22200000", "M AM 6000"},
+		},
+		{
+			name: "other source's frequency",
+			rs:   &rigSettings{Frequency: 20100000, SourceFrequency: map[radioSource]int64{IoB: 22200000}, Mode: "AM", Passband: 6000, Preamp: -1},
+			src:  IoA,
+			want: []string{"F 20100000", "M AM 6000"},
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.rs.commands(tt.src); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRigTune(t *testing.T) {
+	fh := newFakeHamlib(t, nil)
+	rs := &rigSettings{Frequency: 20100000, SourceFrequency: map[radioSource]int64{IoB: 21900000}, Mode: "AM", Passband: 6000, Antenna: 1, Preamp: 10}
+	if err := rs.tune(context.Background(), fh.addr(), 5*time.Second, testWindowEvent(windowOpens, 0).Window); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"F 21900000", "M AM 6000", "Y 1 0", "L PREAMP 10"}
+	if got := fh.sent(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestRigTuneStopsAtError(t *testing.T) {
+	fh := newFakeHamlib(t, map[string]string{"Y": "RPRT -11"})
+	rs := &rigSettings{Frequency: 20100000, Mode: "AM", Passband: 6000, Antenna: 3, Preamp: 10}
+	err := rs.tune(context.Background(), fh.addr(), 5*time.Second, testWindowEvent(windowOpens, 0).Window)
+	var he *hamlibError
+	if !errors.As(err, &he) || he.Code != -11 || he.Command != "Y 3 0" {
+		t.Fatalf("got %v, want the rig rejecting 'Y 3 0' with RPRT -11", err)
+	}
+	if !strings.HasSuffix(err.Error(), "function not available") {
+		t.Errorf("error %q doesn't say what RPRT -11 means", err)
+	}
+	want := []string{"F 20100000", "M AM 6000", "Y 3 0"}
+	if got := fh.sent(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestRigTuneNoDaemon(t *testing.T) {
+	fh := newFakeHamlib(t, nil)
+	addr := fh.addr()
+	fh.ln.Close()
+	rs := &rigSettings{Frequency: 20100000, Mode: "AM", Passband: 6000, Preamp: -1}
+	if err := rs.tune(context.Background(), addr, time.Second, testWindowEvent(windowOpens, 0).Window); err == nil || !strings.HasPrefix(err.Error(), "Error connecting to") {
+		t.Errorf("got %v, want a connection error", err)
+	}
+}
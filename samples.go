@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xfffe
+)
+
+// sampleFile reads samples from a recording: a WAV file, or raw unsigned 8
+// bit I/Q like the record command writes. Complex files have two channels,
+// I and Q. Start is when the recording started, and Frequency the frequency
+// an I/Q recording is centred on, if they're known.
+type sampleFile struct {
+	Path       string
+	Format     string
+	SampleRate int
+	Channels   int
+	Complex    bool
+	Start      time.Time
+	Frequency  int64
+	// Frames is how many frames (one sample for each channel) the file
+	// has, or -1 if that isn't known.
+	Frames int64
+
+	f       *os.File
+	r       *bufio.Reader
+	bits    int
+	float   bool
+	raw     []byte
+	decoded int64
+}
+
+// openSampleFile opens a recording, working out its format from its
+// extension. Raw I/Q files (.cu8, .iq, .bin, or .raw) get their sample rate
+// and start time from the JSON sidecar the record command writes, if there
+// is one; otherwise sampleRate has to be given. WAV files get their start
+// time from a sidecar too, if they have one.
+func openSampleFile(path string, sampleRate int) (*sampleFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sf := &sampleFile{Path: path, f: f, r: bufio.NewReaderSize(f, 1<<20), Frames: -1}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".wav":
+		sf.Format = "wav"
+		// a WAV file's own header has the right sample rate, but
+		// synthetic ones have their start time in a sidecar
+		if err = sf.readSidecar(); err == nil {
+			err = sf.readWAVHeader()
+		}
+		if err == nil && sf.Frames < 0 {
+			// work out the length of a streamed WAV from what's left
+			// of the file
+			pos, perr := f.Seek(0, io.SeekCurrent)
+			st, serr := f.Stat()
+			if perr == nil && serr == nil {
+				sf.Frames = (st.Size() - pos + int64(sf.r.Buffered())) / int64(sf.Channels*sf.bits/8)
+			}
+		}
+	case ".cu8", ".iq", ".bin", ".raw":
+		sf.Format = "cu8"
+		sf.Channels, sf.Complex, sf.bits = 2, true, 8
+		sf.SampleRate = sampleRate
+		err = sf.readSidecar()
+		if err == nil && sf.SampleRate <= 0 {
+			err = fmt.Errorf("%s has no sidecar to get its sample rate from; give it with -sample-rate.", path)
+		}
+		if st, serr := f.Stat(); err == nil && serr == nil {
+			sf.Frames = st.Size() / 2
+		}
+	default:
+		err = fmt.Errorf("Don't know how to read '%s' files. Recordings should be .wav, or raw unsigned 8 bit I/Q (.cu8).", ext)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return sf, nil
+}
+
+// readSidecar fills in the sample rate, start time, and frequency from the
+// recording's sidecar, if it has one.
+func (sf *sampleFile) readSidecar() error {
+	path := strings.TrimSuffix(sf.Path, filepath.Ext(sf.Path)) + ".json"
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	sc := new(recordingSidecar)
+	if err = json.Unmarshal(data, sc); err != nil {
+		return fmt.Errorf("Error reading the sidecar %s: %s", path, err)
+	}
+	sf.Start = sc.Start
+	sf.Frequency = sc.FrequencyHz
+	if sc.SampleRateHz > 0 {
+		sf.SampleRate = sc.SampleRateHz
+	}
+	return nil
+}
+
+// readWAVHeader reads a WAV file's chunks up to the sample data, checking
+// it's a format we can read: 8, 16, 24, or 32 bit PCM, or 32 or 64 bit
+// floating point.
+func (sf *sampleFile) readWAVHeader() error {
+	var riff [12]byte
+	if _, err := io.ReadFull(sf.r, riff[:]); err != nil {
+		return fmt.Errorf("Error reading %s: %s", sf.Path, err)
+	}
+	if string(riff[:4]) != "RIFF" || string(riff[8:]) != "WAVE" {
+		return fmt.Errorf("%s isn't a WAV file.", sf.Path)
+	}
+
+	var format int
+	for {
+		var ch [8]byte
+		if _, err := io.ReadFull(sf.r, ch[:]); err != nil {
+			return fmt.Errorf("%s has no sample data.", sf.Path)
+		}
+		id, size := string(ch[:4]), int64(binary.LittleEndian.Uint32(ch[4:]))
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return fmt.Errorf("%s has a broken format chunk.", sf.Path)
+			}
+			buf := make([]byte, size+size%2)
+			if _, err := io.ReadFull(sf.r, buf); err != nil {
+				return fmt.Errorf("Error reading %s: %s", sf.Path, err)
+			}
+			format = int(binary.LittleEndian.Uint16(buf[0:]))
+			sf.Channels = int(binary.LittleEndian.Uint16(buf[2:]))
+			sf.SampleRate = int(binary.LittleEndian.Uint32(buf[4:]))
+			sf.bits = int(binary.LittleEndian.Uint16(buf[14:]))
+			if format == wavFormatExtensible && size >= 26 {
+				// the real format is at the start of the sub-format GUID
+				format = int(binary.LittleEndian.Uint16(buf[24:]))
+			}
+		case "data":
+			if format == 0 {
+				return fmt.Errorf("%s has sample data before its format chunk.", sf.Path)
+			}
+			switch {
+			case format == wavFormatPCM && (sf.bits == 8 || sf.bits == 16 || sf.bits == 24 || sf.bits == 32):
+			case format == wavFormatFloat && (sf.bits == 32 || sf.bits == 64):
+				sf.float = true
+			default:
+				return fmt.Errorf("%s is in a WAV format that can't be read (format %d, %d bits). Try 16 bit PCM.", sf.Path, format, sf.bits)
+			}
+			if sf.Channels < 1 || sf.SampleRate < 1 {
+				return fmt.Errorf("%s has a broken format chunk.", sf.Path)
+			}
+			// streaming writers leave the size as 0 or all ones
+			if size > 0 && size != math.MaxUint32 {
+				sf.Frames = size / int64(sf.Channels*sf.bits/8)
+			}
+			return nil
+		default:
+			if _, err := sf.r.Discard(int(size + size%2)); err != nil {
+				return fmt.Errorf("Error reading %s: %s", sf.Path, err)
+			}
+		}
+	}
+}
+
+// writeWAVHeader writes the header of a PCM WAV file with the given number
+// of frames.
+func writeWAVHeader(w io.Writer, sampleRate int, channels int, bits int, frames int64) error {
+	blockAlign := channels * bits / 8
+	size := uint32(frames * int64(blockAlign))
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], 36+size)
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], wavFormatPCM)
+	binary.LittleEndian.PutUint16(h[22:], uint16(channels))
+	binary.LittleEndian.PutUint32(h[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(sampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(h[32:], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(h[34:], uint16(bits))
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], size)
+	_, err := w.Write(h)
+	return err
+}
+
+// Duration returns how long the recording is, or 0 if that isn't known.
+func (sf *sampleFile) Duration() time.Duration {
+	if sf.Frames < 0 {
+		return 0
+	}
+	return time.Duration(float64(sf.Frames) / float64(sf.SampleRate) * float64(time.Second))
+}
+
+// readFrames reads up to len(buf)/Channels frames into buf, with each frame's
+// samples one after another, scaled to between -1 and 1. It returns how many
+// frames it read, and io.EOF at the end of the samples.
+func (sf *sampleFile) readFrames(buf []float64) (int, error) {
+	width := sf.bits / 8
+	frameBytes := width * sf.Channels
+	frames := len(buf) / sf.Channels
+	if sf.Frames >= 0 && int64(frames) > sf.Frames-sf.decoded {
+		frames = int(sf.Frames - sf.decoded)
+	}
+	if frames == 0 {
+		return 0, io.EOF
+	}
+	if cap(sf.raw) < frames*frameBytes {
+		sf.raw = make([]byte, frames*frameBytes)
+	}
+	raw := sf.raw[:frames*frameBytes]
+	n, err := io.ReadFull(sf.r, raw)
+	frames = n / frameBytes
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	if frames == 0 && err == nil {
+		err = io.EOF
+	}
+
+	for i := 0; i < frames*sf.Channels; i++ {
+		b := raw[i*width:]
+		var v float64
+		switch {
+		case sf.bits == 8:
+			// 8 bit samples, WAV or I/Q, are unsigned
+			v = (float64(b[0]) - 127.5) / 127.5
+		case sf.bits == 16:
+			v = float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+		case sf.bits == 24:
+			v = float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / (1 << 23)
+		case sf.bits == 32 && sf.float:
+			v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		case sf.bits == 32:
+			v = float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+		case sf.bits == 64:
+			v = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		}
+		buf[i] = v
+	}
+	sf.decoded += int64(frames)
+	return frames, err
+}
+
+func (sf *sampleFile) Close() error {
+	return sf.f.Close()
+}
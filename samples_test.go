@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testWAV is the parts of a WAV file to write for a test. DataSize is what
+// the data chunk's header says, or the length of Data if it's nil. Extra
+// chunks go between the format and data chunks.
+type testWAV struct {
+	Format     uint16
+	Channels   int
+	SampleRate int
+	Bits       int
+	Extensible bool
+	Extra      [][]byte
+	DataSize   *uint32
+	Data       []byte
+}
+
+func (tw *testWAV) bytes() []byte {
+	var fmtChunk bytes.Buffer
+	format := tw.Format
+	if tw.Extensible {
+		format = wavFormatExtensible
+	}
+	blockAlign := tw.Channels * tw.Bits / 8
+	binary.Write(&fmtChunk, binary.LittleEndian, []uint16{format, uint16(tw.Channels)})
+	binary.Write(&fmtChunk, binary.LittleEndian, []uint32{uint32(tw.SampleRate), uint32(tw.SampleRate * blockAlign)})
+	binary.Write(&fmtChunk, binary.LittleEndian, []uint16{uint16(blockAlign), uint16(tw.Bits)})
+	if tw.Extensible {
+		// cbSize, valid bits, channel mask, and the sub-format GUID
+		binary.Write(&fmtChunk, binary.LittleEndian, []uint16{22, uint16(tw.Bits)})
+		binary.Write(&fmtChunk, binary.LittleEndian, uint32(0))
+		binary.Write(&fmtChunk, binary.LittleEndian, tw.Format)
+		fmtChunk.Write([]byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xaa, 0x00, 0x38, 0x9b, 0x71})
+	}
+
+	var b bytes.Buffer
+	b.WriteString("RIFF\x00\x00\x00\x00WAVE")
+	chunk := func(id string, size uint32, data []byte) {
+		b.WriteString(id)
+		binary.Write(&b, binary.LittleEndian, size)
+		b.Write(data)
+		if len(data)%2 == 1 && id != "data" {
+			b.WriteByte(0)
+		}
+	}
+	chunk("fmt ", uint32(fmtChunk.Len()), fmtChunk.Bytes())
+	for _, e := range tw.Extra {
+		chunk("LIST", uint32(len(e)), e)
+	}
+	size := uint32(len(tw.Data))
+	if tw.DataSize != nil {
+		size = *tw.DataSize
+	}
+	chunk("data", size, tw.Data)
+	binary.LittleEndian.PutUint32(b.Bytes()[4:], uint32(b.Len()-8))
+	return b.Bytes()
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func le16(vals ...int16) []byte {
+	b := make([]byte, 0, 2*len(vals))
+	for _, v := range vals {
+		b = binary.LittleEndian.AppendUint16(b, uint16(v))
+	}
+	return b
+}
+
+func TestReadWAV(t *testing.T) {
+	var zero, allOnes uint32 = 0, math.MaxUint32
+	float32s := binary.LittleEndian.AppendUint32(binary.LittleEndian.AppendUint32(nil, math.Float32bits(-0.25)), math.Float32bits(1))
+	float64s := binary.LittleEndian.AppendUint64(nil, math.Float64bits(0.75))
+	tests := []struct {
+		name     string
+		wav      testWAV
+		channels int
+		frames   int64
+		want     []float64
+	}{
+		{
+			"8 bit PCM",
+			testWAV{Format: wavFormatPCM, Channels: 1, SampleRate: 8000, Bits: 8, Data: []byte{0, 255, 191}},
+			1, 3, []float64{-1, 1, 63.5 / 127.5},
+		},
+		{
+			"16 bit PCM stereo",
+			testWAV{Format: wavFormatPCM, Channels: 2, SampleRate: 44100, Bits: 16, Data: le16(-32768, 16384, 0, -16384)},
+			2, 2, []float64{-1, 0.5, 0, -0.5},
+		},
+		{
+			"24 bit PCM",
+			testWAV{Format: wavFormatPCM, Channels: 1, SampleRate: 48000, Bits: 24, Data: []byte{0x00, 0x00, 0x80, 0x00, 0x00, 0x40, 0xff, 0xff, 0xff}},
+			1, 3, []float64{-1, 0.5, -1.0 / (1 << 23)},
+		},
+		{
+			"32 bit PCM",
+			testWAV{Format: wavFormatPCM, Channels: 1, SampleRate: 48000, Bits: 32, Data: []byte{0x00, 0x00, 0x00, 0x80, 0x00, 0x00, 0x00, 0x40}},
+			1, 2, []float64{-1, 0.5},
+		},
+		{
+			"32 bit float",
+			testWAV{Format: wavFormatFloat, Channels: 2, SampleRate: 48000, Bits: 32, Data: float32s},
+			2, 1, []float64{-0.25, 1},
+		},
+		{
+			"64 bit float",
+			testWAV{Format: wavFormatFloat, Channels: 1, SampleRate: 48000, Bits: 64, Data: float64s},
+			1, 1, []float64{0.75},
+		},
+		{
+			"extensible PCM",
+			testWAV{Format: wavFormatPCM, Extensible: true, Channels: 1, SampleRate: 96000, Bits: 16, Data: le16(16384, -16384)},
+			1, 2, []float64{0.5, -0.5},
+		},
+		{
+			"extensible float",
+			testWAV{Format: wavFormatFloat, Extensible: true, Channels: 2, SampleRate: 96000, Bits: 32, Data: float32s},
+			2, 1, []float64{-0.25, 1},
+		},
+		{
+			"odd sized chunk before the data",
+			testWAV{Format: wavFormatPCM, Channels: 1, SampleRate: 8000, Bits: 16, Extra: [][]byte{[]byte("abc"), []byte("INFO")}, Data: le16(8192)},
+			1, 1, []float64{0.25},
+		},
+		{
+			"streamed, with no data size",
+			testWAV{Format: wavFormatPCM, Channels: 2, SampleRate: 8000, Bits: 16, DataSize: &zero, Data: le16(16384, -16384, 8192, -8192)},
+			2, 2, []float64{0.5, -0.5, 0.25, -0.25},
+		},
+		{
+			"streamed, with the data size all ones",
+			testWAV{Format: wavFormatPCM, Channels: 1, SampleRate: 8000, Bits: 16, DataSize: &allOnes, Data: le16(16384, -16384, 8192)},
+			1, 3, []float64{0.5, -0.5, 0.25},
+		},
+	}
+	for _, tt := range tests {
+		sf, err := openSampleFile(writeTestFile(t, "test.wav", tt.wav.bytes()), 0)
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if sf.Format != "wav" || sf.Channels != tt.channels || sf.SampleRate != tt.wav.SampleRate || sf.Complex || sf.Frames != tt.frames {
+			t.Errorf("%s: read as %s, %d channels at %d Hz, complex %t, %d frames", tt.name, sf.Format, sf.Channels, sf.SampleRate, sf.Complex, sf.Frames)
+		}
+
+		// read a frame at a time, to check reads pick up where the last
+		// one stopped
+		var got []float64
+		buf := make([]float64, sf.Channels)
+		for {
+			n, err := sf.readFrames(buf)
+			got = append(got, buf[:n*sf.Channels]...)
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				t.Errorf("%s: %s", tt.name, err)
+				break
+			}
+		}
+		sf.Close()
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: read %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !closeTo(got[i], tt.want[i]) {
+				t.Errorf("%s: read %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestReadWAVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		msg  string
+	}{
+		{"not a WAV file", []byte("ID3\x04\x00\x00\x00\x00\x00\x00\x00\x00"), "isn't a WAV file"},
+		{"too short", []byte("RIFF"), "Error reading"},
+		{"12 bit PCM", (&testWAV{Format: wavFormatPCM, Channels: 1, SampleRate: 8000, Bits: 12, Data: []byte{0, 0}}).bytes(), "(format 1, 12 bits)"},
+		{"A-law", (&testWAV{Format: 6, Channels: 1, SampleRate: 8000, Bits: 8, Data: []byte{0}}).bytes(), "(format 6, 8 bits)"},
+		{"16 bit float", (&testWAV{Format: wavFormatFloat, Channels: 1, SampleRate: 8000, Bits: 16, Data: []byte{0, 0}}).bytes(), "(format 3, 16 bits)"},
+		{"no channels", (&testWAV{Format: wavFormatPCM, Channels: 0, SampleRate: 8000, Bits: 16}).bytes(), "broken format chunk"},
+		{"data before format", []byte("RIFF\x0c\x00\x00\x00WAVEdata\x00\x00\x00\x00"), "sample data before its format chunk"},
+		{"no data", []byte("RIFF\x04\x00\x00\x00WAVE"), "has no sample data"},
+	}
+	for _, tt := range tests {
+		_, err := openSampleFile(writeTestFile(t, "bad.wav", tt.data), 0)
+		if err == nil || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: got error %v, want one about %q", tt.name, err, tt.msg)
+		}
+	}
+}
+
+func TestWriteWAVHeader(t *testing.T) {
+	var b bytes.Buffer
+	if err := writeWAVHeader(&b, 22050, 2, 16, 3); err != nil {
+		t.Fatal(err)
+	}
+	b.Write(le16(16384, -16384, 0, 0, -32768, 8192))
+	sf, err := openSampleFile(writeTestFile(t, "header.wav", b.Bytes()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close()
+	if sf.SampleRate != 22050 || sf.Channels != 2 || sf.Frames != 3 {
+		t.Errorf("read back as %d channels at %d Hz, %d frames", sf.Channels, sf.SampleRate, sf.Frames)
+	}
+}
+
+func TestReadCU8(t *testing.T) {
+	path := writeTestFile(t, "iq.cu8", []byte{0, 255, 255, 0, 128, 127})
+	if _, err := openSampleFile(path, 0); err == nil || !strings.Contains(err.Error(), "-sample-rate") {
+		t.Errorf("without a sidecar or sample rate, got error %v", err)
+	}
+	sf, err := openSampleFile(path, 250000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close()
+	if sf.Format != "cu8" || !sf.Complex || sf.Channels != 2 || sf.SampleRate != 250000 || sf.Frames != 3 {
+		t.Errorf("read as %s, %d channels at %d Hz, complex %t, %d frames", sf.Format, sf.Channels, sf.SampleRate, sf.Complex, sf.Frames)
+	}
+	buf := make([]float64, 8)
+	n, err := sf.readFrames(buf)
+	if n != 3 || err != nil {
+		t.Fatalf("read %d frames, %v", n, err)
+	}
+	want := []float64{-1, 1, 1, -1, 0.5 / 127.5, -0.5 / 127.5}
+	for i, w := range want {
+		if !closeTo(buf[i], w) {
+			t.Errorf("read %v, want %v", buf[:6], want)
+			break
+		}
+	}
+	if n, err = sf.readFrames(buf); n != 0 || !errors.Is(err, io.EOF) {
+		t.Errorf("at the end, read %d frames, %v", n, err)
+	}
+}
@@ -0,0 +1,561 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/soniakeys/meeus/v3/globe"
+	"github.com/soniakeys/unit"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The JSON forecast format is versioned, so downstream consumers can tell
+// what they're getting. Bump jsonSchemaVersion (and update jsonSchema below)
+// whenever the format changes in a way that isn't backwards compatible.
+const jsonSchemaVersion int = 1
+
+const ephemerisSource string = "VSOP87B"
+
+// The types below are the JSON forecast format. Angles are in degrees,
+// durations are ISO 8601 durations, and longitudes are positive east of
+// Greenwich, whatever the forecast uses internally.
+
+type jsonForecast struct {
+	SchemaVersion    int                    `json:"schema_version"`
+	Provenance       *jsonProvenance        `json:"provenance"`
+	JupiterPositions []*jsonJupiterPosition `json:"jupiter_positions,omitempty"`
+	Intervals        []*forecastInterval    `json:"intervals"`
+	Windows          []*jsonWindow          `json:"windows"`
+}
+
+type jsonProvenance struct {
+	Program     string          `json:"program"`
+	Version     string          `json:"version"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Ephemeris   string          `json:"ephemeris"`
+	Parameters  *jsonParameters `json:"parameters"`
+}
+
+type jsonParameters struct {
+	StartTime time.Time     `json:"start_time"`
+	EndTime   time.Time     `json:"end_time"`
+	Duration  string        `json:"duration"`
+	Interval  string        `json:"interval"`
+	Sources   []radioSource `json:"sources"`
+	Observer  *jsonObserver `json:"observer,omitempty"`
+	TimeZone  *jsonTimeZone `json:"time_zone,omitempty"`
+}
+
+type jsonObserver struct {
+	LatitudeDeg  float64 `json:"latitude_deg"`
+	LongitudeDeg float64 `json:"longitude_deg"`
+}
+
+// jsonTimeZone is how a forecast's time zone is saved, since *time.Location
+// doesn't round trip by itself. Zones that can't be loaded by name (like the
+// -offset-hours zone) are recreated from the offset.
+type jsonTimeZone struct {
+	Name             string `json:"name"`
+	UTCOffsetSeconds int    `json:"utc_offset_seconds"`
+}
+
+type jsonJupiterPosition struct {
+	Date             string    `json:"date"`
+	Rising           time.Time `json:"rising"`
+	Transit          time.Time `json:"transit"`
+	Set              time.Time `json:"set"`
+	RightAscensionHr float64   `json:"right_ascension_hours"`
+	DeclinationDeg   float64   `json:"declination_deg"`
+}
+
+type jsonInterval struct {
+	Instant          time.Time   `json:"instant"`
+	RadioSource      radioSource `json:"radio_source"`
+	CMLDeg           float64     `json:"cml_deg"`
+	IoPhaseDeg       float64     `json:"io_phase_deg"`
+	DistanceAU       float64     `json:"distance_au"`
+	TransitHourAngle *float64    `json:"transit_hour_angle_hours,omitempty"`
+	AltitudeDeg      *float64    `json:"altitude_deg,omitempty"`
+	AzimuthDeg       *float64    `json:"azimuth_deg,omitempty"`
+	Recommended      bool        `json:"recommended"`
+}
+
+type jsonWindow struct {
+	RadioSource     radioSource `json:"radio_source"`
+	Start           time.Time   `json:"start"`
+	End             time.Time   `json:"end"`
+	Duration        string      `json:"duration"`
+	Recommended     bool        `json:"recommended"`
+	PeakAltitudeDeg *float64    `json:"peak_altitude_deg,omitempty"`
+}
+
+func (jd *jupiterData) MarshalJSON() ([]byte, error) {
+	prov := jd.Provenance
+	if prov == nil {
+		prov = newProvenance()
+	}
+	params := &jsonParameters{
+		StartTime: jd.StartTime,
+		EndTime:   jd.EndTime,
+		Duration:  isoDuration(jd.Duration),
+		Interval:  isoDuration(time.Duration(jd.Interval) * time.Minute),
+		Sources:   jd.Sources,
+	}
+	if params.Sources == nil {
+		params.Sources = []radioSource{}
+	}
+	if jd.LocalForecast {
+		lat, lon := coordsToDeg(jd.Coords)
+		params.Observer = &jsonObserver{LatitudeDeg: lat, LongitudeDeg: lon}
+	}
+	if jd.Location != nil {
+		_, offset := jd.StartTime.In(jd.Location).Zone()
+		params.TimeZone = &jsonTimeZone{Name: zoneName(jd.Location, jd.StartTime), UTCOffsetSeconds: offset}
+	}
+
+	jf := &jsonForecast{
+		SchemaVersion: jsonSchemaVersion,
+		Provenance: &jsonProvenance{
+			Program:     prov.Program,
+			Version:     prov.Version,
+			GeneratedAt: prov.GeneratedAt,
+			Ephemeris:   prov.Ephemeris,
+			Parameters:  params,
+		},
+		Intervals: jd.Intervals,
+		Windows:   make([]*jsonWindow, 0),
+	}
+	if jf.Intervals == nil {
+		jf.Intervals = []*forecastInterval{}
+	}
+
+	keys := make([]string, 0, len(jd.JupiterPositions))
+	for k := range jd.JupiterPositions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		jp := jd.JupiterPositions[k]
+		jf.JupiterPositions = append(jf.JupiterPositions, &jsonJupiterPosition{
+			Date:             k,
+			Rising:           jp.EntryDate.Add(unitTimeDuration(jp.Rising)),
+			Transit:          jp.EntryDate.Add(unitTimeDuration(jp.Transit)),
+			Set:              jp.EntryDate.Add(unitTimeDuration(jp.Set)),
+			RightAscensionHr: jp.RA.Hour(),
+			DeclinationDeg:   jp.Dec.Deg(),
+		})
+	}
+
+	for _, fw := range jd.Windows() {
+		jf.Windows = append(jf.Windows, newJSONWindow(fw))
+	}
+
+	return json.Marshal(jf)
+}
+
+func newJSONWindow(fw *forecastWindow) *jsonWindow {
+	jw := &jsonWindow{
+		RadioSource: fw.RadioSource,
+		Start:       fw.Start,
+		End:         fw.End,
+		Duration:    isoDuration(fw.Duration()),
+		Recommended: fw.Recommended(),
+	}
+	if peak, ok := fw.PeakAltitude(); ok {
+		deg := peak.Deg()
+		jw.PeakAltitudeDeg = &deg
+	}
+	return jw
+}
+
+func (jd *jupiterData) UnmarshalJSON(data []byte) error {
+	jf := new(jsonForecast)
+	if err := json.Unmarshal(data, jf); err != nil {
+		return err
+	}
+	if jf.SchemaVersion != jsonSchemaVersion {
+		return fmt.Errorf("Unsupported forecast schema_version %d; this version of jovian-noise reads schema_version %d.", jf.SchemaVersion, jsonSchemaVersion)
+	}
+	if jf.Provenance == nil || jf.Provenance.Parameters == nil {
+		return fmt.Errorf("The forecast is missing its provenance and parameters.")
+	}
+	params := jf.Provenance.Parameters
+
+	dur, err := parseISODuration(params.Duration)
+	if err != nil {
+		return err
+	}
+	interval, err := parseISODuration(params.Interval)
+	if err != nil {
+		return err
+	}
+
+	*jd = jupiterData{
+		StartTime: params.StartTime,
+		EndTime:   params.EndTime,
+		Duration:  dur,
+		Interval:  int(interval / time.Minute),
+		Sources:   params.Sources,
+		Intervals: jf.Intervals,
+		Provenance: &forecastProvenance{
+			Program:     jf.Provenance.Program,
+			Version:     jf.Provenance.Version,
+			GeneratedAt: jf.Provenance.GeneratedAt,
+			Ephemeris:   jf.Provenance.Ephemeris,
+		},
+	}
+	if jd.Intervals == nil {
+		jd.Intervals = make([]*forecastInterval, 0)
+	}
+	if params.Observer != nil {
+		jd.Coords = coordsFromDeg(params.Observer.LatitudeDeg, params.Observer.LongitudeDeg)
+		jd.LocalForecast = true
+	}
+	if params.TimeZone != nil {
+		// "Local" would be wherever the forecast is loaded, not where
+		// it was made.
+		if loc, err := time.LoadLocation(params.TimeZone.Name); err == nil && params.TimeZone.Name != "Local" {
+			jd.Location = loc
+		} else {
+			jd.Location = time.FixedZone(params.TimeZone.Name, params.TimeZone.UTCOffsetSeconds)
+		}
+	}
+	if len(jf.JupiterPositions) > 0 {
+		jd.JupiterPositions = make(map[string]*jupiterPosition, len(jf.JupiterPositions))
+		for _, jjp := range jf.JupiterPositions {
+			entry, err := time.Parse(jpFormat, jjp.Date)
+			if err != nil {
+				return err
+			}
+			jd.JupiterPositions[jjp.Date] = &jupiterPosition{
+				EntryDate: entry,
+				Rising:    durationUnitTime(jjp.Rising.Sub(entry)),
+				Transit:   durationUnitTime(jjp.Transit.Sub(entry)),
+				Set:       durationUnitTime(jjp.Set.Sub(entry)),
+				RA:        unit.RAFromHour(jjp.RightAscensionHr),
+				Dec:       unit.AngleFromDeg(jjp.DeclinationDeg),
+			}
+		}
+	}
+
+	return nil
+}
+
+func (fi *forecastInterval) MarshalJSON() ([]byte, error) {
+	ji := &jsonInterval{
+		Instant:     fi.Instant,
+		RadioSource: fi.RadioSource,
+		CMLDeg:      fi.Meridian.Deg(),
+		IoPhaseDeg:  fi.IoPhase.Deg(),
+		DistanceAU:  fi.Distance,
+		Recommended: fi.Recommended(),
+	}
+	if fi.AltAz != nil {
+		ha := fi.TransitHA.Hour()
+		alt := fi.AltAz.Altitude.Deg()
+		az := fi.AltAz.Azimuth.Deg()
+		ji.TransitHourAngle = &ha
+		ji.AltitudeDeg = &alt
+		ji.AzimuthDeg = &az
+	}
+	return json.Marshal(ji)
+}
+
+func (fi *forecastInterval) UnmarshalJSON(data []byte) error {
+	ji := new(jsonInterval)
+	if err := json.Unmarshal(data, ji); err != nil {
+		return err
+	}
+	if ji.RadioSource == NoEvent {
+		return fmt.Errorf("The forecast interval at %s has no radio source.", ji.Instant)
+	}
+	*fi = forecastInterval{
+		Instant:     ji.Instant,
+		IoPhase:     unit.AngleFromDeg(ji.IoPhaseDeg),
+		Meridian:    unit.AngleFromDeg(ji.CMLDeg),
+		Distance:    ji.DistanceAU,
+		RadioSource: ji.RadioSource,
+	}
+	if ji.AltitudeDeg != nil && ji.AzimuthDeg != nil {
+		fi.AltAz = &hzCoords{Altitude: unit.AngleFromDeg(*ji.AltitudeDeg), Azimuth: unit.AngleFromDeg(*ji.AzimuthDeg)}
+	}
+	if ji.TransitHourAngle != nil {
+		fi.TransitHA = unit.HourAngleFromHour(*ji.TransitHourAngle)
+	}
+	return nil
+}
+
+// coordsToDeg returns the latitude and longitude of an observer in degrees,
+// with longitude positive east of Greenwich.
+func coordsToDeg(c globe.Coord) (float64, float64) {
+	lon := -c.Lon.Deg()
+	if lon < -180 {
+		lon += 360
+	}
+	return c.Lat.Deg(), lon
+}
+
+// coordsFromDeg goes the other way, from degrees with longitude positive east
+// of Greenwich to the west positive longitude the meeus library uses.
+func coordsFromDeg(lat float64, lon float64) globe.Coord {
+	lon = -lon
+	if lon < 0 {
+		lon += 360
+	}
+	return globe.Coord{Lat: unit.AngleFromDeg(lat), Lon: unit.AngleFromDeg(lon)}
+}
+
+// zoneName returns the name to save a time zone under. time.Local is only
+// called "Local", so it's saved under the zone name from $TZ or
+// /etc/localtime if there is one, or else its abbreviation at t.
+func zoneName(loc *time.Location, t time.Time) string {
+	if loc != time.Local {
+		return loc.String()
+	}
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		tz = strings.TrimPrefix(tz, ":")
+		if tz == "" {
+			return "UTC"
+		}
+		if _, err := time.LoadLocation(tz); err == nil {
+			return tz
+		}
+	} else if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if i := strings.LastIndex(target, "zoneinfo/"); i >= 0 {
+			name := target[i+len("zoneinfo/"):]
+			if _, err := time.LoadLocation(name); err == nil {
+				return name
+			}
+		}
+	}
+	name, _ := t.In(loc).Zone()
+	return name
+}
+
+func unitTimeDuration(t unit.Time) time.Duration {
+	return time.Duration(float64(t) * float64(time.Second))
+}
+
+func durationUnitTime(d time.Duration) unit.Time {
+	return unit.Time(d.Seconds())
+}
+
+// isoDuration formats a duration as an ISO 8601 duration, in hours, minutes,
+// and seconds (e.g. "PT720H" or "PT1H30M").
+func isoDuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	if d < 0 {
+		b.WriteString("-")
+		d = -d
+	}
+	b.WriteString("PT")
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	if h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if d > 0 {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64))
+		b.WriteString("S")
+	}
+	return b.String()
+}
+
+// parseISODuration parses ISO 8601 durations with weeks, days, hours,
+// minutes, and seconds. Years and months aren't a fixed length, so they're
+// not accepted.
+func parseISODuration(s string) (time.Duration, error) {
+	orig := s
+	var neg bool
+	if strings.HasPrefix(s, "-") {
+		neg = true
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 2 {
+		return 0, fmt.Errorf("'%s' is not an ISO 8601 duration.", orig)
+	}
+	s = s[1:]
+
+	var d float64
+	var inTime bool
+	// a T has to be followed by at least one hours, minutes, or seconds
+	// part
+	var timeParts int
+	for len(s) > 0 {
+		if s[0] == 'T' {
+			if inTime {
+				return 0, fmt.Errorf("'%s' is not an ISO 8601 duration.", orig)
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		i := strings.IndexAny(s, "WDHMS")
+		if i < 1 {
+			return 0, fmt.Errorf("'%s' is not an ISO 8601 duration (or uses years or months, which aren't supported).", orig)
+		}
+		n, err := strconv.ParseFloat(strings.Replace(s[:i], ",", ".", 1), 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("'%s' is not an ISO 8601 duration.", orig)
+		}
+		var u time.Duration
+		switch {
+		case s[i] == 'W' && !inTime:
+			u = 7 * oneDay
+		case s[i] == 'D' && !inTime:
+			u = oneDay
+		case s[i] == 'H' && inTime:
+			u = time.Hour
+		case s[i] == 'M' && inTime:
+			u = time.Minute
+		case s[i] == 'S' && inTime:
+			u = time.Second
+		default:
+			return 0, fmt.Errorf("'%s' is not an ISO 8601 duration (or uses years or months, which aren't supported).", orig)
+		}
+		if inTime {
+			timeParts++
+		}
+		d += n * float64(u)
+		s = s[i+1:]
+	}
+	if inTime && timeParts == 0 {
+		return 0, fmt.Errorf("'%s' is not an ISO 8601 duration.", orig)
+	}
+	if d > math.MaxInt64 {
+		return 0, fmt.Errorf("'%s' is too long.", orig)
+	}
+	if neg {
+		d = -d
+	}
+	return time.Duration(math.Round(d)), nil
+}
+
+// jsonSchema is the JSON Schema for the JSON forecast format, printed by
+// -json-schema.
+var jsonSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"$id": "https://github.com/ctdk/jovian-noise/schema/forecast-v1.json",
+	"title": "Jovian decameter radio storm forecast",
+	"description": "A forecast produced by jovian-noise with '-output json'. Angles are in degrees, durations are ISO 8601 durations, times are RFC 3339 timestamps, and longitudes are positive east of Greenwich.",
+	"type": "object",
+	"required": ["schema_version", "provenance", "intervals", "windows"],
+	"properties": {
+		"schema_version": {
+			"description": "The version of this schema the forecast follows.",
+			"const": 1
+		},
+		"provenance": {
+			"type": "object",
+			"required": ["program", "version", "generated_at", "ephemeris", "parameters"],
+			"properties": {
+				"program": { "type": "string" },
+				"version": { "description": "Version of the program that calculated the forecast.", "type": "string" },
+				"generated_at": { "type": "string", "format": "date-time" },
+				"ephemeris": { "description": "Source of the planetary positions.", "type": "string" },
+				"parameters": {
+					"type": "object",
+					"required": ["start_time", "end_time", "duration", "interval", "sources"],
+					"properties": {
+						"start_time": { "type": "string", "format": "date-time" },
+						"end_time": { "type": "string", "format": "date-time" },
+						"duration": { "$ref": "#/$defs/duration" },
+						"interval": { "$ref": "#/$defs/duration" },
+						"sources": {
+							"description": "The radio sources the forecast includes.",
+							"type": "array",
+							"items": { "$ref": "#/$defs/radio_source" }
+						},
+						"observer": {
+							"description": "Present if the forecast is limited to when Jupiter is above the horizon for an observer.",
+							"type": "object",
+							"required": ["latitude_deg", "longitude_deg"],
+							"properties": {
+								"latitude_deg": { "type": "number", "minimum": -90, "maximum": 90 },
+								"longitude_deg": { "type": "number", "minimum": -180, "maximum": 180 }
+							}
+						},
+						"time_zone": {
+							"description": "The time zone for displaying results, if one was given.",
+							"type": "object",
+							"required": ["name", "utc_offset_seconds"],
+							"properties": {
+								"name": { "type": "string" },
+								"utc_offset_seconds": { "description": "Offset from UTC at the start of the forecast.", "type": "integer" }
+							}
+						}
+					}
+				}
+			}
+		},
+		"jupiter_positions": {
+			"description": "Jupiter's daily rising, transit, and setting times for the observer. Only present if there is an observer.",
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["date", "rising", "transit", "set", "right_ascension_hours", "declination_deg"],
+				"properties": {
+					"date": { "type": "string", "format": "date" },
+					"rising": { "type": "string", "format": "date-time" },
+					"transit": { "type": "string", "format": "date-time" },
+					"set": { "type": "string", "format": "date-time" },
+					"right_ascension_hours": { "type": "number", "minimum": 0, "maximum": 24 },
+					"declination_deg": { "type": "number", "minimum": -90, "maximum": 90 }
+				}
+			}
+		},
+		"intervals": {
+			"description": "Each forecast sample where a radio source may be active.",
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["instant", "radio_source", "cml_deg", "io_phase_deg", "distance_au", "recommended"],
+				"properties": {
+					"instant": { "type": "string", "format": "date-time" },
+					"radio_source": { "$ref": "#/$defs/radio_source" },
+					"cml_deg": { "description": "Jupiter's System III central meridian longitude.", "$ref": "#/$defs/angle" },
+					"io_phase_deg": { "$ref": "#/$defs/angle" },
+					"distance_au": { "description": "Distance from Earth to Jupiter.", "type": "number", "exclusiveMinimum": 0 },
+					"transit_hour_angle_hours": { "description": "Hours from Jupiter's transit. Only present if there is an observer.", "type": "number" },
+					"altitude_deg": { "description": "Only present if there is an observer.", "type": "number", "minimum": -90, "maximum": 90 },
+					"azimuth_deg": { "description": "Only present if there is an observer.", "type": "number" },
+					"recommended": { "type": "boolean" }
+				}
+			}
+		},
+		"windows": {
+			"description": "Consecutive intervals with the same radio source, merged together.",
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["radio_source", "start", "end", "duration", "recommended"],
+				"properties": {
+					"radio_source": { "$ref": "#/$defs/radio_source" },
+					"start": { "type": "string", "format": "date-time" },
+					"end": { "type": "string", "format": "date-time" },
+					"duration": { "$ref": "#/$defs/duration" },
+					"recommended": { "type": "boolean" },
+					"peak_altitude_deg": { "description": "Only present if there is an observer.", "type": "number", "minimum": -90, "maximum": 90 }
+				}
+			}
+		}
+	},
+	"$defs": {
+		"angle": { "type": "number", "minimum": 0, "exclusiveMaximum": 360 },
+		"duration": { "type": "string", "pattern": "^-?P(\\d+W)?(\\d+D)?(T(\\d+H)?(\\d+M)?(\\d+(\\.\\d+)?S)?)?$" },
+		"radio_source": { "enum": ["Io-A", "Io-B", "Io-C", "non-Io-A"] }
+	}
+}
+`
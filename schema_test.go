@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/soniakeys/unit"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestISODurationRoundTrip(t *testing.T) {
+	tests := []struct {
+		d   time.Duration
+		iso string
+	}{
+		{0, "PT0S"},
+		{30 * time.Minute, "PT30M"},
+		{90 * time.Minute, "PT1H30M"},
+		{720 * time.Hour, "PT720H"},
+		{time.Hour + 5*time.Second, "PT1H5S"},
+		{1500 * time.Millisecond, "PT1.5S"},
+		{-2 * time.Hour, "-PT2H"},
+	}
+	for _, tt := range tests {
+		if got := isoDuration(tt.d); got != tt.iso {
+			t.Errorf("isoDuration(%s) = %q, want %q", tt.d, got, tt.iso)
+		}
+		got, err := parseISODuration(tt.iso)
+		if err != nil {
+			t.Errorf("parseISODuration(%q) returned an error: %s", tt.iso, err)
+			continue
+		}
+		if got != tt.d {
+			t.Errorf("parseISODuration(%q) = %s, want %s", tt.iso, got, tt.d)
+		}
+	}
+}
+
+func TestParseISODuration(t *testing.T) {
+	tests := []struct {
+		iso  string
+		want time.Duration
+	}{
+		{"P7D", 7 * oneDay},
+		{"P1W", 7 * oneDay},
+		{"P1DT12H", 36 * time.Hour},
+		{"PT0.5H", 30 * time.Minute},
+		{"PT1,5M", 90 * time.Second},
+		{"P0D", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseISODuration(tt.iso)
+		if err != nil {
+			t.Errorf("parseISODuration(%q) returned an error: %s", tt.iso, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseISODuration(%q) = %s, want %s", tt.iso, got, tt.want)
+		}
+	}
+}
+
+func TestParseISODurationRejects(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"P",
+		"PT",
+		"7D",
+		"720h",
+		"P1Y",
+		"P2M",
+		"PT1D",
+		"P1H",
+		"PTT1H",
+		"P1DT",
+		"PT-1H",
+		"PTxH",
+		"PT1H30",
+		"P99999999999999999999D",
+	} {
+		if d, err := parseISODuration(s); err == nil {
+			t.Errorf("parseISODuration(%q) = %s, want an error", s, d)
+		}
+	}
+}
+
+func testRoundTripForecast(loc *time.Location) *jupiterData {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	entry := start.Truncate(oneDay)
+	jData := &jupiterData{
+		StartTime:     start,
+		EndTime:       start.Add(2 * time.Hour),
+		Duration:      2 * time.Hour,
+		Interval:      30,
+		Coords:        coordsFromDeg(40, -105),
+		LocalForecast: true,
+		Sources:       []radioSource{IoA, IoB},
+		Location:      loc,
+		JupiterPositions: map[string]*jupiterPosition{
+			entry.Format(jpFormat): {
+				EntryDate: entry,
+				Rising:    unit.Time(15 * 3600),
+				Transit:   unit.Time(22*3600 + 30*60),
+				Set:       unit.Time(30 * 3600),
+				RA:        unit.RAFromHour(3.5),
+				Dec:       unit.AngleFromDeg(18.25),
+			},
+		},
+		Provenance: &forecastProvenance{Program: "jovian-noise", Version: "test", GeneratedAt: start.Add(-time.Hour), Ephemeris: ephemerisSource},
+	}
+	for i := 0; i < 4; i++ {
+		rs := IoB
+		if i == 3 {
+			rs = IoA
+		}
+		jData.Intervals = append(jData.Intervals, &forecastInterval{
+			Instant:     start.Add(time.Duration(i) * 30 * time.Minute),
+			IoPhase:     unit.AngleFromDeg(85 + float64(i)*4),
+			Meridian:    unit.AngleFromDeg(120 + float64(i)*18),
+			Distance:    4.75,
+			RadioSource: rs,
+			TransitHA:   unit.HourAngleFromHour(-2 + float64(i)/2),
+			AltAz:       &hzCoords{Altitude: unit.AngleFromDeg(30 + float64(i)), Azimuth: unit.AngleFromDeg(150 + float64(i)*5)},
+		})
+	}
+	return jData
+}
+
+func TestJupiterDataJSONRoundTrip(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		loc  *time.Location
+	}{
+		{"no time zone", nil},
+		{"named zone", denver},
+		{"offset", time.FixedZone("Manual Offset Zone", 9*3600+1800)},
+		{"local", time.Local},
+	}
+	for _, tt := range tests {
+		jData := testRoundTripForecast(tt.loc)
+		j, err := json.Marshal(jData)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		got := new(jupiterData)
+		if err := json.Unmarshal(j, got); err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+
+		if !got.StartTime.Equal(jData.StartTime) || !got.EndTime.Equal(jData.EndTime) || got.Duration != jData.Duration || got.Interval != jData.Interval {
+			t.Errorf("%s: period is %s to %s (%s, %d minutes), want %s to %s (%s, %d minutes)", tt.name, got.StartTime, got.EndTime, got.Duration, got.Interval, jData.StartTime, jData.EndTime, jData.Duration, jData.Interval)
+		}
+		if lat, lon := coordsToDeg(got.Coords); !got.LocalForecast || !closeTo(lat, 40) || !closeTo(lon, -105) {
+			t.Errorf("%s: observer is %g, %g (local %t), want 40, -105", tt.name, lat, lon, got.LocalForecast)
+		}
+		if len(got.Sources) != 2 || got.Sources[0] != IoA || got.Sources[1] != IoB {
+			t.Errorf("%s: sources are %v", tt.name, got.Sources)
+		}
+		if *got.Provenance != *jData.Provenance {
+			t.Errorf("%s: provenance is %+v, want %+v", tt.name, got.Provenance, jData.Provenance)
+		}
+
+		switch {
+		case tt.loc == nil:
+			if got.Location != nil {
+				t.Errorf("%s: location is %s, want none", tt.name, got.Location)
+			}
+		case got.Location == nil:
+			t.Errorf("%s: the location was lost", tt.name)
+		case got.Location == time.Local || got.Location.String() == "Local":
+			t.Errorf("%s: the location was saved as Local", tt.name)
+		default:
+			for _, when := range []time.Time{jData.StartTime, jData.StartTime.AddDate(0, 0, 14)} {
+				gotName, gotOffset := when.In(got.Location).Zone()
+				wantName, wantOffset := when.In(tt.loc).Zone()
+				if gotOffset != wantOffset || gotName != wantName {
+					t.Errorf("%s: zone at %s is %s %d, want %s %d", tt.name, when, gotName, gotOffset, wantName, wantOffset)
+				}
+			}
+		}
+
+		if len(got.Intervals) != len(jData.Intervals) {
+			t.Fatalf("%s: got %d intervals, want %d", tt.name, len(got.Intervals), len(jData.Intervals))
+		}
+		for i, fi := range got.Intervals {
+			want := jData.Intervals[i]
+			if !fi.Instant.Equal(want.Instant) || fi.RadioSource != want.RadioSource || fi.Distance != want.Distance ||
+				!closeTo(fi.Meridian.Deg(), want.Meridian.Deg()) || !closeTo(fi.IoPhase.Deg(), want.IoPhase.Deg()) ||
+				!closeTo(fi.TransitHA.Hour(), want.TransitHA.Hour()) || fi.AltAz == nil ||
+				!closeTo(fi.AltAz.Altitude.Deg(), want.AltAz.Altitude.Deg()) || !closeTo(fi.AltAz.Azimuth.Deg(), want.AltAz.Azimuth.Deg()) {
+				t.Errorf("%s: interval %d is %+v, want %+v", tt.name, i, fi, want)
+			}
+		}
+		for k, want := range jData.JupiterPositions {
+			jp, ok := got.JupiterPositions[k]
+			if !ok {
+				t.Errorf("%s: no Jupiter position for %s", tt.name, k)
+				continue
+			}
+			if !jp.EntryDate.Equal(want.EntryDate) || !closeTo(float64(jp.Rising), float64(want.Rising)) || !closeTo(float64(jp.Transit), float64(want.Transit)) ||
+				!closeTo(float64(jp.Set), float64(want.Set)) || !closeTo(jp.RA.Hour(), want.RA.Hour()) || !closeTo(jp.Dec.Deg(), want.Dec.Deg()) {
+				t.Errorf("%s: Jupiter position for %s is %+v, want %+v", tt.name, k, jp, want)
+			}
+		}
+
+		// and it should save the same way again
+		again, err := json.Marshal(got)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if !bytes.Equal(again, j) {
+			t.Errorf("%s: saving the loaded forecast again gives\n%s\nnot\n%s", tt.name, again, j)
+		}
+	}
+}
+
+func closeTo(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// schemaProblems checks a decoded JSON value against the part of the JSON
+// Schema that jsonSchema uses, and returns what doesn't match. Properties
+// the schema doesn't declare are reported too, so the schema can't fall
+// behind the output.
+func schemaProblems(path string, schema map[string]interface{}, defs map[string]interface{}, v interface{}) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		return schemaProblems(path, defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{}), defs, v)
+	}
+	var problems []string
+	bad := func(format string, a ...interface{}) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, a...))
+	}
+	if c, ok := schema["const"]; ok && fmt.Sprint(c) != fmt.Sprint(v) {
+		bad("is %v, want %v", v, c)
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			bad("%v isn't one of %v", v, enum)
+		}
+	}
+
+	switch want := schema["type"]; want {
+	case nil:
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			bad("is %T, want an object", v)
+			break
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		if req, ok := schema["required"].([]interface{}); ok {
+			for _, r := range req {
+				if _, ok := obj[r.(string)]; !ok {
+					bad("is missing %s", r)
+				}
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ps, ok := props[k].(map[string]interface{})
+			if !ok {
+				bad("has %s, which isn't in the schema", k)
+				continue
+			}
+			problems = append(problems, schemaProblems(path+"."+k, ps, defs, obj[k])...)
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			bad("is %T, want an array", v)
+			break
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range arr {
+				problems = append(problems, schemaProblems(fmt.Sprintf("%s[%d]", path, i), items, defs, item)...)
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			bad("is %T, want a string", v)
+			break
+		}
+		if pat, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pat).MatchString(str) {
+			bad("%q doesn't match %s", str, pat)
+		}
+		switch schema["format"] {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				bad("%q isn't a date-time", str)
+			}
+		case "date":
+			if _, err := time.Parse("2006-01-02", str); err != nil {
+				bad("%q isn't a date", str)
+			}
+		}
+	case "number", "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			bad("is %T, want a %s", v, want)
+			break
+		}
+		f, err := n.Float64()
+		if err != nil {
+			bad("%s isn't a number", n)
+			break
+		}
+		if _, err := n.Int64(); want == "integer" && err != nil {
+			bad("%s isn't an integer", n)
+		}
+		if min, ok := schema["minimum"].(float64); ok && f < min {
+			bad("%g is less than %g", f, min)
+		}
+		if max, ok := schema["maximum"].(float64); ok && f > max {
+			bad("%g is more than %g", f, max)
+		}
+		if min, ok := schema["exclusiveMinimum"].(float64); ok && f <= min {
+			bad("%g isn't more than %g", f, min)
+		}
+		if max, ok := schema["exclusiveMaximum"].(float64); ok && f >= max {
+			bad("%g isn't less than %g", f, max)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			bad("is %T, want a boolean", v)
+		}
+	default:
+		bad("the schema has type %v, which this test doesn't know", want)
+	}
+	return problems
+}
+
+// checkJSONOutput writes a forecast with writeJSON, and checks it against
+// jsonSchema.
+func checkJSONOutput(t *testing.T, name string, jData *jupiterData) {
+	t.Helper()
+	schema := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(jsonSchema), &schema); err != nil {
+		t.Fatalf("the schema isn't JSON: %s", err)
+	}
+	var b bytes.Buffer
+	if err := writeJSON(&b, jData); err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	d := json.NewDecoder(&b)
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		t.Fatalf("%s: %s", name, err)
+	}
+	for _, p := range schemaProblems("forecast", schema, schema["$defs"].(map[string]interface{}), v) {
+		t.Errorf("%s: %s", name, p)
+	}
+}
+
+func TestJSONOutputMatchesSchema(t *testing.T) {
+	denver, err := time.LoadLocation("America/Denver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := testRoundTripForecast(nil)
+	remote.LocalForecast = false
+	remote.JupiterPositions = nil
+	for _, fi := range remote.Intervals {
+		fi.AltAz, fi.TransitHA = nil, 0
+	}
+	tests := []struct {
+		name  string
+		jData *jupiterData
+	}{
+		{"observer and time zone", testRoundTripForecast(denver)},
+		{"no observer", remote},
+		{"nothing forecast", &jupiterData{StartTime: remote.StartTime, EndTime: remote.EndTime, Duration: remote.Duration, Interval: remote.Interval}},
+	}
+	for _, tt := range tests {
+		checkJSONOutput(t, tt.name, tt.jData)
+	}
+}
+
+func TestCalculatedJSONMatchesSchema(t *testing.T) {
+	checkJSONOutput(t, "calculated forecast", testForecast(t))
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/json"
+	"flag"
+	"fmt"
+	pp "github.com/soniakeys/meeus/v3/planetposition"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// forecastServer answers forecast requests over HTTP. The VSOP87 data is
+// loaded once when the server starts, rather than on every request.
+type forecastServer struct {
+	earth       *pp.V87Planet
+	jupiter     *pp.V87Planet
+	maxDuration time.Duration
+	profiles    map[string]*observerProfile
+	refresh     time.Duration
+
+	feedMu sync.Mutex
+	feeds  map[string]*calendarFeed
+}
+
+// calendarFeed is a profile's rendered iCalendar feed, kept until it's due to
+// be refreshed.
+type calendarFeed struct {
+	body      []byte
+	etag      string
+	generated time.Time
+	expires   time.Time
+}
+
+type jsonError struct {
+	Error jsonErrorDetail `json:"error"`
+}
+
+type jsonErrorDetail struct {
+	Status  int    `json:"status"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func serveCommand(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := flags.String("listen", "localhost:8080", "Address to listen for HTTP requests on.")
+	maxDuration := flags.Duration("max-duration", 90*oneDay, "Longest forecast duration a request may ask for.")
+	profilesFile := flags.String("profiles", "", "Optional path to a JSON file of observer profiles to serve iCalendar feeds for, at '/calendar/<name>.ics'.")
+	refresh := flags.Duration("refresh", 6*time.Hour, "How often the iCalendar feeds are recalculated, and how often calendar apps are asked to check them.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise serve [options]\n\nServes forecasts over HTTP. 'GET /forecast' takes the forecast parameters as query parameters and returns the same JSON as '-output json'. With -profiles, 'GET /calendar/<name>.ics' returns a rolling iCalendar feed of the named observer profile's forecast windows.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("serve doesn't take any arguments.")
+	}
+	if *maxDuration <= 0 {
+		return fmt.Errorf("-max-duration must be positive.")
+	}
+	if *refresh < time.Minute {
+		return fmt.Errorf("-refresh must be at least a minute.")
+	}
+
+	var profiles map[string]*observerProfile
+	if *profilesFile != "" {
+		var err error
+		if profiles, err = loadProfiles(*profilesFile); err != nil {
+			return err
+		}
+	}
+
+	earth, jupiter, err := loadPlanets()
+	if err != nil {
+		return err
+	}
+	fs := &forecastServer{earth: earth, jupiter: jupiter, maxDuration: *maxDuration, profiles: profiles, refresh: *refresh, feeds: make(map[string]*calendarFeed)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/forecast", fs.handleForecast)
+	if profiles != nil {
+		mux.HandleFunc("/calendar/", fs.handleCalendar)
+	}
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      2 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+	log.Printf("jovian-noise %s serving forecasts on http://%s/forecast", version, *listen)
+	return srv.ListenAndServe()
+}
+
+func (fs *forecastServer) handleForecast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "", fmt.Sprintf("Method %s is not allowed.", r.Method))
+		return
+	}
+
+	fp, err := forecastParamsFromQuery(r.URL.Query())
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	if fp.Duration > fs.maxDuration {
+		writeError(w, http.StatusBadRequest, "duration", fmt.Sprintf("duration can't be longer than %s.", fs.maxDuration))
+		return
+	}
+
+	jData, err := fp.jupiterData()
+	if err != nil {
+		writeParamError(w, err)
+		return
+	}
+	if err = calculateForecast(jData, fs.earth, fs.jupiter); err != nil {
+		log.Printf("Error calculating forecast for %s: %s", r.URL.RawQuery, err)
+		writeError(w, http.StatusInternalServerError, "", "Error calculating the forecast.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = writeJSON(w, jData); err != nil {
+		log.Printf("Error writing forecast: %s", err)
+	}
+}
+
+func (fs *forecastServer) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "", fmt.Sprintf("Method %s is not allowed.", r.Method))
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/calendar/")
+	if !strings.HasSuffix(name, ".ics") {
+		writeError(w, http.StatusNotFound, "", "Calendar feeds are at '/calendar/<profile>.ics'.")
+		return
+	}
+	profile, ok := fs.profiles[strings.TrimSuffix(name, ".ics")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "", fmt.Sprintf("No observer profile named '%s'.", strings.TrimSuffix(name, ".ics")))
+		return
+	}
+
+	feed, err := fs.calendarFeed(profile)
+	if err != nil {
+		log.Printf("Error calculating calendar feed for %s: %s", profile.Name, err)
+		writeError(w, http.StatusInternalServerError, "", "Error calculating the forecast.")
+		return
+	}
+
+	maxAge := int(time.Until(feed.expires).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	w.Header().Set("Expires", feed.expires.UTC().Format(http.TimeFormat))
+	w.Header().Set("ETag", feed.etag)
+	// ServeContent takes care of If-None-Match and If-Modified-Since.
+	http.ServeContent(w, r, name, feed.generated, bytes.NewReader(feed.body))
+}
+
+// calendarFeed returns the profile's iCalendar feed, recalculating it if the
+// cached one is due for a refresh. The feed starts at the beginning of the
+// previous UTC day, so that windows don't get cut off (and change their UIDs)
+// as the days roll by. The lock is only held to look in and update the cache,
+// so one slow feed doesn't hold up the others; if two requests for the same
+// feed race, both calculate it and the last one is kept.
+func (fs *forecastServer) calendarFeed(profile *observerProfile) (*calendarFeed, error) {
+	now := time.Now()
+	fs.feedMu.Lock()
+	old, ok := fs.feeds[profile.Name]
+	fs.feedMu.Unlock()
+	if ok && now.Before(old.expires) {
+		return old, nil
+	}
+
+	today := now.UTC().Truncate(oneDay)
+	fp := profile.params(today.Add(-oneDay))
+	fp.Duration += oneDay
+	jData, err := fp.jupiterData()
+	if err != nil {
+		return nil, err
+	}
+	if err = calculateForecast(jData, fs.earth, fs.jupiter); err != nil {
+		return nil, err
+	}
+
+	cal := &icsCalendar{Name: fmt.Sprintf("Jupiter radio storms (%s)", profile.Name), UIDSuffix: profile.uidSuffix(), Refresh: fs.refresh, Stamp: today}
+	var b bytes.Buffer
+	if err = writeICS(&b, jData, cal); err != nil {
+		return nil, err
+	}
+
+	// Keep the old Last-Modified time if nothing actually changed.
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(b.Bytes()))
+	generated := now
+	if ok && old.etag == etag {
+		generated = old.generated
+	}
+	feed := &calendarFeed{body: b.Bytes(), etag: etag, generated: generated, expires: now.Add(fs.refresh)}
+	fs.feedMu.Lock()
+	fs.feeds[profile.Name] = feed
+	fs.feedMu.Unlock()
+	return feed, nil
+}
+
+// forecastParamsFromQuery fills in a forecast's parameters from the query
+// parameters of a request. They're named like the command line flags, but
+// with underscores instead of dashes, and 'start' may be used for
+// 'start_time'. Durations may be given in either golang ParseDuration or ISO
+// 8601 format.
+func forecastParamsFromQuery(q map[string][]string) (*forecastParams, error) {
+	fp := &forecastParams{Duration: defaultDuration, Interval: defaultInterval}
+
+	for k, v := range q {
+		if len(v) != 1 {
+			return nil, newParamError(k, "%s may only be given once.", k)
+		}
+		val := v[0]
+		var err error
+		switch k {
+		case "start", "start_time":
+			fp.StartTime = val
+		case "duration":
+			fp.Duration, err = parseQueryDuration(val)
+		case "interval":
+			fp.Interval, err = strconv.Atoi(val)
+		case "timezone":
+			fp.Timezone = val
+		case "offset_hours":
+			fp.OffsetHours, err = strconv.ParseFloat(val, 64)
+		case "local":
+			fp.LocalTZ, err = strconv.ParseBool(val)
+		case "lat":
+			fp.Lat, err = strconv.Atoi(val)
+			fp.LatSet = true
+		case "lon":
+			fp.Lon, err = strconv.Atoi(val)
+			fp.LonSet = true
+		case "non_io_a":
+			fp.NonIoA, err = strconv.ParseBool(val)
+		case "sources":
+			fp.Sources = val
+		default:
+			return nil, newParamError(k, "Unknown parameter '%s'.", k)
+		}
+		if err != nil {
+			return nil, newParamError(k, "Invalid value '%s' for %s.", val, k)
+		}
+	}
+
+	return fp, nil
+}
+
+func parseQueryDuration(s string) (time.Duration, error) {
+	if strings.HasPrefix(strings.TrimPrefix(s, "-"), "P") {
+		return parseISODuration(s)
+	}
+	return time.ParseDuration(s)
+}
+
+// writeParamError writes a problem with the request's parameters.
+func writeParamError(w http.ResponseWriter, err error) {
+	if pe, ok := err.(*paramError); ok {
+		writeError(w, http.StatusBadRequest, strings.ReplaceAll(pe.Param, "-", "_"), pe.plainMessage())
+		return
+	}
+	writeError(w, http.StatusBadRequest, "", err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, param string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	j, err := json.MarshalIndent(&jsonError{jsonErrorDetail{Status: status, Param: param, Message: message}}, "", "\t")
+	if err != nil {
+		log.Printf("Error writing error response: %s", err)
+		return
+	}
+	w.Write(j)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParamErrorPlainMessage(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{"-lon must be between -180 and 180.", "lon must be between -180 and 180."},
+		{"-interval must be at least 1 minute.", "interval must be at least 1 minute."},
+		{"Both -lat and -lon, or neither, must be supplied", "Both lat and lon, or neither, must be supplied"},
+		{"One of the -timezone, -offset-hours, and -local flags can be specified", "One of the timezone, offset_hours, and local flags can be specified"},
+		{"-start-time is -5 hours off", "start_time is -5 hours off"},
+		{"Invalid value '-7x' for offset_hours.", "Invalid value '-7x' for offset_hours."},
+		{"Error loading timezone Etc/GMT-3: unknown time zone", "Error loading timezone Etc/GMT-3: unknown time zone"},
+	}
+	for _, tt := range tests {
+		pe := &paramError{Param: "p", Message: tt.msg}
+		if got := pe.plainMessage(); got != tt.want {
+			t.Errorf("plainMessage(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestHandleForecastErrors(t *testing.T) {
+	fs := &forecastServer{maxDuration: 90 * oneDay}
+	tests := []struct {
+		name    string
+		method  string
+		query   string
+		status  int
+		param   string
+		message string
+	}{
+		{"longitude out of range", "GET", "lat=10&lon=-200", 400, "lon", "lon must be between -180 and 180."},
+		{"latitude out of range", "GET", "lat=-91&lon=10", 400, "lat", "lat must be between -90 and 90."},
+		{"latitude without longitude", "GET", "lat=10", 400, "lat", "Both lat and lon, or neither, must be supplied"},
+		{"bad number", "GET", "lat=ten&lon=10", 400, "lat", "Invalid value 'ten' for lat."},
+		{"negative offset", "GET", "offset_hours=-7x", 400, "offset_hours", "Invalid value '-7x' for offset_hours."},
+		{"zero interval", "GET", "interval=0", 400, "interval", "interval must be at least 1 minute."},
+		{"duration shorter than the interval", "GET", "duration=PT10M", 400, "duration", "duration really should be longer than the interval specified."},
+		{"duration too long", "GET", "duration=P91D", 400, "duration", "duration can't be longer than 2160h0m0s."},
+		{"bad ISO duration", "GET", "duration=P1Y", 400, "duration", "Invalid value 'P1Y' for duration."},
+		{"unknown time zone", "GET", "timezone=Mars/Olympus_Mons", 400, "timezone", "Error loading timezone Mars/Olympus_Mons: unknown time zone Mars/Olympus_Mons"},
+		{"two time zones", "GET", "timezone=UTC&offset_hours=-7", 400, "timezone", "One of the timezone, offset_hours, and local flags can be specified, but not more than that. None are required, however."},
+		{"unknown source", "GET", "sources=Io-A,Io-D", 400, "sources", "The name 'Io-D' is not a valid radio source."},
+		{"bad start time", "GET", "start=yesterday", 400, "start_time", `parsing time "yesterday" as "2006-01-02T15:04:05Z07:00": cannot parse "yesterday" as "2006"`},
+		{"unknown parameter", "GET", "latitude=10", 400, "latitude", "Unknown parameter 'latitude'."},
+		{"repeated parameter", "GET", "lat=10&lat=20&lon=0", 400, "lat", "lat may only be given once."},
+		{"wrong method", "POST", "", 405, "", "Method POST is not allowed."},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		fs.handleForecast(rec, httptest.NewRequest(tt.method, "/forecast?"+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: status is %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type is %q", tt.name, ct)
+		}
+		je := new(jsonError)
+		if err := json.Unmarshal(rec.Body.Bytes(), je); err != nil {
+			t.Errorf("%s: the error isn't JSON: %s\n%s", tt.name, err, rec.Body)
+			continue
+		}
+		if je.Error.Status != tt.status || je.Error.Param != tt.param || je.Error.Message != tt.message {
+			t.Errorf("%s: error is %+v, want {Status:%d Param:%s Message:%s}", tt.name, je.Error, tt.status, tt.param, tt.message)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	fs.handleForecast(rec, httptest.NewRequest("DELETE", "/forecast", nil))
+	if allow := rec.Header().Get("Allow"); allow != "GET, HEAD" {
+		t.Errorf("Allow is %q, want GET, HEAD", allow)
+	}
+}
+
+func TestHandleForecast(t *testing.T) {
+	if os.Getenv("VSOP87") == "" {
+		t.Skip("VSOP87 isn't set")
+	}
+	earth, jupiter, err := loadPlanets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &forecastServer{earth: earth, jupiter: jupiter, maxDuration: 90 * oneDay}
+	rec := httptest.NewRecorder()
+	fs.handleForecast(rec, httptest.NewRequest("GET", "/forecast?start=2024-03-01T00:00:00Z&duration=P7D&lat=40&lon=-105&sources=io-b,Io-A&timezone=America/Denver", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status is %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type is %q", ct)
+	}
+	jData := new(jupiterData)
+	if err := json.Unmarshal(rec.Body.Bytes(), jData); err != nil {
+		t.Fatalf("the forecast doesn't load: %s", err)
+	}
+	if !jData.StartTime.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || jData.Duration != 7*oneDay || !jData.LocalForecast {
+		t.Errorf("forecast is for %s for %s (local %t)", jData.StartTime, jData.Duration, jData.LocalForecast)
+	}
+	if jData.Location == nil || jData.Location.String() != "America/Denver" {
+		t.Errorf("time zone is %v, want America/Denver", jData.Location)
+	}
+	for _, fi := range jData.Intervals {
+		if fi.RadioSource != IoA && fi.RadioSource != IoB {
+			t.Errorf("got a %s interval, but only Io-A and Io-B were asked for", fi.RadioSource)
+			break
+		}
+	}
+	if !strings.Contains(rec.Body.String(), `"sources": [`) {
+		t.Errorf("the forecast doesn't look like -output json's")
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"math/cmplx"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Layout of the spectrogram, in pixels. The forecast strip runs along the
+// top of the waterfall, and the time axis, CML, and Io phase along the
+// bottom.
+const (
+	specMarginLeft   = 100
+	specMarginTop    = 64
+	specMarginRight  = 20
+	specMarginBottom = 96
+	specStripHeight  = 14
+	specFontScale    = 2
+)
+
+var specAxis = color.RGBA{0x88, 0x88, 0x88, 0xff}
+
+// specColormap is the waterfall's colour scale, from the noise floor up.
+var specColormap = []color.RGBA{
+	{0x00, 0x00, 0x00, 0xff},
+	{0x1c, 0x10, 0x6e, 0xff},
+	{0x84, 0x1e, 0x7a, 0xff},
+	{0xe0, 0x4a, 0x3a, 0xff},
+	{0xfc, 0xa6, 0x36, 0xff},
+	{0xfc, 0xfd, 0xbf, 0xff},
+}
+
+// spectrogram is a recording's power over time and frequency, in dB. Power
+// has a column for each stretch of time, each with a row for each band of
+// frequencies from Low to High.
+type spectrogram struct {
+	Start  time.Time
+	End    time.Time
+	Low    float64
+	High   float64
+	Power  [][]float64
+	MinDB  float64
+	MaxDB  float64
+	Center int64
+}
+
+func spectrogramCommand(args []string) error {
+	flags := flag.NewFlagSet("spectrogram", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	sampleRate := flags.Int("sample-rate", 0, "Sample rate in Hz of raw I/Q files without a sidecar.")
+	fftSize := flags.Int("fft", 1024, "FFT size. Must be a power of 2.")
+	width := flags.Int("width", 1200, "Most columns of the waterfall, each averaging the spectra of a stretch of the recording.")
+	height := flags.Int("height", 400, "Most rows of the waterfall, each averaging a band of frequencies.")
+	minDB := flags.String("min-db", "auto", "Power in dB at the bottom of the colour scale, or 'auto'.")
+	maxDB := flags.String("max-db", "auto", "Power in dB at the top of the colour scale, or 'auto'.")
+	out := flags.String("o", "", "Path to write the PNG to. Defaults to stdout.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise spectrogram [options] <recording>\n\nDraws a WAV or raw I/Q recording's dynamic spectrum as a PNG waterfall, marked with the forecast windows, CML, and Io phase along the time axis. -start-time is when the recording started; recordings from the record command have it in their sidecars.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("spectrogram needs a recording to draw.")
+	}
+	if *fftSize < 16 || *fftSize&(*fftSize-1) != 0 {
+		return fmt.Errorf("-fft must be a power of 2, and at least 16.")
+	}
+	if *width < 10 || *height < 10 {
+		return fmt.Errorf("-width and -height must be at least 10.")
+	}
+
+	sf, err := openSampleFile(flags.Arg(0), *sampleRate)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+	if fp.StartTime != "" {
+		if sf.Start, err = time.Parse(time.RFC3339, fp.StartTime); err != nil {
+			return fmt.Errorf("Error parsing -start-time: %s", err)
+		}
+	}
+	if sf.Start.IsZero() {
+		return fmt.Errorf("Give the time %s started recording with -start-time.", sf.Path)
+	}
+	if sf.Frames < 0 {
+		return fmt.Errorf("The length of %s isn't known (is it a pipe?), and drawing a spectrogram needs it.", sf.Path)
+	}
+	if sf.Frames < int64(*fftSize) {
+		return fmt.Errorf("%s is too short for a spectrogram with -fft %d.", sf.Path, *fftSize)
+	}
+
+	sg, err := readSpectrogram(sf, *fftSize, *width, *height)
+	if err != nil {
+		return err
+	}
+	if err = sg.setScale(*minDB, *maxDB); err != nil {
+		return err
+	}
+
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+	jData, windows, err := recordingForecast(p, fp, sg.Start, sg.End)
+	if err != nil {
+		return err
+	}
+	img := sg.draw(p, jData, windows)
+
+	if *out == "" {
+		return png.Encode(os.Stdout, img)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readSpectrogram reads the whole recording, taking the spectrum of each
+// fftSize samples in turn with a Hann window. The spectra are averaged into
+// at most width columns and height rows. Real recordings are mixed down to
+// one channel, and show 0 Hz up to half the sample rate; I/Q recordings show
+// the sample rate's worth of frequencies around the centre.
+func readSpectrogram(sf *sampleFile, fftSize int, width int, height int) (*spectrogram, error) {
+	total := sf.Frames / int64(fftSize)
+	cols := width
+	if total < int64(cols) {
+		cols = int(total)
+	}
+	bins := fftSize / 2
+	if sf.Complex {
+		bins = fftSize
+	}
+	rows := height
+	if bins < rows {
+		rows = bins
+	}
+
+	sg := &spectrogram{Start: sf.Start, Center: sf.Frequency}
+	if sf.Complex {
+		sg.Low, sg.High = float64(sf.Frequency)-float64(sf.SampleRate)/2, float64(sf.Frequency)+float64(sf.SampleRate)/2
+	} else {
+		sg.Low, sg.High = 0, float64(sf.SampleRate)/2
+	}
+
+	hann := make([]float64, fftSize)
+	for i := range hann {
+		hann[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(fftSize-1))
+	}
+	sums := make([][]float64, cols)
+	counts := make([]int, cols)
+	for c := range sums {
+		sums[c] = make([]float64, rows)
+	}
+
+	buf := make([]float64, fftSize*sf.Channels)
+	x := make([]complex128, fftSize)
+	var k int64
+	for ; k < total; k++ {
+		n, err := sf.readFrames(buf)
+		if n < fftSize {
+			if err == nil || errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("Error reading %s: %s", sf.Path, err)
+		}
+		for i := 0; i < fftSize; i++ {
+			frame := buf[i*sf.Channels : (i+1)*sf.Channels]
+			if sf.Complex {
+				x[i] = complex(frame[0]*hann[i], frame[1]*hann[i])
+			} else {
+				var mix float64
+				for _, v := range frame {
+					mix += v
+				}
+				x[i] = complex(mix/float64(sf.Channels)*hann[i], 0)
+			}
+		}
+		fft(x)
+
+		col := int(k * int64(cols) / total)
+		counts[col]++
+		for b := 0; b < bins; b++ {
+			// I/Q spectra are shifted to put 0 Hz in the middle
+			idx := b
+			if sf.Complex {
+				idx = (b + fftSize/2) % fftSize
+			}
+			pow := real(x[idx])*real(x[idx]) + imag(x[idx])*imag(x[idx])
+			sums[col][b*rows/bins] += pow
+		}
+	}
+
+	// a file cut short of what its header says only gets the columns
+	// that were read
+	if k == 0 {
+		return nil, fmt.Errorf("%s has no samples to draw.", sf.Path)
+	}
+	if k < total {
+		cols = int((k-1)*int64(cols)/total) + 1
+		sums, counts = sums[:cols], counts[:cols]
+	}
+	sg.End = sf.Start.Add(time.Duration(float64(k*int64(fftSize)) / float64(sf.SampleRate) * float64(time.Second)))
+
+	perRow := float64(bins) / float64(rows)
+	sg.Power = make([][]float64, cols)
+	for c := range sums {
+		sg.Power[c] = make([]float64, rows)
+		for r, s := range sums[c] {
+			mean := s / perRow / math.Max(float64(counts[c]), 1) / float64(fftSize*fftSize)
+			sg.Power[c][r] = 10 * math.Log10(mean+1e-20)
+		}
+	}
+	return sg, nil
+}
+
+// setScale sets the range of the colour scale. Automatic limits are the 5th
+// and 99.9th percentiles of the power, so the noise is dark and the bursts
+// stand out.
+func (sg *spectrogram) setScale(minDB string, maxDB string) error {
+	all := make([]float64, 0, len(sg.Power)*len(sg.Power[0]))
+	for _, col := range sg.Power {
+		all = append(all, col...)
+	}
+	sort.Float64s(all)
+	sg.MinDB = all[(len(all)-1)*5/100]
+	sg.MaxDB = all[(len(all)-1)*999/1000]
+
+	var err error
+	if minDB != "auto" {
+		if sg.MinDB, err = strconv.ParseFloat(minDB, 64); err != nil {
+			return fmt.Errorf("-min-db must be a number, or 'auto'.")
+		}
+	}
+	if maxDB != "auto" {
+		if sg.MaxDB, err = strconv.ParseFloat(maxDB, 64); err != nil {
+			return fmt.Errorf("-max-db must be a number, or 'auto'.")
+		}
+	}
+	if sg.MaxDB <= sg.MinDB {
+		sg.MaxDB = sg.MinDB + 1
+	}
+	return nil
+}
+
+// color returns the colour for a power in dB.
+func (sg *spectrogram) color(db float64) color.RGBA {
+	f := (db - sg.MinDB) / (sg.MaxDB - sg.MinDB)
+	f = math.Max(0, math.Min(1, f)) * float64(len(specColormap)-1)
+	i := int(f)
+	if i >= len(specColormap)-1 {
+		return specColormap[len(specColormap)-1]
+	}
+	a, b := specColormap[i], specColormap[i+1]
+	t := f - float64(i)
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xff}
+}
+
+// draw draws the waterfall, with time across and frequency up, and the
+// forecast windows in a strip along the top. Window edges are marked across
+// the waterfall, and the CML and Io phase are given under each time tick.
+func (sg *spectrogram) draw(p *planets, jData *jupiterData, windows []*forecastWindow) *image.RGBA {
+	cols, rows := len(sg.Power), len(sg.Power[0])
+	plotW, plotH := cols, rows
+	// stretch short recordings so there's room for the labels
+	scaleX := 1
+	for plotW*scaleX < 600 {
+		scaleX++
+	}
+	plotW *= scaleX
+
+	width := specMarginLeft + plotW + specMarginRight
+	height := specMarginTop + plotH + specMarginBottom
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(pngBackground), image.Point{}, draw.Src)
+
+	for c, col := range sg.Power {
+		for r, db := range col {
+			x := specMarginLeft + c*scaleX
+			y := specMarginTop + plotH - 1 - r
+			draw.Draw(img, image.Rect(x, y, x+scaleX, y+1), image.NewUniform(sg.color(db)), image.Point{}, draw.Src)
+		}
+	}
+
+	loc := jData.displayLocation()
+	zone, _ := sg.Start.In(loc).Zone()
+	startState := p.stateAt(sg.Start, nil)
+	drawText(img, specMarginLeft, 8, fmt.Sprintf("%s - %s %s", sg.Start.In(loc).Format("2006-01-02 15:04:05"), sg.End.In(loc).Format("15:04:05"), zone), specFontScale, pngText)
+	drawText(img, specMarginLeft, 24, fmt.Sprintf("AT START: CML %.1f  IO PHASE %.1f  SOURCE %s", startState.Meridian.Deg(), startState.IoPhase.Deg(), startState.sourceName()), specFontScale, pngText)
+
+	span := float64(sg.End.Sub(sg.Start))
+	xAt := func(t time.Time) int {
+		f := float64(t.Sub(sg.Start)) / span
+		return specMarginLeft + int(math.Round(math.Max(0, math.Min(1, f))*float64(plotW)))
+	}
+
+	// forecast strip and window edges
+	stripY := specMarginTop - specStripHeight - 4
+	drawText(img, 8, stripY+2, "FORECAST", specFontScale, pngText)
+	draw.Draw(img, image.Rect(specMarginLeft, stripY, specMarginLeft+plotW, stripY+specStripHeight), image.NewUniform(pngEmpty), image.Point{}, draw.Src)
+	for _, fw := range windows {
+		c := pngSourceColors[fw.RadioSource]
+		x0, x1 := xAt(fw.Start), xAt(fw.End)
+		draw.Draw(img, image.Rect(x0, stripY, x1, stripY+specStripHeight), image.NewUniform(c), image.Point{}, draw.Src)
+		if label := fw.RadioSource.String(); textWidth(label, specFontScale)+4 <= x1-x0 {
+			drawText(img, x0+2, stripY+2, label, specFontScale, pngBackground)
+		}
+		for _, t := range []time.Time{fw.Start, fw.End} {
+			if t.After(sg.Start) && t.Before(sg.End) {
+				x := xAt(t)
+				for y := specMarginTop; y < specMarginTop+plotH; y += 4 {
+					draw.Draw(img, image.Rect(x, y, x+1, y+2), image.NewUniform(c), image.Point{}, draw.Src)
+				}
+			}
+		}
+	}
+
+	// frequency axis
+	for _, f := range []float64{0, 0.5, 1} {
+		y := specMarginTop + plotH - 1 - int(f*float64(plotH-1))
+		label := fmt.Sprintf("%.3f MHZ", (sg.Low+f*(sg.High-sg.Low))/1e6)
+		if sg.Center == 0 && sg.Low == 0 {
+			label = fmt.Sprintf("%.1f KHZ", f*sg.High/1e3)
+		}
+		draw.Draw(img, image.Rect(specMarginLeft-4, y, specMarginLeft, y+1), image.NewUniform(specAxis), image.Point{}, draw.Src)
+		drawText(img, specMarginLeft-6-textWidth(label, 1), y-2, label, 1, pngText)
+	}
+
+	// time axis, with the CML and Io phase at each tick
+	axisY := specMarginTop + plotH
+	step := specTickStep(sg.End.Sub(sg.Start))
+	timeFormat := "15:04"
+	if step < time.Minute {
+		timeFormat = "15:04:05"
+	}
+	drawText(img, 8, axisY+8, zone, specFontScale, pngText)
+	drawText(img, 8, axisY+26, "CML", specFontScale, pngText)
+	drawText(img, 8, axisY+44, "IO", specFontScale, pngText)
+	for t := sg.Start.Truncate(step); !t.After(sg.End); t = t.Add(step) {
+		if t.Before(sg.Start) {
+			continue
+		}
+		x := xAt(t)
+		st := p.stateAt(t, nil)
+		draw.Draw(img, image.Rect(x, axisY, x+1, axisY+5), image.NewUniform(specAxis), image.Point{}, draw.Src)
+		for i, label := range []string{t.In(loc).Format(timeFormat), fmt.Sprintf("%.0f", st.Meridian.Deg()), fmt.Sprintf("%.0f", st.IoPhase.Deg())} {
+			drawText(img, x-textWidth(label, specFontScale)/2, axisY+8+i*18, label, specFontScale, pngText)
+		}
+	}
+
+	// legend
+	lx := specMarginLeft
+	ly := axisY + 70
+	for _, rs := range []radioSource{IoA, IoB, IoC, NonIoA} {
+		draw.Draw(img, image.Rect(lx, ly, lx+10, ly+10), image.NewUniform(pngSourceColors[rs]), image.Point{}, draw.Src)
+		drawText(img, lx+14, ly, rs.String(), specFontScale, pngText)
+		lx += 14 + textWidth(rs.String(), specFontScale) + 16
+	}
+	scale := fmt.Sprintf("%.0f DB", sg.MinDB)
+	drawText(img, lx, ly, scale, specFontScale, pngText)
+	lx += textWidth(scale, specFontScale) + 6
+	for i := 0; i < 100; i++ {
+		c := sg.color(sg.MinDB + float64(i)/99*(sg.MaxDB-sg.MinDB))
+		draw.Draw(img, image.Rect(lx+i, ly, lx+i+1, ly+10), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	drawText(img, lx+106, ly, fmt.Sprintf("%.0f DB", sg.MaxDB), specFontScale, pngText)
+	return img
+}
+
+// specTickStep picks a round step between time ticks that gives no more
+// than 8 of them.
+func specTickStep(d time.Duration) time.Duration {
+	for _, s := range []time.Duration{time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second, 15 * time.Second, 30 * time.Second, time.Minute, 2 * time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour} {
+		if d/s <= 8 {
+			return s
+		}
+	}
+	return 12 * time.Hour
+}
+
+// fft does an in place radix 2 fast Fourier transform. len(x) must be a
+// power of 2.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReadSpectrogramTruncated(t *testing.T) {
+	const fftSize, rate = 64, 8000
+	start := time.Date(2024, 1, 1, 5, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		frames int
+		cols   int
+		end    time.Duration
+	}{
+		{"whole file", 100 * fftSize, 50, 800 * time.Millisecond},
+		// the header says 100 FFTs' worth, but there are only 40
+		{"cut short", 40 * fftSize, 20, 320 * time.Millisecond},
+		{"cut short mid FFT", 40*fftSize + fftSize/2, 20, 320 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		data := wavBytes(rate, 1, repeat16(100*fftSize, 8192, -8192))
+		data = data[:44+2*tt.frames]
+		sf, err := openSampleFile(writeTestFile(t, "cut.wav", data), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sf.Start = start
+		sg, err := readSpectrogram(sf, fftSize, 50, 16)
+		sf.Close()
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if len(sg.Power) != tt.cols {
+			t.Errorf("%s: got %d columns, want %d", tt.name, len(sg.Power), tt.cols)
+		}
+		if got := sg.End.Sub(sg.Start); got != tt.end {
+			t.Errorf("%s: ends %s after the start, want %s", tt.name, got, tt.end)
+		}
+		for c, col := range sg.Power {
+			loudest := -200.0
+			for _, db := range col {
+				loudest = math.Max(loudest, db)
+			}
+			if loudest < -100 {
+				t.Errorf("%s: column %d is empty", tt.name, c)
+				break
+			}
+		}
+	}
+
+	data := wavBytes(rate, 1, repeat16(100*fftSize, 0))
+	sf, err := openSampleFile(writeTestFile(t, "empty.wav", data[:44]), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close()
+	if _, err := readSpectrogram(sf, fftSize, 50, 16); err == nil {
+		t.Error("a file with no samples gave a spectrogram")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"github.com/soniakeys/meeus/v3/coord"
+	"github.com/soniakeys/meeus/v3/elliptic"
+	"github.com/soniakeys/meeus/v3/globe"
+	"github.com/soniakeys/meeus/v3/julian"
+	pp "github.com/soniakeys/meeus/v3/planetposition"
+	"github.com/soniakeys/meeus/v3/sidereal"
+	"github.com/soniakeys/unit"
+	"math"
+	"sync"
+	"time"
+)
+
+// planets holds the VSOP87 data for the long running modes, which calculate
+// forecasts over and over.
+type planets struct {
+	earth   *pp.V87Planet
+	jupiter *pp.V87Planet
+}
+
+func newPlanets() (*planets, error) {
+	earth, jupiter, err := loadPlanets()
+	if err != nil {
+		return nil, err
+	}
+	return &planets{earth: earth, jupiter: jupiter}, nil
+}
+
+// forecast checks the parameters and calculates a forecast for them.
+func (p *planets) forecast(fp *forecastParams) (*jupiterData, error) {
+	jData, err := fp.jupiterData()
+	if err != nil {
+		return nil, err
+	}
+	if err = calculateForecast(jData, p.earth, p.jupiter); err != nil {
+		return nil, err
+	}
+	return jData, nil
+}
+
+// jovianState is where things stand at one moment, rather than over a
+// forecast. RadioSource is NoEvent if no source is likely to be active, and
+// AltAz is only set if there's an observer.
+type jovianState struct {
+	Time        time.Time
+	Meridian    unit.Angle
+	IoPhase     unit.Angle
+	Distance    float64
+	RadioSource radioSource
+	AltAz       *hzCoords
+}
+
+// stateAt works out the state at the given time, the same way as
+// calculateForecast does for each interval. Unlike calculateForecast, it
+// works out Jupiter's position at that exact time.
+func (p *planets) stateAt(t time.Time, observer *globe.Coord) *jovianState {
+	jd := julian.TimeToJD(t)
+	el, _, eDist := p.earth.Position2000(jd)
+	jl, _, jDist := p.jupiter.Position2000(jd)
+	st := &jovianState{Time: t, Meridian: systemIIIMeridian(jd)}
+	st.Distance = distance(el, eDist, jl, jDist)
+	st.IoPhase = ioPos(jd, st.Distance)
+	st.RadioSource = source(st.Meridian, st.IoPhase)
+
+	if observer != nil {
+		ra, dec := elliptic.Position(p.jupiter, p.earth, jd)
+		az, alt := coord.EqToHz(ra, dec, observer.Lat, observer.Lon, sidereal.Apparent(jd))
+		st.AltAz = &hzCoords{Altitude: alt, Azimuth: (az + math.Pi).Mod1()}
+	}
+	return st
+}
+
+// windowSchedule keeps a forecast from now until Horizon ahead, so the long
+// running modes can find the next windows. It's recalculated once it's more
+// than Refresh old.
+type windowSchedule struct {
+	Planets *planets
+	Params  forecastParams
+	Horizon time.Duration
+	Refresh time.Duration
+
+	mu         sync.Mutex
+	jData      *jupiterData
+	calculated time.Time
+}
+
+// forecast returns the schedule's forecast, recalculating it if it's due.
+func (ws *windowSchedule) forecast(now time.Time) (*jupiterData, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.jData != nil && now.Sub(ws.calculated) < ws.Refresh {
+		return ws.jData, nil
+	}
+	fp := ws.Params
+	fp.StartTime = now.Truncate(time.Duration(fp.Interval) * time.Minute).UTC().Format(time.RFC3339)
+	fp.Duration = ws.Horizon
+	jData, err := ws.Planets.forecast(&fp)
+	if err != nil {
+		return nil, err
+	}
+	ws.jData, ws.calculated = jData, now
+	return jData, nil
+}
+
+// next returns the next window of each source to start after now, and the
+// first of those. Sources with no windows in the schedule are left out.
+func (ws *windowSchedule) next(now time.Time) (*forecastWindow, map[radioSource]*forecastWindow, error) {
+	jData, err := ws.forecast(now)
+	if err != nil {
+		return nil, nil, err
+	}
+	var first *forecastWindow
+	bySource := make(map[radioSource]*forecastWindow)
+	for _, fw := range jData.Windows() {
+		if !fw.Start.After(now) {
+			continue
+		}
+		if first == nil {
+			first = fw
+		}
+		if _, ok := bySource[fw.RadioSource]; !ok {
+			bySource[fw.RadioSource] = fw
+		}
+	}
+	return first, bySource, nil
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// burstProfile describes the bursts synthesized for a radio source. Rates
+// are for the middle of a window; activity rises from nothing at the start
+// of the window and falls away again at its end. Lengths are the shortest
+// and longest in seconds, and levels are peak amplitudes relative to the
+// band noise.
+type burstProfile struct {
+	// L-bursts are swells of noise lasting seconds, flickering as they
+	// pass through the interplanetary medium.
+	LRate   float64 // per minute
+	LLength [2]float64
+	LLevel  float64
+	// S-bursts are pops of a few milliseconds, which come in trains.
+	TrainRate   float64 // per minute
+	TrainLength [2]float64
+	SRate       float64 // per second during a train
+	SLength     [2]float64
+	SLevel      float64
+}
+
+// burstProfiles are loosely based on what's heard from each source at 20
+// MHz: Io-B is known for its S-burst trains, and the non-Io sources for
+// slow, weak L-bursts.
+var burstProfiles = map[radioSource]*burstProfile{
+	IoA: {
+		LRate: 10, LLength: [2]float64{1, 6}, LLevel: 3,
+		TrainRate: 0.5, TrainLength: [2]float64{0.2, 1}, SRate: 20, SLength: [2]float64{0.002, 0.008}, SLevel: 4,
+	},
+	IoB: {
+		LRate: 2, LLength: [2]float64{1, 4}, LLevel: 2,
+		TrainRate: 4, TrainLength: [2]float64{0.5, 4}, SRate: 40, SLength: [2]float64{0.001, 0.01}, SLevel: 6,
+	},
+	IoC: {
+		LRate: 8, LLength: [2]float64{2, 8}, LLevel: 2.5,
+	},
+	NonIoA: {
+		LRate: 3, LLength: [2]float64{5, 20}, LLevel: 2,
+	},
+}
+
+// synthBurst is one L-burst or S-burst, timed in seconds from the start of
+// the synthetic recording. S-bursts have the number of the train they're
+// in, counting from 1; L-bursts have 0. Flicker is how fast an L-burst's
+// level wavers, in Hz.
+type synthBurst struct {
+	Start   float64
+	Length  float64
+	Level   float64
+	Train   int
+	Flicker float64
+	Phase   float64
+}
+
+// level is how loud the burst is t seconds into the recording.
+func (b *synthBurst) level(t float64) float64 {
+	x := t - b.Start
+	if x < 0 || x >= b.Length {
+		return 0
+	}
+	if b.Train > 0 {
+		// a sharp attack, then a quick decay
+		return b.Level * (1 - math.Exp(-x/0.0003)) * math.Exp(-3*x/b.Length)
+	}
+	s := math.Sin(math.Pi * x / b.Length)
+	return b.Level * s * s * (1 + 0.3*math.Sin(2*math.Pi*b.Flicker*x+b.Phase))
+}
+
+// jsonSynthesis goes in the sidecar of a synthetic recording, so detectors
+// can be checked against what was put in it. Bursts lists the L-bursts and
+// the S-burst trains, with how loud they get over the band noise.
+type jsonSynthesis struct {
+	Seed      int64                 `json:"seed"`
+	NoiseDBFS float64               `json:"noise_dbfs"`
+	Bursts    []*jsonSyntheticBurst `json:"bursts"`
+}
+
+type jsonSyntheticBurst struct {
+	Kind    string    `json:"kind"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	LevelDB float64   `json:"level_db"`
+	Count   int       `json:"count,omitempty"`
+}
+
+func synthesizeCommand(args []string) error {
+	flags := flag.NewFlagSet("synthesize", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	window := flags.Int("window", 1, "Which of the forecast's windows to synthesize, counting from 1.")
+	out := flags.String("o", "", "Path to write the WAV file to. Defaults to one named by source and start time, like the record command's.")
+	sampleRate := flags.Int("sample-rate", 8000, "Sample rate in Hz.")
+	pad := flags.Duration("pad", 5*time.Minute, "How much band noise to put before and after the window.")
+	maxLength := flags.Duration("max-length", 0, "Optional limit on how long the recording is.")
+	noise := flags.Float64("noise", -30, "Level of the band noise, in dB relative to full scale.")
+	seed := flags.Int64("seed", 0, "Seed for the random numbers, to make the same recording again. Defaults to a random seed.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise synthesize [options]\n\nWrites a WAV file of simulated Jovian emission over band noise, timed to one of the forecast's windows, with bursts like the window's radio source makes. A sidecar like the record command's says when it starts and lists the bursts.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("synthesize doesn't take any arguments.")
+	}
+	if *window < 1 {
+		return fmt.Errorf("-window must be at least 1.")
+	}
+	if *sampleRate < 1000 || *sampleRate > 192000 {
+		return fmt.Errorf("-sample-rate must be from 1000 to 192000.")
+	}
+	if *pad < 0 || *maxLength < 0 {
+		return fmt.Errorf("-pad and -max-length can't be negative.")
+	}
+	if *noise > 0 {
+		return fmt.Errorf("-noise must be 0 dB or less.")
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+	jData, err := p.forecast(fp)
+	if err != nil {
+		return err
+	}
+	windows := jData.Windows()
+	if len(windows) < *window {
+		return fmt.Errorf("The forecast only has %d windows. Try a longer -duration.", len(windows))
+	}
+	fw := windows[*window-1]
+
+	start := fw.Start.Add(-*pad).UTC()
+	end := fw.End.Add(*pad).UTC()
+	if *maxLength > 0 && end.Sub(start) > *maxLength {
+		end = start.Add(*maxLength)
+	}
+	frames := int64(end.Sub(start).Seconds() * float64(*sampleRate))
+	if frames*2 > math.MaxUint32-36 {
+		return fmt.Errorf("The recording would be too long for a WAV file; use -max-length or a lower -sample-rate.")
+	}
+
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("%s-%s.wav", fw.RadioSource.slug(), start.Format(recordingTimeFormat))
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".wav") {
+		return fmt.Errorf("%s should end in .wav, so the sidecar can be found next to it.", path)
+	}
+
+	rng := rand.New(rand.NewSource(*seed))
+	from := fw.Start.Sub(start).Seconds()
+	bursts := burstProfiles[fw.RadioSource].bursts(rng, from, fw.End.Sub(fw.Start).Seconds())
+
+	sc := &recordingSidecar{
+		File:         filepath.Base(path),
+		Format:       "wav",
+		RadioSource:  fw.RadioSource,
+		Start:        start,
+		End:          &end,
+		SampleRateHz: *sampleRate,
+		Bytes:        44 + frames*2,
+		Samples:      frames,
+		Window:       newJSONWindow(fw),
+		Intervals:    fw.Intervals,
+		Version:      version,
+		Synthesis:    &jsonSynthesis{Seed: *seed, NoiseDBFS: *noise, Bursts: make([]*jsonSyntheticBurst, 0)},
+	}
+	if jData.LocalForecast {
+		lat, lon := coordsToDeg(jData.Coords)
+		sc.Observer = &jsonObserver{LatitudeDeg: lat, LongitudeDeg: lon}
+	}
+	at := func(s float64) time.Time {
+		return start.Add(time.Duration(s * float64(time.Second)))
+	}
+	trains := make(map[int]*jsonSyntheticBurst)
+	for _, b := range bursts {
+		if b.Start >= end.Sub(start).Seconds() {
+			break
+		}
+		// the bursts' noise adds to the band noise, so the power
+		// over the floor at the peak is 1 + level²
+		db := 10 * math.Log10(1+b.Level*b.Level)
+		if b.Train == 0 {
+			sc.Synthesis.Bursts = append(sc.Synthesis.Bursts, &jsonSyntheticBurst{Kind: "L", Start: at(b.Start), End: at(b.Start + b.Length), LevelDB: db})
+			continue
+		}
+		// S-bursts are listed by train
+		train, ok := trains[b.Train]
+		if !ok {
+			train = &jsonSyntheticBurst{Kind: "S", Start: at(b.Start)}
+			trains[b.Train] = train
+			sc.Synthesis.Bursts = append(sc.Synthesis.Bursts, train)
+		}
+		if e := at(b.Start + b.Length); e.After(train.End) {
+			train.End = e
+		}
+		train.LevelDB = math.Max(train.LevelDB, db)
+		train.Count++
+	}
+
+	if err = writeSynthetic(path, *sampleRate, frames, math.Pow(10, *noise/20), bursts, rng); err != nil {
+		return err
+	}
+	if err = writeSidecar(strings.TrimSuffix(path, filepath.Ext(path))+".json", sc); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s of synthetic %s emission, from %s to %s, to %s (seed %d).\n", end.Sub(start).Round(time.Second), fw.RadioSource, start.Format(time.RFC3339), end.UTC().Format(time.RFC3339), path, *seed)
+	return nil
+}
+
+// bursts makes the bursts for a window starting from seconds into the
+// recording and lasting length seconds, sorted by when they start. Each
+// kind of burst comes at random at its rate, scaled by how active the
+// source is at that point in the window. Bursts and trains are cut off at
+// the end of the window.
+func (bp *burstProfile) bursts(rng *rand.Rand, from float64, length float64) []*synthBurst {
+	activity := func(t float64) float64 {
+		return math.Sin(math.Pi * (t - from) / length)
+	}
+	between := func(r [2]float64) float64 {
+		return r[0] + rng.Float64()*(r[1]-r[0])
+	}
+	// times comes up with when things happen, at up to rate a second,
+	// between t0 and t1
+	times := func(rate float64, t0 float64, t1 float64, scaled bool) []float64 {
+		ts := make([]float64, 0)
+		if rate <= 0 {
+			return ts
+		}
+		for t := t0 + rng.ExpFloat64()/rate; t < t1; t += rng.ExpFloat64() / rate {
+			if !scaled || rng.Float64() < activity(t) {
+				ts = append(ts, t)
+			}
+		}
+		return ts
+	}
+
+	end := from + length
+	bursts := make([]*synthBurst, 0)
+	for _, t := range times(bp.LRate/60, from, end, true) {
+		bursts = append(bursts, &synthBurst{
+			Start:   t,
+			Length:  math.Min(between(bp.LLength), end-t),
+			Level:   bp.LLevel * (0.5 + rng.Float64()) * activity(t),
+			Flicker: 0.5 + 1.5*rng.Float64(),
+			Phase:   2 * math.Pi * rng.Float64(),
+		})
+	}
+	for n, t := range times(bp.TrainRate/60, from, end, true) {
+		level := bp.SLevel * (0.5 + rng.Float64()) * activity(t)
+		for _, s := range times(bp.SRate, t, math.Min(t+between(bp.TrainLength), end), false) {
+			bursts = append(bursts, &synthBurst{Start: s, Length: math.Min(between(bp.SLength), end-s), Level: level * (0.7 + 0.6*rng.Float64()), Train: n + 1})
+		}
+	}
+	sort.Slice(bursts, func(i, j int) bool {
+		return bursts[i].Start < bursts[j].Start
+	})
+	return bursts
+}
+
+// writeSynthetic writes the recording as a 16 bit mono WAV file. The band
+// noise and each burst's noise are independent Gaussian noise, with the
+// bursts' scaled by their level.
+func writeSynthetic(path string, sampleRate int, frames int64, noise float64, bursts []*synthBurst, rng *rand.Rand) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriterSize(f, 1<<20)
+	if err = writeWAVHeader(w, sampleRate, 1, 16, frames); err != nil {
+		return err
+	}
+
+	active := make([]*synthBurst, 0)
+	next := 0
+	var b [2]byte
+	for i := int64(0); i < frames; i++ {
+		t := float64(i) / float64(sampleRate)
+		for next < len(bursts) && bursts[next].Start <= t {
+			active = append(active, bursts[next])
+			next++
+		}
+		var level float64
+		kept := active[:0]
+		for _, sb := range active {
+			if t < sb.Start+sb.Length {
+				level += sb.level(t)
+				kept = append(kept, sb)
+			}
+		}
+		active = kept
+
+		v := noise * (rng.NormFloat64() + level*rng.NormFloat64())
+		v = math.Max(-1, math.Min(1, v))
+		binary.LittleEndian.PutUint16(b[:], uint16(int16(math.Round(v*math.MaxInt16))))
+		if _, err = w.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBurstsDeterministic(t *testing.T) {
+	for rs, bp := range burstProfiles {
+		first := bp.bursts(rand.New(rand.NewSource(42)), 300, 3600)
+		again := bp.bursts(rand.New(rand.NewSource(42)), 300, 3600)
+		other := bp.bursts(rand.New(rand.NewSource(43)), 300, 3600)
+		if len(first) == 0 {
+			t.Errorf("%s: an hour long window has no bursts", rs)
+			continue
+		}
+		if !reflect.DeepEqual(first, again) {
+			t.Errorf("%s: the same seed gave different bursts", rs)
+		}
+		if reflect.DeepEqual(first, other) {
+			t.Errorf("%s: different seeds gave the same bursts", rs)
+		}
+	}
+}
+
+func TestBurstsInsideWindow(t *testing.T) {
+	const from, length = 300.0, 1800.0
+	// Io-B used to run past the end of the window with seeds 832, 1051,
+	// and 2107
+	seeds := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 832, 1051, 2107}
+	for rs, bp := range burstProfiles {
+		for _, seed := range seeds {
+			bursts := bp.bursts(rand.New(rand.NewSource(seed)), from, length)
+			for i, b := range bursts {
+				if b.Start < from || b.Start+b.Length > from+length || b.Length <= 0 {
+					t.Errorf("%s seed %d: burst from %.3fs for %.3fs isn't inside the window from %gs to %gs", rs, seed, b.Start, b.Length, from, from+length)
+				}
+				if i > 0 && b.Start < bursts[i-1].Start {
+					t.Errorf("%s seed %d: bursts aren't in order", rs, seed)
+				}
+				if b.Level < 0 {
+					t.Errorf("%s seed %d: burst at %.3fs has level %g", rs, seed, b.Start, b.Level)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteSynthetic(t *testing.T) {
+	const rate = 8000
+	path := filepath.Join(t.TempDir(), "synth.wav")
+	noise := math.Pow(10, -30.0/20)
+	// an L-burst from 0.5s to 1.5s, 20 dB over the noise at its peak
+	bursts := []*synthBurst{{Start: 0.5, Length: 1, Level: 10, Flicker: 1}}
+	if err := writeSynthetic(path, rate, 2*rate, noise, bursts, rand.New(rand.NewSource(1))); err != nil {
+		t.Fatal(err)
+	}
+
+	sf, err := openSampleFile(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sf.Close()
+	if sf.Format != "wav" || sf.SampleRate != rate || sf.Channels != 1 || sf.Frames != 2*rate || sf.Duration() != 2*time.Second {
+		t.Errorf("read back as %s, %d channels at %d Hz, %d frames", sf.Format, sf.Channels, sf.SampleRate, sf.Frames)
+	}
+	ps, err := readPowerSeries(sf, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ps.Power) != 20 {
+		t.Fatalf("got %d points of power, want 20", len(ps.Power))
+	}
+	for i, pow := range ps.Power {
+		switch {
+		case i < 5 && math.Abs(pow+30) > 1:
+			t.Errorf("power at %d00ms is %.1f dB, want the noise's -30 dB", i, pow)
+		case i >= 9 && i <= 10 && pow < -15:
+			t.Errorf("power at %d00ms is %.1f dB, in the middle of the burst", i, pow)
+		case i >= 15 && math.Abs(pow+30) > 1:
+			t.Errorf("power at %d00ms is %.1f dB, want the noise's -30 dB after the burst", i, pow)
+		}
+	}
+}
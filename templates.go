@@ -15,3 +15,93 @@ var textOutputTemplate = `
 ################################################################################
 
 `
+
+var htmlOutputTemplate = `
+<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Jovian Decameter Radio Storm Forecast: {{.Start}} - {{.End}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; background: #fdfdfd; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.2em; margin-top: 2em; }
+dl.params { display: grid; grid-template-columns: max-content auto; gap: 0.2em 1em; }
+dl.params dt { font-weight: bold; }
+dl.params dd { margin: 0; }
+table.sortable { border-collapse: collapse; }
+table.sortable th, table.sortable td { padding: 0.3em 0.8em; border-bottom: 1px solid #ddd; text-align: left; }
+table.sortable th { cursor: pointer; user-select: none; background: #eee; }
+table.sortable th[aria-sort=ascending]::after { content: " \25B2"; }
+table.sortable th[aria-sort=descending]::after { content: " \25BC"; }
+.swatch { display: inline-block; width: 0.8em; height: 0.8em; margin-right: 0.4em; vertical-align: middle; }
+.legend span { margin-right: 1.5em; }
+.timeline { display: grid; grid-template-columns: 8em 1fr; gap: 0.3em 0.5em; align-items: center; }
+.hours { position: relative; height: 1.2em; font-size: 0.75em; color: #666; }
+.hours span { position: absolute; transform: translateX(-50%); }
+.bar { position: relative; height: 1.4em; background-color: #ececec;
+	background-image: repeating-linear-gradient(to right, transparent 0, transparent calc(100% / 24 - 1px), #d4d4d4 calc(100% / 24 - 1px), #d4d4d4 calc(100% / 24)); }
+.bar div { position: absolute; top: 0; bottom: 0; }
+.bar div.up { background: rgba(255, 215, 100, 0.45); }
+.bar div.active { top: 20%; bottom: 20%; }
+.io-a { background: #d62728; }
+.io-b { background: #1f77b4; }
+.io-c { background: #2ca02c; }
+.non-io-a { background: #9467bd; }
+.swatch.up { background: rgba(255, 215, 100, 0.45); }
+footer { margin-top: 2em; font-size: 0.8em; color: #666; }
+</style>
+</head>
+<body>
+<h1>Jovian Decameter Radio Storm Forecast</h1>
+<dl class="params">
+<dt>From</dt><dd>{{.Start}}</dd>
+<dt>Until</dt><dd>{{.End}}</dd>
+<dt>Duration</dt><dd>{{.Duration}}</dd>
+<dt>Interval</dt><dd>{{.Interval}} minutes</dd>
+{{if .Local}}<dt>Coordinates</dt><dd>{{.Lat}}º, {{.Lon}}º</dd>
+{{end}}<dt>Time zone</dt><dd>{{.Location}}{{if .Offset}} ({{.Offset}}){{end}}</dd>
+</dl>
+
+<h2>Windows</h2>
+{{if .Windows}}<table class="sortable">
+<thead>
+<tr><th data-type="text">Source</th><th data-type="number">Start</th><th data-type="number">End</th><th data-type="number">Duration</th>{{if .Local}}<th data-type="number">Peak Alt.</th><th data-type="text">Rec</th>{{end}}</tr>
+</thead>
+<tbody>
+{{range .Windows}}<tr><td data-sort="{{.Source}}"><span class="swatch {{.Class}}"></span>{{.Source}}</td><td data-sort="{{.StartUnix}}">{{.Start}}</td><td data-sort="{{.EndUnix}}">{{.End}}</td><td data-sort="{{.Minutes}}">{{.Duration}}</td>{{if $.Local}}<td data-sort="{{.PeakDeg}}">{{.PeakAltitude}}</td><td data-sort="{{if .Recommended}}Y{{else}}N{{end}}">{{if .Recommended}}Y{{else}}N{{end}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{else}}<p>No radio storms are forecast for this period.</p>
+{{end}}
+<h2>Timeline</h2>
+<p class="legend">{{if .Local}}<span><span class="swatch up"></span>Jupiter above the horizon</span>{{end}}{{range .Sources}}<span><span class="swatch {{.Class}}"></span>{{.Name}}</span>{{end}}</p>
+<div class="timeline">
+<div></div><div class="hours">{{range .Hours}}<span style="left: {{.Left}}%">{{.Label}}</span>{{end}}</div>
+{{range .Days}}<div>{{.Date}}</div><div class="bar">{{range .Up}}<div class="{{.Class}}" style="left: {{.Left}}%; width: {{.Width}}%" title="{{.Title}}"></div>{{end}}{{range .Active}}<div class="active {{.Class}}" style="left: {{.Left}}%; width: {{.Width}}%" title="{{.Title}}"></div>{{end}}</div>
+{{end}}</div>
+
+<footer>Generated by jovian-noise {{.Version}}</footer>
+<script>
+document.querySelectorAll("table.sortable").forEach(function(table) {
+	table.querySelectorAll("th").forEach(function(th, col) {
+		th.addEventListener("click", function() {
+			var tbody = table.tBodies[0];
+			var rows = Array.prototype.slice.call(tbody.rows);
+			var asc = th.getAttribute("aria-sort") !== "ascending";
+			table.querySelectorAll("th").forEach(function(h) { h.removeAttribute("aria-sort"); });
+			th.setAttribute("aria-sort", asc ? "ascending" : "descending");
+			var numeric = th.dataset.type === "number";
+			rows.sort(function(a, b) {
+				var x = a.cells[col].dataset.sort, y = b.cells[col].dataset.sort;
+				var c = numeric ? parseFloat(x) - parseFloat(y) : x.localeCompare(y);
+				return asc ? c : -c;
+			});
+			rows.forEach(function(r) { tbody.appendChild(r); });
+		});
+	});
+});
+</script>
+</body>
+</html>
+`
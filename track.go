@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// rotatorLimits is how far the rotator can turn, in degrees. Azimuths are
+// clockwise from north, and may go past 360 or below 0 for rotators that can
+// turn more than all the way around, like '-180' to '180' or '0' to '450'.
+type rotatorLimits struct {
+	MinAz float64
+	MaxAz float64
+	MinEl float64
+	MaxEl float64
+}
+
+// rotator points an antenna through rotctld, keeping one connection open
+// and reconnecting if it's lost.
+type rotator struct {
+	addr     string
+	timeout  time.Duration
+	deadband float64
+	limits   rotatorLimits
+
+	hc      *hamlibConn
+	pointed bool
+	az, el  float64
+}
+
+func trackCommand(args []string) error {
+	flags := flag.NewFlagSet("track", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	addr := flags.String("rotctld", "localhost:4533", "Address of the rotctld server to control the antenna rotator with.")
+	every := flags.Duration("every", time.Minute, "How often to update the antenna's position during a window.")
+	deadband := flags.Float64("deadband", 2, "Degrees Jupiter has to move, in azimuth or elevation, before the antenna is moved again.")
+	minAz := flags.Float64("min-az", 0, "The rotator's lowest azimuth, in degrees clockwise from north.")
+	maxAz := flags.Float64("max-az", 360, "The rotator's highest azimuth, in degrees clockwise from north.")
+	minEl := flags.Float64("min-el", 0, "The rotator's lowest elevation, in degrees.")
+	maxEl := flags.Float64("max-el", 90, "The rotator's highest elevation, in degrees.")
+	park := flags.Bool("park", true, "Park the antenna after each window, and when stopping.")
+	timeout := flags.Duration("timeout", 10*time.Second, "How long to wait for rotctld to answer.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise track -lat <lat> -lon <lon> [options]\n\nRuns until stopped, pointing the antenna at Jupiter through rotctld during each forecast window.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("track doesn't take any arguments.")
+	}
+	if fp.StartTime != "" {
+		return fmt.Errorf("-start-time can't be used with track, which always starts now.")
+	}
+	base, err := fp.jupiterData()
+	if err != nil {
+		return err
+	}
+	if !base.LocalForecast {
+		return fmt.Errorf("track needs -lat and -lon to know where to point the antenna.")
+	}
+	if *every < time.Second {
+		return fmt.Errorf("-every must be at least a second.")
+	}
+	if *deadband < 0 {
+		return fmt.Errorf("-deadband can't be negative.")
+	}
+	limits := rotatorLimits{MinAz: *minAz, MaxAz: *maxAz, MinEl: *minEl, MaxEl: *maxEl}
+	if limits.MinAz >= limits.MaxAz || limits.MinEl >= limits.MaxEl {
+		return fmt.Errorf("The rotator's minimum azimuth and elevation need to be less than its maximums.")
+	}
+
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+	rot := &rotator{addr: *addr, timeout: *timeout, deadband: *deadband, limits: limits}
+	ww := &windowWatcher{Params: fp, Leads: []time.Duration{0}, Horizon: 2 * oneDay, Step: oneDay, Calculate: p.forecast}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	events := make(chan *windowEvent)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- ww.Run(ctx, func(ev *windowEvent) error {
+			select {
+			case events <- ev:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	log.Printf("jovian-noise %s tracking through rotctld at %s.", version, *addr)
+	ticker := time.NewTicker(*every)
+	defer ticker.Stop()
+
+	// Windows of different sources can follow right on from each other,
+	// so rather than counting windows in and out, keep tracking until the
+	// last one that's opened has closed.
+	var until time.Time
+	tracking := false
+	for {
+		select {
+		case ev := <-events:
+			if ev.Kind == windowOpens && ev.Window.End.After(until) {
+				log.Printf("Tracking Jupiter for the %s window until %s.", ev.Window.RadioSource, ev.Window.End.UTC().Format(time.RFC3339))
+				until = ev.Window.End
+			}
+		case <-ticker.C:
+		case err = <-errs:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+
+		now := time.Now()
+		if now.Before(until) {
+			st := p.stateAt(now, &base.Coords)
+			if err := rot.point(st.AltAz.Azimuth.Deg(), st.AltAz.Altitude.Deg()); err != nil {
+				log.Printf("Error pointing the antenna: %s", err)
+			}
+			tracking = true
+		} else if tracking {
+			tracking = false
+			if *park {
+				if err := rot.park(); err != nil {
+					log.Printf("Error parking the antenna: %s", err)
+				}
+			}
+		}
+	}
+
+	if tracking && *park {
+		if err := rot.park(); err != nil {
+			log.Printf("Error parking the antenna: %s", err)
+		}
+	}
+	rot.close()
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Stopping.")
+		return nil
+	}
+	return err
+}
+
+// fit returns the position within the rotator's limits to point at for the
+// given azimuth and elevation. If the rotator can reach the azimuth more
+// than one way, the one closest to lastAz is used. Positions it can't reach
+// are clamped to the nearest limit, and ok is false.
+func (rl rotatorLimits) fit(az float64, el float64, lastAz float64) (float64, float64, bool) {
+	ok := true
+	best, bestDist := math.NaN(), math.Inf(1)
+	for _, a := range []float64{az - 360, az, az + 360, az + 720} {
+		if a < rl.MinAz || a > rl.MaxAz {
+			continue
+		}
+		if d := math.Abs(a - lastAz); d < bestDist {
+			best, bestDist = a, d
+		}
+	}
+	if math.IsNaN(best) {
+		ok = false
+		// go to whichever limit is nearer, going around the circle
+		toMin := math.Mod(rl.MinAz-az+720, 360)
+		toMax := math.Mod(az-rl.MaxAz+720, 360)
+		if toMin < toMax {
+			best = rl.MinAz
+		} else {
+			best = rl.MaxAz
+		}
+	}
+	if el < rl.MinEl {
+		el, ok = rl.MinEl, false
+	} else if el > rl.MaxEl {
+		el, ok = rl.MaxEl, false
+	}
+	return best, el, ok
+}
+
+// point moves the antenna to Jupiter's azimuth and elevation, unless it's
+// already pointed within the deadband of there.
+func (r *rotator) point(az float64, el float64) error {
+	lastAz := r.az
+	if !r.pointed {
+		lastAz = az
+	}
+	toAz, toEl, ok := r.limits.fit(az, el, lastAz)
+	if r.pointed && math.Abs(toAz-r.az) < r.deadband && math.Abs(toEl-r.el) < r.deadband {
+		return nil
+	}
+	if !ok {
+		log.Printf("Jupiter is at %.1fº azimuth, %.1fº elevation, beyond the rotator's limits; pointing at %.1fº, %.1fº instead.", az, el, toAz, toEl)
+	}
+	if err := r.set(fmt.Sprintf("P %.1f %.1f", toAz, toEl)); err != nil {
+		return err
+	}
+	r.pointed, r.az, r.el = true, toAz, toEl
+	return nil
+}
+
+// park sends the rotator to its park position.
+func (r *rotator) park() error {
+	r.pointed = false
+	return r.set("K")
+}
+
+// set sends a command to rotctld, connecting first if need be. If anything
+// goes wrong with the connection, it's dropped so the next command
+// reconnects.
+func (r *rotator) set(cmd string) error {
+	if r.hc == nil {
+		hc, err := dialHamlib(context.Background(), r.addr, r.timeout)
+		if err != nil {
+			return err
+		}
+		r.hc = hc
+	}
+	err := r.hc.set(cmd)
+	var rejected *hamlibError
+	if err != nil && !errors.As(err, &rejected) {
+		r.close()
+	}
+	return err
+}
+
+func (r *rotator) close() {
+	if r.hc != nil {
+		r.hc.Close()
+		r.hc = nil
+	}
+}
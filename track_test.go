@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatorLimitsFit(t *testing.T) {
+	full := rotatorLimits{MinAz: 0, MaxAz: 360, MinEl: 0, MaxEl: 90}
+	southStop := rotatorLimits{MinAz: -180, MaxAz: 180, MinEl: 0, MaxEl: 90}
+	overlap := rotatorLimits{MinAz: 0, MaxAz: 450, MinEl: 0, MaxEl: 90}
+	southOnly := rotatorLimits{MinAz: 90, MaxAz: 270, MinEl: 10, MaxEl: 80}
+
+	tests := []struct {
+		name           string
+		limits         rotatorLimits
+		az, el, lastAz float64
+		wantAz, wantEl float64
+		wantOK         bool
+	}{
+		{"in range", full, 123, 45, 123, 123, 45, true},
+		{"west through south stop", southStop, 270, 30, 0, -90, 30, true},
+		{"east through south stop", southStop, 90, 30, 0, 90, 30, true},
+		{"overlap near the start", overlap, 30, 20, 10, 30, 20, true},
+		{"overlap stays on the far side", overlap, 30, 20, 400, 390, 20, true},
+		{"overlap past the end", overlap, 100, 20, 400, 100, 20, true},
+		{"clamped to the nearer minimum", southOnly, 10, 30, 180, 90, 30, false},
+		{"clamped to the nearer maximum", southOnly, 300, 30, 180, 270, 30, false},
+		{"elevation below the limit", southOnly, 180, 5, 180, 180, 10, false},
+		{"elevation above the limit", southOnly, 180, 85, 180, 180, 80, false},
+	}
+	for _, tt := range tests {
+		az, el, ok := tt.limits.fit(tt.az, tt.el, tt.lastAz)
+		if az != tt.wantAz || el != tt.wantEl || ok != tt.wantOK {
+			t.Errorf("%s: got %g, %g, %t, want %g, %g, %t", tt.name, az, el, ok, tt.wantAz, tt.wantEl, tt.wantOK)
+		}
+	}
+}
+
+func TestRotatorDeadbandAndPark(t *testing.T) {
+	fh := newFakeHamlib(t, nil)
+	rot := &rotator{addr: fh.addr(), timeout: 5 * time.Second, deadband: 2, limits: rotatorLimits{MinAz: 0, MaxAz: 360, MinEl: 0, MaxEl: 90}}
+	defer rot.close()
+
+	steps := []struct {
+		park   bool
+		az, el float64
+	}{
+		{az: 100, el: 20},
+		// within the deadband
+		{az: 101.5, el: 21.5},
+		{az: 98.5, el: 20},
+		// azimuth moved far enough
+		{az: 102, el: 20},
+		// elevation moved far enough
+		{az: 102, el: 22.5},
+		{park: true},
+		// the same place, but it's been parked
+		{az: 102, el: 22.5},
+		{az: 103, el: 22.5},
+	}
+	for _, s := range steps {
+		var err error
+		if s.park {
+			err = rot.park()
+		} else {
+			err = rot.point(s.az, s.el)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{"P 100.0 20.0", "P 102.0 20.0", "P 102.0 22.5", "K", "P 102.0 22.5"}
+	if got := fh.sent(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestRotatorRejectedKeepsConnection(t *testing.T) {
+	fh := newFakeHamlib(t, map[string]string{"P 100.0 20.0": "RPRT -1"})
+	rot := &rotator{addr: fh.addr(), timeout: 5 * time.Second, deadband: 2, limits: rotatorLimits{MinAz: 0, MaxAz: 360, MinEl: 0, MaxEl: 90}}
+	defer rot.close()
+
+	if err := rot.point(100, 20); err == nil {
+		t.Fatal("expected the rejected command to be an error")
+	}
+	if rot.hc == nil {
+		t.Error("the connection was dropped after rotctld rejected a command")
+	}
+	// it wasn't pointed, so trying again goes through
+	if err := rot.point(100.5, 20); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"P 100.0 20.0", "P 100.5 20.0"}
+	if got := fh.sent(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
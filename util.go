@@ -1,8 +1,15 @@
 package main
 
 import (
+	"github.com/soniakeys/meeus/v3/coord"
+	"github.com/soniakeys/meeus/v3/globe"
+	"github.com/soniakeys/meeus/v3/julian"
+	"github.com/soniakeys/meeus/v3/rise"
+	"github.com/soniakeys/meeus/v3/sidereal"
+	"github.com/soniakeys/meeus/v3/solar"
 	"github.com/soniakeys/unit"
 	"math"
+	"time"
 )
 
 const fullCircle = 2 * math.Pi
@@ -47,26 +54,102 @@ func ioPos(jd float64, dist float64) unit.Angle {
 	return unit.Angle(math.Mod(ioAngle+math.Pi, fullCircle))
 }
 
+// sunUp returns true if the Sun is above the horizon at the given time and
+// place.
+func sunUp(t time.Time, c globe.Coord) bool {
+	jd := julian.TimeToJD(t)
+	ra, dec := solar.ApparentEquatorial(jd)
+	_, alt := coord.EqToHz(ra, dec, c.Lat, c.Lon, sidereal.Apparent(jd))
+	return alt > rise.Stdh0Solar
+}
+
+// sunDown returns the parts of a span of time when the Sun is below the
+// horizon at the given place, checked every few minutes.
+func sunDown(ts timeSpan, c globe.Coord) []timeSpan {
+	const step = 5 * time.Minute
+	spans := make([]timeSpan, 0)
+	for t := ts.Start; t.Before(ts.End); t = t.Add(step) {
+		if !sunUp(t, c) {
+			end := t.Add(step)
+			if end.After(ts.End) {
+				end = ts.End
+			}
+			spans = appendSpan(spans, timeSpan{t, end})
+		}
+	}
+	return spans
+}
+
+// localNights splits the time between start and end into nights, running
+// from local noon to local noon, so that an evening and the following
+// morning are kept together.
+func localNights(start time.Time, end time.Time, loc *time.Location) []timeSpan {
+	nights := make([]timeSpan, 0)
+	s := start.In(loc)
+	noon := time.Date(s.Year(), s.Month(), s.Day(), 12, 0, 0, 0, loc)
+	if s.Before(noon) {
+		noon = noon.AddDate(0, 0, -1)
+	}
+	for noon.Before(end) {
+		next := noon.AddDate(0, 0, 1)
+		nights = append(nights, timeSpan{noon, next})
+		noon = next
+	}
+	return nights
+}
+
 func reg(a float64) float64 {
 	return math.Mod(a, fullCircle)
 }
 
+// angleRange is a range of angles in degrees. The ends of the range are only
+// part of it if Inclusive is set.
+type angleRange struct {
+	Min       float64
+	Max       float64
+	Inclusive bool
+}
+
+// sourceRegion is a box in the CML/Io phase plane where a radio source is
+// likely to be active.
+type sourceRegion struct {
+	RadioSource radioSource
+	CML         angleRange
+	IoPhase     angleRange
+}
+
+// sourceRegions are checked in order, so a point that falls in more than one
+// region (like Io-A and non-Io-A) belongs to the first one it matches. Io-C
+// wraps around 0°, so it has two regions.
+var sourceRegions = []sourceRegion{
+	{IoA, angleRange{200, 270, true}, angleRange{205, 260, false}},
+	{IoB, angleRange{105, 185, false}, angleRange{80, 110, false}},
+	{IoC, angleRange{300, 360, false}, angleRange{225, 260, false}},
+	{IoC, angleRange{0, 20, false}, angleRange{225, 260, false}},
+	{NonIoA, angleRange{230, 280, false}, angleRange{0, 360, true}},
+}
+
+func (ar angleRange) contains(deg float64) bool {
+	if ar.Inclusive {
+		return deg >= ar.Min && deg <= ar.Max
+	}
+	return deg > ar.Min && deg < ar.Max
+}
+
+func (sr sourceRegion) contains(meridian float64, ioDeg float64) bool {
+	return sr.CML.contains(meridian) && sr.IoPhase.contains(ioDeg)
+}
+
 func source(m unit.Angle, io unit.Angle) radioSource {
-	var s radioSource
 	meridian := m.Deg()
 	ioDeg := io.Deg()
 
-	switch {
-	case (meridian <= 270 && meridian >= 200) && (ioDeg < 260 && ioDeg > 205):
-		s = IoA
-	case (meridian < 185 && meridian > 105) && (ioDeg < 110 && ioDeg > 80):
-		s = IoB
-	case (meridian < 360 && meridian > 300 || meridian < 20 && meridian > 0) && (ioDeg < 260 && ioDeg > 225):
-		s = IoC
-	case (meridian < 280 && meridian > 230):
-		s = NonIoA
+	for _, sr := range sourceRegions {
+		if sr.contains(meridian, ioDeg) {
+			return sr.RadioSource
+		}
 	}
-	return s
+	return NoEvent
 }
 
 func distance(eLon unit.Angle, eDistance float64, jLon unit.Angle, jDistance float64) float64 {
@@ -94,3 +177,42 @@ func angleCalc(e, j unit.Angle) unit.Angle {
 	}
 	return angle
 }
+
+type timeSpan struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (ts timeSpan) overlaps(other timeSpan) bool {
+	return ts.Start.Before(other.End) && other.Start.Before(ts.End)
+}
+
+// clip returns the part of the span inside the other span, and false if they
+// don't overlap at all.
+func (ts timeSpan) clip(other timeSpan) (timeSpan, bool) {
+	if !ts.overlaps(other) {
+		return timeSpan{}, false
+	}
+	if ts.Start.Before(other.Start) {
+		ts.Start = other.Start
+	}
+	if ts.End.After(other.End) {
+		ts.End = other.End
+	}
+	return ts, true
+}
+
+// appendSpan appends a span to a list of spans sorted by time, merging it
+// with the last span if they touch.
+func appendSpan(spans []timeSpan, ts timeSpan) []timeSpan {
+	if !ts.Start.Before(ts.End) {
+		return spans
+	}
+	if n := len(spans); n > 0 && !spans[n-1].End.Before(ts.Start) {
+		if ts.End.After(spans[n-1].End) {
+			spans[n-1].End = ts.End
+		}
+		return spans
+	}
+	return append(spans, ts)
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// verification scores the forecast against the observation log, counting
+// how many of the times sampled fell in each box of the usual contingency
+// table: forecast and detected (hits), forecast but not detected (false
+// alarms), detected but not forecast (misses), and neither.
+type verification struct {
+	Start        time.Time
+	End          time.Time
+	Step         time.Duration
+	Station      string
+	Sessions     int
+	Detections   int
+	Forecast     int // detections during forecast activity
+	BelowHorizon bool
+
+	Hits              int
+	FalseAlarms       int
+	Misses            int
+	CorrectNegatives  int
+	SourceHits        map[radioSource]int
+	SourceFalseAlarms map[radioSource]int
+}
+
+type jsonVerification struct {
+	Start              time.Time          `json:"start"`
+	End                time.Time          `json:"end"`
+	Step               string             `json:"step"`
+	Station            string             `json:"station,omitempty"`
+	Observer           *jsonObserver      `json:"observer,omitempty"`
+	Sessions           int                `json:"sessions"`
+	Detections         int                `json:"detections"`
+	DetectionsForecast int                `json:"detections_forecast"`
+	Hits               string             `json:"hits"`
+	FalseAlarms        string             `json:"false_alarms"`
+	Misses             string             `json:"misses"`
+	CorrectNegatives   string             `json:"correct_negatives"`
+	HitRate            *float64           `json:"hit_rate,omitempty"`
+	FalseAlarmRate     *float64           `json:"false_alarm_rate,omitempty"`
+	Skill              *float64           `json:"skill,omitempty"`
+	Sources            []*jsonSourceSkill `json:"sources"`
+}
+
+type jsonSourceSkill struct {
+	RadioSource    radioSource `json:"radio_source"`
+	Forecast       string      `json:"forecast"`
+	Detected       *float64    `json:"detected,omitempty"`
+	HitRate        *float64    `json:"hit_rate,omitempty"`
+	FalseAlarmRate *float64    `json:"false_alarm_rate,omitempty"`
+	Skill          *float64    `json:"skill,omitempty"`
+}
+
+func verifyCommand(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	db := flags.String("db", defaultObservationLog, "Path to the observation log.")
+	station := flags.String("station", "", "Only use this station's observations.")
+	step := flags.Duration("step", time.Minute, "How often to sample the forecast and the observations.")
+	output := flags.String("output", "text", "How to format the results. Currently acceptable options are: text (default), json.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise verify [options]\n\nScores the forecast against the detections in the observation log, from -start-time for -duration, or over all the logged observations if -start-time isn't given. If sessions have been logged, only the times in them count; otherwise all the time is counted as listened to. With -lat and -lon, times Jupiter was below the horizon are left out.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("verify doesn't take any arguments.")
+	}
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("Output format '%s' is not a valid selection.", *output)
+	}
+	if *step < time.Second {
+		return fmt.Errorf("-step must be at least a second.")
+	}
+
+	ol := &observationLog{Path: *db}
+	all, err := ol.load()
+	if err != nil {
+		return err
+	}
+	var detections, sessions []timeSpan
+	for _, o := range all {
+		if *station != "" && o.Station != *station {
+			continue
+		}
+		if o.Kind == observationSession {
+			sessions = append(sessions, o.span())
+		} else {
+			detections = append(detections, o.span())
+		}
+	}
+	if len(detections) == 0 {
+		return fmt.Errorf("There are no detections in %s to verify the forecast against.", *db)
+	}
+
+	var period timeSpan
+	if fp.StartTime != "" {
+		if period.Start, err = time.Parse(time.RFC3339, fp.StartTime); err != nil {
+			return fmt.Errorf("Error parsing -start-time: %s", err)
+		}
+		period.End = period.Start.Add(fp.Duration)
+	} else {
+		period = detections[0]
+		for _, spans := range [][]timeSpan{detections, sessions} {
+			for _, s := range spans {
+				if s.Start.Before(period.Start) {
+					period.Start = s.Start
+				}
+				if s.End.After(period.End) {
+					period.End = s.End
+				}
+			}
+		}
+		fp.StartTime = period.Start.Format(time.RFC3339)
+	}
+	jData, err := fp.jupiterData()
+	if err != nil {
+		return err
+	}
+	var observer = &jData.Coords
+	if !jData.LocalForecast {
+		observer = nil
+	}
+
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+	v := &verification{
+		Start:             period.Start.UTC(),
+		End:               period.End.UTC(),
+		Step:              *step,
+		Station:           *station,
+		Sessions:          len(sessions),
+		BelowHorizon:      observer != nil,
+		SourceHits:        make(map[radioSource]int),
+		SourceFalseAlarms: make(map[radioSource]int),
+	}
+	// forecast is whether one of the sources being forecast is likely to
+	// be active, and Jupiter is up if there's an observer. It returns
+	// false for counted if the time shouldn't be counted at all.
+	forecast := func(t time.Time) (src radioSource, active bool, counted bool) {
+		st := p.stateAt(t, observer)
+		if st.AltAz != nil && st.AltAz.Altitude <= 0 {
+			return NoEvent, false, false
+		}
+		return st.RadioSource, jData.includesSource(st.RadioSource), true
+	}
+
+	v.tally(detections, sessions, forecast)
+
+	if *output == "json" {
+		return outputVerificationJSON(jData, v)
+	}
+	outputVerificationText(jData, v)
+	return nil
+}
+
+// tally samples the period every Step, counting each time in the
+// contingency table by whether activity was forecast and whether it was
+// detected, and counts the detections in the period that were forecast.
+// Only times in the sessions are counted, if there are any. forecast gives
+// the source forecast to be active at a time, and counted is false if the
+// time shouldn't be counted at all.
+func (v *verification) tally(detections []timeSpan, sessions []timeSpan, forecast func(t time.Time) (src radioSource, active bool, counted bool)) {
+	detected := newSpanSet(detections)
+	listening := newSpanSet(sessions)
+	for t := v.Start; t.Before(v.End); t = t.Add(v.Step) {
+		if len(sessions) > 0 && !listening.contains(t) {
+			continue
+		}
+		src, active, counted := forecast(t)
+		if !counted {
+			continue
+		}
+		heard := detected.contains(t)
+		switch {
+		case active && heard:
+			v.Hits++
+			v.SourceHits[src]++
+		case active:
+			v.FalseAlarms++
+			v.SourceFalseAlarms[src]++
+		case heard:
+			v.Misses++
+		default:
+			v.CorrectNegatives++
+		}
+	}
+
+	// each detection in the period counts as forecast if there was
+	// activity forecast at any point during it
+	for _, d := range detections {
+		d, ok := d.clip(timeSpan{v.Start, v.End})
+		if !ok {
+			continue
+		}
+		v.Detections++
+		for t := d.Start; t.Before(d.End); t = t.Add(v.Step) {
+			if _, active, _ := forecast(t); active {
+				v.Forecast++
+				break
+			}
+		}
+	}
+}
+
+// rate returns n out of n + others, or nil if both are 0.
+func rate(n int, others int) *float64 {
+	if n+others == 0 {
+		return nil
+	}
+	r := float64(n) / float64(n+others)
+	return &r
+}
+
+// skill is the Peirce skill score, the hit rate less the false alarm rate.
+// It's 1 for a perfect forecast, and 0 or less for one that does no better
+// than chance.
+func skill(hitRate *float64, falseAlarmRate *float64) *float64 {
+	if hitRate == nil || falseAlarmRate == nil {
+		return nil
+	}
+	s := *hitRate - *falseAlarmRate
+	return &s
+}
+
+// sourceSkill is how well the forecast of one source did on its own. Its
+// hit rate is the share of the detected time it was forecast for, and its
+// false alarm rate the share of the quiet time. Detected is the share of
+// the time it was forecast for that something was detected.
+func (v *verification) sourceSkill(rs radioSource) (detected *float64, hitRate *float64, falseAlarmRate *float64) {
+	hits, falseAlarms := v.SourceHits[rs], v.SourceFalseAlarms[rs]
+	detected = rate(hits, falseAlarms)
+	hitRate = rate(hits, v.Hits+v.Misses-hits)
+	falseAlarmRate = rate(falseAlarms, v.FalseAlarms+v.CorrectNegatives-falseAlarms)
+	return detected, hitRate, falseAlarmRate
+}
+
+func (v *verification) duration(samples int) time.Duration {
+	return time.Duration(samples) * v.Step
+}
+
+func outputVerificationText(jData *jupiterData, v *verification) {
+	loc := jData.displayLocation()
+	zone, _ := v.End.In(loc).Zone()
+	who := ""
+	if v.Station != "" {
+		who = fmt.Sprintf(" from %s", v.Station)
+	}
+	fmt.Printf("Verification of the forecast against %d detections%s, %s until %s %s, sampled every %s.\n", v.Detections, who, v.Start.In(loc).Format("2006-01-02 15:04"), v.End.In(loc).Format("2006-01-02 15:04"), zone, v.Step)
+	if v.Sessions > 0 {
+		fmt.Printf("Only the times during logged sessions (%d of them) are counted.\n", v.Sessions)
+	} else {
+		fmt.Printf("No sessions are logged, so all the time is counted as listened to.\n")
+	}
+	if v.BelowHorizon {
+		lat, lon := jData.displayCoords()
+		fmt.Printf("Times Jupiter was below the horizon at %d, %d are left out.\n", lat, lon)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 1, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "\tDetected\tNot detected\t\n")
+	fmt.Fprintf(w, "Forecast\t%s\t%s\t\n", hoursText(v.duration(v.Hits)), hoursText(v.duration(v.FalseAlarms)))
+	fmt.Fprintf(w, "Not forecast\t%s\t%s\t\n", hoursText(v.duration(v.Misses)), hoursText(v.duration(v.CorrectNegatives)))
+	w.Flush()
+	fmt.Println()
+
+	hitRate := rate(v.Hits, v.Misses)
+	falseAlarmRate := rate(v.FalseAlarms, v.CorrectNegatives)
+	fmt.Printf("Hit rate: %s\n", percentText(hitRate))
+	fmt.Printf("False alarm rate: %s\n", percentText(falseAlarmRate))
+	fmt.Printf("Skill (hit rate less false alarm rate): %s\n", scoreText(skill(hitRate, falseAlarmRate)))
+	fmt.Printf("%d of %d detections were during forecast activity.\n\n", v.Forecast, v.Detections)
+
+	w = tabwriter.NewWriter(os.Stdout, 1, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Source\tForecast\tDetected\tHit rate\tFalse alarms\tSkill\t\n")
+	fmt.Fprintf(w, "------\t--------\t--------\t--------\t------------\t-----\t\n")
+	for _, rs := range jData.Sources {
+		detected, hr, far := v.sourceSkill(rs)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", rs, hoursText(v.duration(v.SourceHits[rs]+v.SourceFalseAlarms[rs])), percentText(detected), percentText(hr), percentText(far), scoreText(skill(hr, far)))
+	}
+	w.Flush()
+}
+
+func outputVerificationJSON(jData *jupiterData, v *verification) error {
+	hitRate := rate(v.Hits, v.Misses)
+	falseAlarmRate := rate(v.FalseAlarms, v.CorrectNegatives)
+	jv := &jsonVerification{
+		Start:              v.Start,
+		End:                v.End,
+		Step:               isoDuration(v.Step),
+		Station:            v.Station,
+		Sessions:           v.Sessions,
+		Detections:         v.Detections,
+		DetectionsForecast: v.Forecast,
+		Hits:               isoDuration(v.duration(v.Hits)),
+		FalseAlarms:        isoDuration(v.duration(v.FalseAlarms)),
+		Misses:             isoDuration(v.duration(v.Misses)),
+		CorrectNegatives:   isoDuration(v.duration(v.CorrectNegatives)),
+		HitRate:            hitRate,
+		FalseAlarmRate:     falseAlarmRate,
+		Skill:              skill(hitRate, falseAlarmRate),
+		Sources:            make([]*jsonSourceSkill, 0, len(jData.Sources)),
+	}
+	if jData.LocalForecast {
+		lat, lon := coordsToDeg(jData.Coords)
+		jv.Observer = &jsonObserver{LatitudeDeg: lat, LongitudeDeg: lon}
+	}
+	for _, rs := range jData.Sources {
+		detected, hr, far := v.sourceSkill(rs)
+		jv.Sources = append(jv.Sources, &jsonSourceSkill{
+			RadioSource:    rs,
+			Forecast:       isoDuration(v.duration(v.SourceHits[rs] + v.SourceFalseAlarms[rs])),
+			Detected:       detected,
+			HitRate:        hr,
+			FalseAlarmRate: far,
+			Skill:          skill(hr, far),
+		})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	return enc.Encode(jv)
+}
+
+func hoursText(d time.Duration) string {
+	return fmt.Sprintf("%0.1fh", d.Hours())
+}
+
+func percentText(r *float64) string {
+	if r == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%0.1f%%", *r*100)
+}
+
+func scoreText(s *float64) string {
+	if s == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%0.2f", *s)
+}
+
+// spanSet answers whether times are in any of a set of spans, which can
+// overlap.
+type spanSet []timeSpan
+
+// newSpanSet sorts the spans and merges the ones that overlap or touch.
+func newSpanSet(spans []timeSpan) spanSet {
+	sorted := append([]timeSpan(nil), spans...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+	ss := make(spanSet, 0, len(sorted))
+	for _, s := range sorted {
+		if n := len(ss); n > 0 && !s.Start.After(ss[n-1].End) {
+			if s.End.After(ss[n-1].End) {
+				ss[n-1].End = s.End
+			}
+			continue
+		}
+		ss = append(ss, s)
+	}
+	return ss
+}
+
+func (ss spanSet) contains(t time.Time) bool {
+	i := sort.Search(len(ss), func(i int) bool {
+		return ss[i].End.After(t)
+	})
+	return i < len(ss) && !t.Before(ss[i].Start)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// ptrText shows a rate or score, or nil.
+func ptrText(f *float64) string {
+	if f == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%.4f", *f)
+}
+
+func TestRate(t *testing.T) {
+	tests := []struct {
+		n, others int
+		want      string
+	}{
+		{0, 0, "nil"},
+		{3, 1, "0.7500"},
+		{0, 5, "0.0000"},
+		{4, 0, "1.0000"},
+	}
+	for _, tt := range tests {
+		if got := ptrText(rate(tt.n, tt.others)); got != tt.want {
+			t.Errorf("rate(%d, %d) = %s, want %s", tt.n, tt.others, got, tt.want)
+		}
+	}
+}
+
+func TestSkill(t *testing.T) {
+	f := func(v float64) *float64 {
+		return &v
+	}
+	tests := []struct {
+		name         string
+		hitRate, fAR *float64
+		want         string
+	}{
+		{"perfect", f(1), f(0), "1.0000"},
+		{"better than chance", f(0.8), f(0.3), "0.5000"},
+		{"no better than chance", f(0.4), f(0.4), "0.0000"},
+		{"worse than chance", f(0.2), f(0.7), "-0.5000"},
+		{"no hit rate", nil, f(0.1), "nil"},
+		{"no false alarm rate", f(0.5), nil, "nil"},
+	}
+	for _, tt := range tests {
+		if got := ptrText(skill(tt.hitRate, tt.fAR)); got != tt.want {
+			t.Errorf("%s: skill is %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSourceSkill(t *testing.T) {
+	v := &verification{
+		Hits:              6,
+		FalseAlarms:       4,
+		Misses:            2,
+		CorrectNegatives:  8,
+		SourceHits:        map[radioSource]int{IoA: 4, IoB: 2},
+		SourceFalseAlarms: map[radioSource]int{IoA: 1, IoB: 3},
+	}
+	tests := []struct {
+		rs                         radioSource
+		detected, hitRate, falseAR string
+	}{
+		// 4 of the 5 samples forecast for Io-A were detected; it was
+		// forecast for 4 of the 8 detected samples, and 1 of the 12
+		// quiet ones
+		{IoA, "0.8000", "0.5000", "0.0833"},
+		{IoB, "0.4000", "0.2500", "0.2500"},
+		// Io-C was never forecast
+		{IoC, "nil", "0.0000", "0.0000"},
+	}
+	for _, tt := range tests {
+		detected, hr, far := v.sourceSkill(tt.rs)
+		if ptrText(detected) != tt.detected || ptrText(hr) != tt.hitRate || ptrText(far) != tt.falseAR {
+			t.Errorf("%s: detected %s, hit rate %s, false alarm rate %s; want %s, %s, %s", tt.rs, ptrText(detected), ptrText(hr), ptrText(far), tt.detected, tt.hitRate, tt.falseAR)
+		}
+	}
+
+	empty := &verification{SourceHits: map[radioSource]int{}, SourceFalseAlarms: map[radioSource]int{}}
+	if detected, hr, far := empty.sourceSkill(IoA); detected != nil || hr != nil || far != nil {
+		t.Errorf("with nothing counted, got %s, %s, %s", ptrText(detected), ptrText(hr), ptrText(far))
+	}
+}
+
+func TestSpanSet(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time {
+		return t0.Add(time.Duration(m) * time.Minute)
+	}
+	span := func(a, b int) timeSpan {
+		return timeSpan{at(a), at(b)}
+	}
+	// out of order, overlapping, touching, inside another, and apart
+	ss := newSpanSet([]timeSpan{span(20, 30), span(0, 5), span(3, 8), span(8, 10), span(21, 22), span(40, 45)})
+	want := []timeSpan{span(0, 10), span(20, 30), span(40, 45)}
+	if len(ss) != len(want) {
+		t.Fatalf("merged into %v, want %v", ss, want)
+	}
+	for i := range ss {
+		if !ss[i].Start.Equal(want[i].Start) || !ss[i].End.Equal(want[i].End) {
+			t.Errorf("merged into %v, want %v", ss, want)
+			break
+		}
+	}
+
+	tests := []struct {
+		m    int
+		want bool
+	}{
+		{-1, false},
+		{0, true},
+		{5, true},
+		{9, true},
+		{10, false},
+		{15, false},
+		{20, true},
+		{29, true},
+		{30, false},
+		{44, true},
+		{45, false},
+		{100, false},
+	}
+	for _, tt := range tests {
+		if got := ss.contains(at(tt.m)); got != tt.want {
+			t.Errorf("contains %d minutes in = %t, want %t", tt.m, got, tt.want)
+		}
+	}
+	if newSpanSet(nil).contains(t0) {
+		t.Error("an empty set contains something")
+	}
+}
+
+func TestVerifyTally(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time {
+		return t0.Add(time.Duration(m) * time.Minute)
+	}
+	// Io-A is forecast for minutes 2 to 4, Jupiter is in the Io-C region,
+	// which isn't being forecast, for minutes 5 and 6, and it's below the
+	// horizon from minute 8
+	forecast := func(t time.Time) (radioSource, bool, bool) {
+		switch m := int(t.Sub(t0) / time.Minute); {
+		case m >= 8:
+			return NoEvent, false, false
+		case m >= 2 && m < 5:
+			return IoA, true, true
+		case m >= 5 && m < 7:
+			return IoC, false, true
+		}
+		return NoEvent, false, true
+	}
+	detections := []timeSpan{{at(3), at(6)}, {at(9), at(10)}, {at(0), at(1)}}
+
+	tests := []struct {
+		name       string
+		start, end int
+		sessions   []timeSpan
+		// hits, false alarms, misses, correct negatives, detections, and
+		// detections forecast
+		want [6]int
+	}{
+		// minute 0 is a miss, 1 nothing, 2 a false alarm, 3 and 4 hits,
+		// 5 a miss, and 6 and 7 nothing, and 8 and 9 aren't counted
+		{"all the time", 0, 10, nil, [6]int{2, 1, 2, 3, 3, 1}},
+		{"sessions", 0, 10, []timeSpan{{at(0), at(2)}, {at(1), at(4)}, {at(6), at(10)}}, [6]int{1, 1, 1, 3, 3, 1}},
+		{"no sessions overlap", 0, 10, []timeSpan{{at(20), at(30)}}, [6]int{0, 0, 0, 0, 3, 1}},
+		{"part of the time", 1, 10, nil, [6]int{2, 1, 1, 3, 2, 1}},
+		// the detection from minute 3 is forecast, from 4 on
+		{"detection clipped", 4, 8, nil, [6]int{1, 0, 1, 2, 1, 1}},
+		{"below the horizon", 8, 10, nil, [6]int{0, 0, 0, 0, 1, 0}},
+	}
+	for _, tt := range tests {
+		v := &verification{
+			Start:             at(tt.start),
+			End:               at(tt.end),
+			Step:              time.Minute,
+			SourceHits:        make(map[radioSource]int),
+			SourceFalseAlarms: make(map[radioSource]int),
+		}
+		v.tally(detections, tt.sessions, forecast)
+		got := [6]int{v.Hits, v.FalseAlarms, v.Misses, v.CorrectNegatives, v.Detections, v.Forecast}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		if v.SourceHits[IoA] != v.Hits || v.SourceFalseAlarms[IoA] != v.FalseAlarms || len(v.SourceHits)+len(v.SourceFalseAlarms) > 2 {
+			t.Errorf("%s: source hits %v and false alarms %v, want them all Io-A", tt.name, v.SourceHits, v.SourceFalseAlarms)
+		}
+	}
+}
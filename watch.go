@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func watchCommand(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	fp := addForecastFlags(flags)
+	leads := flags.String("lead", "30m,0s", "Comma separated list of how long before each window opens and closes to send alerts (in golang ParseDuration format).")
+	recompute := flags.Duration("recompute", oneDay, "How often to recalculate the forecast. -duration is how far ahead it's calculated.")
+	jsonLines := flags.Bool("json", false, "Write alerts to stdout as JSON lines.")
+	logFile := flags.String("log-file", "", "Append alerts to this file as lines of text.")
+	execCmd := flags.String("exec", "", "Shell command to run for each alert. The alert is passed as JSON on stdin, and in JOVIAN_* environment variables.")
+	webhookURL := flags.String("webhook", "", "URL to POST each alert to. If the JOVIAN_WEBHOOK_SECRET environment variable is set, requests are signed with it.")
+	webhookTmpl := flags.String("webhook-template", "", "Optional path to a text/template file for webhook request bodies, instead of the alert as JSON.")
+	webhookType := flags.String("webhook-content-type", "application/json", "Content type of webhook request bodies.")
+	webhookRetries := flags.Int("webhook-retries", 3, "How many times to retry a webhook that fails.")
+	smtpFile := flags.String("smtp-config", "", "Optional path to a JSON file of SMTP settings. If given, an email is sent before each recommended window opens, at the longest -lead time.")
+	timeout := flags.Duration("timeout", time.Minute, "How long to wait for an alert to be sent before giving up on it.")
+	test := flags.Bool("test", false, "Send an alert about the next window right away, then exit, to check the alerts are set up right.")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: jovian-noise watch [options]\n\nRuns until stopped, sending alerts before forecast windows open and close. Without -json, -log-file, or -exec, alerts are written to stderr.\n\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fp.setFlags(flags)
+
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf("watch doesn't take any arguments.")
+	}
+	if fp.StartTime != "" {
+		return fmt.Errorf("-start-time can't be used with watch, which always starts now.")
+	}
+
+	ww, err := newWatchWindowWatcher(fp, *leads, *recompute)
+	if err != nil {
+		return err
+	}
+	loc, err := fp.location()
+	if err != nil {
+		return err
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	notifiers := make([]notifier, 0)
+	if *jsonLines {
+		notifiers = append(notifiers, newJSONLinesNotifier(os.Stdout))
+	}
+	if *execCmd != "" {
+		notifiers = append(notifiers, &execNotifier{command: *execCmd})
+	}
+	if *webhookURL != "" {
+		n, err := newWebhookNotifier(*webhookURL, os.Getenv("JOVIAN_WEBHOOK_SECRET"), *webhookTmpl, *webhookType, *webhookRetries, loc)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, n)
+	}
+	if *smtpFile != "" {
+		sc, err := loadSMTPConfig(*smtpFile)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, &emailNotifier{config: sc, loc: loc, lead: ww.maxLead()})
+	}
+	if *logFile != "" || len(notifiers) == 0 {
+		n, err := newLogNotifier(*logFile, loc)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, n)
+	}
+
+	if *test {
+		return testWatch(ww, notifiers, *timeout)
+	}
+	return runWatch(ww, notifiers, *timeout)
+}
+
+// newWatchWindowWatcher checks the watch options and sets up a windowWatcher
+// for them.
+func newWatchWindowWatcher(fp *forecastParams, leadList string, recompute time.Duration) (*windowWatcher, error) {
+	ww := &windowWatcher{Params: fp, Horizon: fp.Duration, Step: recompute}
+	for _, l := range strings.Split(leadList, ",") {
+		lead, err := time.ParseDuration(strings.TrimSpace(l))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid lead time '%s': %s", l, err)
+		}
+		if lead < 0 {
+			return nil, fmt.Errorf("Lead times can't be negative.")
+		}
+		ww.Leads = append(ww.Leads, lead)
+	}
+	if recompute < time.Hour {
+		return nil, fmt.Errorf("-recompute must be at least an hour.")
+	}
+	if ww.Horizon < recompute+ww.maxLead()+windowOverlap {
+		return nil, fmt.Errorf("-duration must be at least a day longer than -recompute plus the longest lead time.")
+	}
+	// Check the rest of the parameters now, rather than when the first
+	// forecast is calculated.
+	if _, err := fp.jupiterData(); err != nil {
+		return nil, err
+	}
+	return ww, nil
+}
+
+// runWatch sends alerts from the window watcher to the notifiers until it
+// gets SIGINT or SIGTERM. Problems sending alerts are logged, but don't stop
+// it.
+func runWatch(ww *windowWatcher, notifiers []notifier, timeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := setupWatchCalculate(ww); err != nil {
+		return err
+	}
+	ww.Recalculated = func(jData *jupiterData) {
+		log.Printf("Forecast calculated until %s, with %d windows.", jData.EndTime.Format(time.RFC3339), len(jData.Windows()))
+	}
+
+	err := ww.Run(ctx, func(ev *windowEvent) error {
+		notifyAll(notifiers, ev, timeout)
+		return nil
+	})
+
+	closeNotifiers(notifiers)
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Stopping.")
+		return nil
+	}
+	return err
+}
+
+// testWatch sends an alert about the next window that hasn't closed yet, at
+// the longest lead time, which every notifier sends alerts for.
+func testWatch(ww *windowWatcher, notifiers []notifier, timeout time.Duration) error {
+	if err := setupWatchCalculate(ww); err != nil {
+		return err
+	}
+	fp := *ww.Params
+	fp.StartTime = time.Now().Truncate(time.Duration(fp.Interval) * time.Minute).UTC().Format(time.RFC3339)
+	fp.Duration = ww.Horizon
+	jData, err := ww.Calculate(&fp)
+	if err != nil {
+		return err
+	}
+	windows := jData.Windows()
+	if len(windows) == 0 {
+		return fmt.Errorf("There aren't any windows in the next %s to send a test alert about.", ww.Horizon)
+	}
+	fw := windows[0]
+	ev := &windowEvent{Kind: windowOpens, Lead: ww.maxLead(), Window: fw}
+	ev.At = fw.Start.Add(-ev.Lead)
+
+	errs := notifyAll(notifiers, ev, timeout)
+	closeNotifiers(notifiers)
+	if errs > 0 {
+		return fmt.Errorf("%d of %d test alerts failed.", errs, len(notifiers))
+	}
+	return nil
+}
+
+func setupWatchCalculate(ww *windowWatcher) error {
+	p, err := newPlanets()
+	if err != nil {
+		return err
+	}
+	ww.Calculate = p.forecast
+	return nil
+}
+
+// notifyAll sends the alert to each notifier, logging any problems, and
+// returns how many failed. Alerts get their own context, so ones already
+// being sent can finish when we're told to stop.
+func notifyAll(notifiers []notifier, ev *windowEvent, timeout time.Duration) int {
+	errs := 0
+	for _, n := range notifiers {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := n.Notify(ctx, ev); err != nil {
+			log.Printf("Error sending alert: %s", err)
+			errs++
+		}
+		cancel()
+	}
+	return errs
+}
+
+func closeNotifiers(notifiers []notifier) {
+	for _, n := range notifiers {
+		if err := n.Close(); err != nil {
+			log.Printf("Error closing notifier: %s", err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"text/template"
+	"time"
+)
+
+// webhookNotifier POSTs each alert to a URL. The body is the alert as JSON,
+// unless a template is given. If there's a secret, the body is signed with
+// HMAC-SHA256 and the signature sent in the X-Jovian-Signature header, as
+// "sha256=<hex digest>".
+type webhookNotifier struct {
+	url         string
+	secret      []byte
+	tmpl        *template.Template
+	contentType string
+	retries     int
+	backoff     time.Duration
+	loc         *time.Location
+	client      *http.Client
+}
+
+// webhookData is what webhook body templates get: the alert's fields, plus
+// the alert as a line of text in .Text.
+type webhookData struct {
+	*jsonAlert
+	Text string
+}
+
+const webhookSignatureHeader = "X-Jovian-Signature"
+
+// webhookBackoff is how long to wait before the first retry. It doubles for
+// each retry after that.
+const webhookBackoff = time.Second
+
+func newWebhookNotifier(url string, secret string, tmplFile string, contentType string, retries int, loc *time.Location) (*webhookNotifier, error) {
+	if retries < 0 {
+		return nil, fmt.Errorf("-webhook-retries can't be negative.")
+	}
+	n := &webhookNotifier{url: url, secret: []byte(secret), contentType: contentType, retries: retries, backoff: webhookBackoff, loc: loc, client: &http.Client{}}
+	if tmplFile != "" {
+		tmpl, err := template.New(filepath.Base(tmplFile)).Funcs(webhookTemplateFuncs(loc)).ParseFiles(tmplFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing webhook template %s: %s", tmplFile, err)
+		}
+		n.tmpl = tmpl
+	}
+	return n, nil
+}
+
+func webhookTemplateFuncs(loc *time.Location) template.FuncMap {
+	return template.FuncMap{
+		// json quotes a value for a JSON body
+		"json": func(v interface{}) (string, error) {
+			j, err := json.Marshal(v)
+			return string(j), err
+		},
+		"local": func(t time.Time) time.Time {
+			return t.In(loc)
+		},
+	}
+}
+
+func (n *webhookNotifier) body(ev *windowEvent) ([]byte, error) {
+	ja := newJSONAlert(ev)
+	if n.tmpl == nil {
+		return json.Marshal(ja)
+	}
+	var b bytes.Buffer
+	if err := n.tmpl.Execute(&b, &webhookData{ja, alertText(ev, n.loc)}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// sign returns the value for the signature header.
+func (n *webhookNotifier) sign(body []byte) string {
+	mac := hmac.New(sha256.New, n.secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, ev *windowEvent) error {
+	body, err := n.body(ev)
+	if err != nil {
+		return err
+	}
+
+	backoff := n.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.retries {
+			return err
+		}
+		if serr := sleepUntil(ctx, time.Now().Add(backoff)); serr != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// post sends the body once. If it fails, it also says whether it's worth
+// trying again: network errors, 5xx responses, and 429s are, but the other
+// 4xx responses aren't going to get any better.
+func (n *webhookNotifier) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", n.contentType)
+	req.Header.Set("User-Agent", "jovian-noise/"+version)
+	if len(n.secret) > 0 {
+		req.Header.Set(webhookSignatureHeader, n.sign(body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("Error sending webhook to %s: %s", n.url, err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("Webhook %s returned %s.", n.url, resp.Status)
+}
+
+func (n *webhookNotifier) Close() error {
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/soniakeys/unit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testWindowEvent returns an event for an Io-B window on 1 January 2024,
+// from 02:00 to 03:00 UTC, with Jupiter up.
+func testWindowEvent(kind windowEventKind, lead time.Duration) *windowEvent {
+	start := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+	fw := &forecastWindow{Start: start, End: start.Add(time.Hour), RadioSource: IoB}
+	for i := 0; i < 2; i++ {
+		fw.Intervals = append(fw.Intervals, &forecastInterval{
+			Instant:     start.Add(time.Duration(i) * 30 * time.Minute),
+			Meridian:    unit.AngleFromDeg(100 + float64(i)*18),
+			IoPhase:     unit.AngleFromDeg(80 + float64(i)*4),
+			Distance:    4.5,
+			RadioSource: IoB,
+			AltAz:       &hzCoords{Altitude: unit.AngleFromDeg(40), Azimuth: unit.AngleFromDeg(180)},
+		})
+	}
+	ev := &windowEvent{Kind: kind, Lead: lead, Window: fw}
+	ev.At = ev.When().Add(-lead)
+	return ev
+}
+
+// webhookRecorder is a webhook endpoint that answers with the given statuses
+// in turn, then 200s, and keeps what it was sent.
+type webhookRecorder struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+	times    []time.Time
+}
+
+func (wr *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.bodies = append(wr.bodies, body)
+	wr.headers = append(wr.headers, r.Header.Clone())
+	wr.times = append(wr.times, time.Now())
+	status := http.StatusOK
+	if len(wr.statuses) > 0 {
+		status = wr.statuses[0]
+		wr.statuses = wr.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestWebhook(t *testing.T, statuses []int, secret string, retries int) (*webhookNotifier, *webhookRecorder) {
+	wr := &webhookRecorder{statuses: statuses}
+	srv := httptest.NewServer(wr)
+	t.Cleanup(srv.Close)
+	n, err := newWebhookNotifier(srv.URL, secret, "", "application/json", retries, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.backoff = 20 * time.Millisecond
+	return n, wr
+}
+
+func TestWebhookSignature(t *testing.T) {
+	const secret = "s3kr1t"
+	n, wr := newTestWebhook(t, nil, secret, 0)
+	if err := n.Notify(context.Background(), testWindowEvent(windowOpens, 15*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if len(wr.bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(wr.bodies))
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(wr.bodies[0])
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := wr.headers[0].Get(webhookSignatureHeader); got != want {
+		t.Errorf("signature header is %q, want %q", got, want)
+	}
+	if ct := wr.headers[0].Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type is %q, want application/json", ct)
+	}
+
+	ja := new(jsonAlert)
+	if err := json.Unmarshal(wr.bodies[0], ja); err != nil {
+		t.Fatalf("body isn't a JSON alert: %s", err)
+	}
+	if ja.Event != windowOpens || ja.RadioSource != IoB || ja.Lead != "PT15M" {
+		t.Errorf("body is for %s %s with lead %s, want opens Io-B with lead PT15M", ja.Event, ja.RadioSource, ja.Lead)
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	n, wr := newTestWebhook(t, nil, "", 0)
+	if err := n.Notify(context.Background(), testWindowEvent(windowCloses, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if sig := wr.headers[0].Get(webhookSignatureHeader); sig != "" {
+		t.Errorf("got signature header %q without a secret", sig)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		wantErr  bool
+		attempts int
+	}{
+		{"success", nil, 3, false, 1},
+		{"retries 5xx until it works", []int{500, 503}, 3, false, 3},
+		{"retries 429", []int{429}, 3, false, 2},
+		{"gives up after the retries", []int{500, 500, 500, 500}, 2, true, 3},
+		{"no retries", []int{502}, 0, true, 1},
+		{"doesn't retry 4xx", []int{400}, 3, true, 1},
+		{"doesn't retry 404", []int{404}, 3, true, 1},
+	}
+	for _, tt := range tests {
+		n, wr := newTestWebhook(t, tt.statuses, "", tt.retries)
+		err := n.Notify(context.Background(), testWindowEvent(windowOpens, 0))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %t", tt.name, err, tt.wantErr)
+		}
+		if len(wr.bodies) != tt.attempts {
+			t.Errorf("%s: got %d attempts, want %d", tt.name, len(wr.bodies), tt.attempts)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	n, wr := newTestWebhook(t, []int{500, 500, 500}, "", 3)
+	if err := n.Notify(context.Background(), testWindowEvent(windowOpens, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if len(wr.times) != 4 {
+		t.Fatalf("got %d attempts, want 4", len(wr.times))
+	}
+	// the waits should be at least 20ms, 40ms, and 80ms
+	want := n.backoff
+	for i := 1; i < len(wr.times); i++ {
+		if gap := wr.times[i].Sub(wr.times[i-1]); gap < want {
+			t.Errorf("retry %d came %s after the last attempt, want at least %s", i, gap, want)
+		}
+		want *= 2
+	}
+}
+
+func TestWebhookCanceled(t *testing.T) {
+	n, wr := newTestWebhook(t, []int{500, 500}, "", 5)
+	n.backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := n.Notify(ctx, testWindowEvent(windowOpens, 0)); err == nil {
+		t.Fatal("expected an error when the context is done while waiting to retry")
+	}
+	if len(wr.bodies) != 1 {
+		t.Errorf("got %d attempts, want 1", len(wr.bodies))
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+type windowEventKind string
+
+const (
+	windowOpens  windowEventKind = "opens"
+	windowCloses windowEventKind = "closes"
+)
+
+// windowEvent is a forecast window opening or closing, or an alert Lead ahead
+// of it. At is when the event is due.
+type windowEvent struct {
+	At     time.Time
+	Kind   windowEventKind
+	Lead   time.Duration
+	Window *forecastWindow
+}
+
+// When returns when the window actually opens or closes.
+func (ev *windowEvent) When() time.Time {
+	if ev.Kind == windowOpens {
+		return ev.Window.Start
+	}
+	return ev.Window.End
+}
+
+// windowWatcher follows the forecast as wall clock time goes by, calculating
+// it Horizon ahead and recalculating it every Step. Each calculation starts
+// windowOverlap before the events it's responsible for, which needs to be
+// longer than any window so windows already under way are seen whole.
+type windowWatcher struct {
+	Params    *forecastParams
+	Leads     []time.Duration
+	Horizon   time.Duration
+	Step      time.Duration
+	Calculate func(*forecastParams) (*jupiterData, error)
+	// Recalculated, if set, is called with each new forecast.
+	Recalculated func(*jupiterData)
+}
+
+const windowOverlap = oneDay
+
+// maxLead returns the longest lead time.
+func (ww *windowWatcher) maxLead() time.Duration {
+	var m time.Duration
+	for _, l := range ww.Leads {
+		if l > m {
+			m = l
+		}
+	}
+	return m
+}
+
+// Run calls fn with each event as it comes due, until the context is done
+// or fn returns an error. Events that were due just before Run was called,
+// for windows that are about to open or already open, aren't skipped; the
+// latest of them for each window is sent straight away.
+func (ww *windowWatcher) Run(ctx context.Context, fn func(*windowEvent) error) error {
+	step := time.Duration(ww.Params.Interval) * time.Minute
+	fp := *ww.Params
+
+	from := time.Now().Truncate(step)
+	first := true
+	for {
+		to := from.Add(ww.Step)
+		// Step needn't be a multiple of the interval, so each forecast's
+		// start is put back on the interval grid; otherwise the samples,
+		// and the windows made from them, would move from one
+		// calculation to the next.
+		fp.StartTime = from.Add(-windowOverlap).Truncate(step).UTC().Format(time.RFC3339)
+		fp.Duration = ww.Horizon + windowOverlap
+		jData, err := ww.Calculate(&fp)
+		if err != nil {
+			return err
+		}
+		if ww.Recalculated != nil {
+			ww.Recalculated(jData)
+		}
+
+		events := ww.events(jData.Windows(), from, to, first)
+		for _, ev := range events {
+			if err := sleepUntil(ctx, ev.At); err != nil {
+				return err
+			}
+			if err := fn(ev); err != nil {
+				return err
+			}
+		}
+
+		first = false
+		from = to
+	}
+}
+
+// events returns the events due between from and to, in order. With catchUp,
+// missed opening events for windows that haven't closed yet are moved up to
+// from.
+func (ww *windowWatcher) events(windows []*forecastWindow, from time.Time, to time.Time, catchUp bool) []*windowEvent {
+	events := make([]*windowEvent, 0)
+	for _, fw := range windows {
+		var missed *windowEvent
+		for _, lead := range ww.Leads {
+			for _, kind := range []windowEventKind{windowOpens, windowCloses} {
+				ev := &windowEvent{Kind: kind, Lead: lead, Window: fw}
+				ev.At = ev.When().Add(-lead)
+				if !ev.At.Before(from) && ev.At.Before(to) {
+					events = append(events, ev)
+				} else if catchUp && kind == windowOpens && ev.At.Before(from) && fw.End.After(from) {
+					if missed == nil || ev.At.After(missed.At) {
+						missed = ev
+					}
+				}
+			}
+		}
+		if missed != nil {
+			missed.At = from
+			events = append(events, missed)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At.Before(events[j].At)
+	})
+	return events
+}
+
+// sleepUntil waits until the given time, or returns early with the context's
+// error if it's canceled.
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
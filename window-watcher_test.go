@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWindowWatcherEvents(t *testing.T) {
+	at := func(h int, m int) time.Time {
+		return time.Date(2024, 1, 1, h, m, 0, 0, time.UTC)
+	}
+	ioA := &forecastWindow{Start: at(10, 0), End: at(11, 0), RadioSource: IoA}
+	ioB := &forecastWindow{Start: at(10, 30), End: at(12, 0), RadioSource: IoB}
+	past := &forecastWindow{Start: at(7, 0), End: at(8, 0), RadioSource: IoC}
+
+	type want struct {
+		at     time.Time
+		kind   windowEventKind
+		lead   time.Duration
+		window *forecastWindow
+	}
+	tests := []struct {
+		name    string
+		leads   []time.Duration
+		windows []*forecastWindow
+		from    time.Time
+		to      time.Time
+		catchUp bool
+		want    []want
+	}{
+		{
+			name:    "opens and closes",
+			leads:   []time.Duration{0},
+			windows: []*forecastWindow{ioA},
+			from:    at(9, 0),
+			to:      at(12, 0),
+			want: []want{
+				{at(10, 0), windowOpens, 0, ioA},
+				{at(11, 0), windowCloses, 0, ioA},
+			},
+		},
+		{
+			name:    "leads come before the event",
+			leads:   []time.Duration{0, 15 * time.Minute},
+			windows: []*forecastWindow{ioA},
+			from:    at(9, 0),
+			to:      at(12, 0),
+			want: []want{
+				{at(9, 45), windowOpens, 15 * time.Minute, ioA},
+				{at(10, 0), windowOpens, 0, ioA},
+				{at(10, 45), windowCloses, 15 * time.Minute, ioA},
+				{at(11, 0), windowCloses, 0, ioA},
+			},
+		},
+		{
+			name:    "windows are interleaved in time order",
+			leads:   []time.Duration{0},
+			windows: []*forecastWindow{ioB, ioA},
+			from:    at(9, 0),
+			to:      at(13, 0),
+			want: []want{
+				{at(10, 0), windowOpens, 0, ioA},
+				{at(10, 30), windowOpens, 0, ioB},
+				{at(11, 0), windowCloses, 0, ioA},
+				{at(12, 0), windowCloses, 0, ioB},
+			},
+		},
+		{
+			name:    "from is included and to is not",
+			leads:   []time.Duration{0, 15 * time.Minute},
+			windows: []*forecastWindow{ioA},
+			from:    at(10, 0),
+			to:      at(10, 45),
+			want: []want{
+				{at(10, 0), windowOpens, 0, ioA},
+			},
+		},
+		{
+			name:    "no catch-up",
+			leads:   []time.Duration{0, 15 * time.Minute},
+			windows: []*forecastWindow{ioA},
+			from:    at(10, 30),
+			to:      at(12, 0),
+			want: []want{
+				{at(10, 45), windowCloses, 15 * time.Minute, ioA},
+				{at(11, 0), windowCloses, 0, ioA},
+			},
+		},
+		{
+			name:    "catch-up sends the latest missed opening at from",
+			leads:   []time.Duration{0, 15 * time.Minute},
+			windows: []*forecastWindow{ioA},
+			from:    at(10, 30),
+			to:      at(12, 0),
+			catchUp: true,
+			want: []want{
+				{at(10, 30), windowOpens, 0, ioA},
+				{at(10, 45), windowCloses, 15 * time.Minute, ioA},
+				{at(11, 0), windowCloses, 0, ioA},
+			},
+		},
+		{
+			name:    "catch-up of a lead for a window that hasn't opened",
+			leads:   []time.Duration{time.Hour},
+			windows: []*forecastWindow{ioA},
+			from:    at(9, 30),
+			to:      at(9, 45),
+			catchUp: true,
+			want: []want{
+				{at(9, 30), windowOpens, time.Hour, ioA},
+			},
+		},
+		{
+			name:    "catch-up skips windows that have closed",
+			leads:   []time.Duration{0},
+			windows: []*forecastWindow{past, ioA},
+			from:    at(9, 0),
+			to:      at(10, 0),
+			catchUp: true,
+			want:    []want{},
+		},
+	}
+	for _, tt := range tests {
+		ww := &windowWatcher{Leads: tt.leads}
+		events := ww.events(tt.windows, tt.from, tt.to, tt.catchUp)
+		if len(events) != len(tt.want) {
+			t.Errorf("%s: got %d events, want %d", tt.name, len(events), len(tt.want))
+			continue
+		}
+		for i, ev := range events {
+			w := tt.want[i]
+			if !ev.At.Equal(w.at) || ev.Kind != w.kind || ev.Lead != w.lead || ev.Window != w.window {
+				t.Errorf("%s: event %d is %s %s %s (lead %s), want %s %s %s (lead %s)", tt.name, i, ev.At.Format(time.Kitchen), ev.Window.RadioSource, ev.Kind, ev.Lead, w.at.Format(time.Kitchen), w.window.RadioSource, w.kind, w.lead)
+			}
+		}
+	}
+}
+
+func TestWindowWatcherAlignsForecasts(t *testing.T) {
+	errDone := errors.New("done")
+	starts := make([]time.Time, 0)
+	ww := &windowWatcher{
+		Params:  &forecastParams{Interval: 30},
+		Leads:   []time.Duration{0},
+		Horizon: 2 * oneDay,
+		// deliberately not a multiple of the interval
+		Step: 61 * time.Minute,
+		Calculate: func(fp *forecastParams) (*jupiterData, error) {
+			st, err := time.Parse(time.RFC3339, fp.StartTime)
+			if err != nil {
+				return nil, err
+			}
+			starts = append(starts, st)
+			if len(starts) == 5 {
+				return nil, errDone
+			}
+			return &jupiterData{Interval: fp.Interval}, nil
+		},
+	}
+	err := ww.Run(context.Background(), func(*windowEvent) error {
+		return nil
+	})
+	if err != errDone {
+		t.Fatalf("Run returned %v, want %v", err, errDone)
+	}
+	for i, st := range starts {
+		if st.Truncate(30*time.Minute) != st {
+			t.Errorf("forecast %d starts at %s, which isn't on the 30 minute grid", i, st)
+		}
+		if i > 0 && st.Before(starts[i-1]) {
+			t.Errorf("forecast %d starts before the one before it", i)
+		}
+	}
+}